@@ -179,6 +179,30 @@ async function handleFileUpload(file, side) {
     }
 }
 
+// Show the parser's reported progress (30% to 90%), falling back to
+// elapsed time while no page counts are known
+function showParseProgress(status, attempt, progressFill, progressText) {
+    const progress = status.progress;
+    if (progress && progress.total_pages > 0) {
+        const ratio = Math.min(1, progress.extracted_pages / progress.total_pages);
+        progressFill.style.width = `${30 + Math.round(60 * ratio)}%`;
+        let text = progress.state === 'converting'
+            ? 'MinerU 格式转换中...'
+            : `MinerU 解析中 ${progress.extracted_pages}/${progress.total_pages} 页`;
+        if (status.eta_seconds !== undefined) {
+            text += `，预计剩余 ${status.eta_seconds} 秒`;
+        }
+        progressText.textContent = text;
+        return;
+    }
+
+    progressFill.style.width = `${30 + Math.min(60, attempt * 2)}%`;
+    const waiting = progress && (progress.state === 'pending' || progress.state === 'waiting-file');
+    progressText.textContent = waiting
+        ? `MinerU 排队中... (${attempt * 5}秒)`
+        : `MinerU 处理中... (${attempt * 5}秒)`;
+}
+
 async function pollForResult(contractId, progressFill, progressText) {
     const token = localStorage.getItem('auth_token');
     const maxAttempts = 120; // 10 minutes with 5 second intervals
@@ -188,11 +212,6 @@ async function pollForResult(contractId, progressFill, progressText) {
         await new Promise(resolve => setTimeout(resolve, 5000));
         attempt++;
 
-        // Update progress (30% to 90%)
-        const progress = 30 + Math.min(60, attempt * 2);
-        progressFill.style.width = `${progress}%`;
-        progressText.textContent = `MinerU 处理中... (${attempt * 5}秒)`;
-
         try {
             const statusResponse = await fetch(`/api/contracts/${contractId}/status`, {
                 headers: { 'Authorization': `Bearer ${token}` }
@@ -201,6 +220,7 @@ async function pollForResult(contractId, progressFill, progressText) {
             if (!statusResponse.ok) continue;
 
             const status = await statusResponse.json();
+            showParseProgress(status, attempt, progressFill, progressText);
 
             if (status.status === 'completed') {
                 // Get full contract data with JSON
@@ -213,11 +233,13 @@ async function pollForResult(contractId, progressFill, progressText) {
                 return contract.json_data;
             } else if (status.status === 'failed') {
                 throw new Error(status.error_msg || '处理失败');
+            } else if (status.status === 'cancelled') {
+                throw new Error('处理已取消');
             } else {
                 console.log('Current status:', status.status);
             }
         } catch (error) {
-            if (error.message.includes('处理失败')) {
+            if (error.message.includes('处理失败') || error.message.includes('处理已取消')) {
                 throw error;
             }
             // Continue polling on network errors
@@ -0,0 +1,55 @@
+// Command mineru-fake serves a fake MinerU API for local development.
+//
+//	go run ./cmd/mineru-fake -addr :8090 -uid dev-uid
+//
+// Then set mineru.api_url to http://localhost:8090/api/v4 and mineru.uid to
+// the same UID. Tasks download the uploaded document, or return -file for
+// every task, and finish after -delay.
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/pkg/logger"
+	"github.com/AnTengye/contractdiff/backend/pkg/minerufake"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "listen address")
+	token := flag.String("token", "", "required API token, empty accepts any")
+	uid := flag.String("uid", "", "account UID used to sign callbacks")
+	file := flag.String("file", "", "DOCX or text file returned for every task instead of the uploaded document")
+	delay := flag.Duration("delay", 3*time.Second, "time a task stays running")
+	level := flag.String("log-level", "info", "log level: debug, info, warn, error")
+	flag.Parse()
+
+	logger.Init(&logger.Config{Level: *level, Format: "text"})
+
+	if *file != "" {
+		if _, err := os.Stat(*file); err != nil {
+			slog.Error("document not found", "file", *file, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	srv := minerufake.NewServer(minerufake.Options{
+		Token:          *token,
+		UID:            *uid,
+		Document:       *file,
+		ProcessingTime: *delay,
+	})
+
+	slog.Info("fake MinerU listening",
+		"addr", *addr,
+		"api_url", "http://localhost"+*addr+minerufake.APIPrefix,
+		"document", *file,
+	)
+	if err := http.ListenAndServe(*addr, srv); err != nil {
+		slog.Error("server failed", "error", err)
+		os.Exit(1)
+	}
+}
@@ -1,19 +1,25 @@
 package config
 
 import (
+	"fmt"
 	"os"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Server ServerConfig `yaml:"server"`
-	Minio  MinioConfig  `yaml:"minio"`
-	Mineru MineruConfig `yaml:"mineru"`
-	Auth   AuthConfig   `yaml:"auth"`
-	Log    LogConfig    `yaml:"log"`
-	Store  StoreConfig  `yaml:"store"`
-	Users  []User       `yaml:"users"`
+	Server  ServerConfig  `yaml:"server"`
+	Minio   MinioConfig   `yaml:"minio"`
+	Storage StorageConfig `yaml:"storage"`
+	Mineru  MineruConfig  `yaml:"mineru"`
+	Auth    AuthConfig    `yaml:"auth"`
+	Log     LogConfig     `yaml:"log"`
+	Store   StoreConfig   `yaml:"store"`
+	Parser  ParserConfig  `yaml:"parser"`
+	Queue   QueueConfig   `yaml:"queue"`
+	Users   []User        `yaml:"users"`
+
+	Normalization NormalizationConfig `yaml:"normalization"`
 }
 
 type LogConfig struct {
@@ -34,12 +40,71 @@ type MinioConfig struct {
 	ExpireDays int    `yaml:"expire_days"`
 }
 
+// StorageConfig selects where uploaded files and parse artifacts are kept.
+// The local driver serves files through signed, expiring URLs.
+type StorageConfig struct {
+	Driver      string `yaml:"driver"`       // minio or local
+	Path        string `yaml:"path"`         // Root directory of the local driver
+	BaseURL     string `yaml:"base_url"`     // URL at which parsers reach this server, e.g. http://localhost:8080
+	SigningKey  string `yaml:"signing_key"`  // HMAC key of file URLs, defaults to auth.jwt_secret
+	ExpireHours int    `yaml:"expire_hours"` // Lifetime of file URLs
+	// Orphaned objects, whose contract no longer exists, are deleted by a
+	// periodic scan once they are older than OrphanMinAgeMinutes
+	ReconcileIntervalMinutes int `yaml:"reconcile_interval_minutes"`
+	OrphanMinAgeMinutes      int `yaml:"orphan_min_age_minutes"`
+}
+
 type MineruConfig struct {
-	APIURL       string `yaml:"api_url"`
-	APIToken     string `yaml:"api_token"`
-	ModelVersion string `yaml:"model_version"`
-	CallbackURL  string `yaml:"callback_url"`
-	Seed         string `yaml:"seed"`
+	APIURL       string                    `yaml:"api_url"`
+	APIToken     string                    `yaml:"api_token"`
+	ModelVersion string                    `yaml:"model_version"`
+	CallbackURL  string                    `yaml:"callback_url"`
+	Seed         string                    `yaml:"seed"`
+	UID          string                    `yaml:"uid"` // MinerU account UID, part of the callback checksum
+	Retry        RetryConfig               `yaml:"retry"`
+	Breaker      BreakerConfig             `yaml:"breaker"`
+	Options      ExtractOptions            `yaml:"options"` // Extraction defaults for every upload
+	Tenants      map[string]ExtractOptions `yaml:"tenants"` // Per-tenant defaults, overriding Options
+	ResultLimits ResultLimits              `yaml:"result_limits"`
+}
+
+// ResultLimits bound the parse results downloaded from MinerU, and the DOCX
+// files the built-in parser reads
+type ResultLimits struct {
+	MaxDownloadBytes     int64 `yaml:"max_download_bytes"`     // Size of a result ZIP or JSON as downloaded
+	MaxUncompressedBytes int64 `yaml:"max_uncompressed_bytes"` // Total size of the files read from a result ZIP
+	MaxEntries           int   `yaml:"max_entries"`            // Files in a result ZIP
+}
+
+// DefaultResultLimits apply to limits left unset
+var DefaultResultLimits = ResultLimits{
+	MaxDownloadBytes:     200 << 20,
+	MaxUncompressedBytes: 500 << 20,
+	MaxEntries:           10000,
+}
+
+// ExtractOptions are default MinerU extraction options. Unset fields fall
+// back to the next level: upload, tenant, global, then MinerU itself.
+type ExtractOptions struct {
+	ModelVersion  string `yaml:"model_version"` // Overrides mineru.model_version
+	IsOCR         *bool  `yaml:"is_ocr"`
+	EnableFormula *bool  `yaml:"enable_formula"`
+	EnableTable   *bool  `yaml:"enable_table"`
+	Language      string `yaml:"language"`
+	PageRanges    string `yaml:"page_ranges"`
+}
+
+// RetryConfig controls retries of transient API failures
+type RetryConfig struct {
+	MaxAttempts int `yaml:"max_attempts"`  // Tries per call including the first, 1 = no retry
+	BaseDelayMs int `yaml:"base_delay_ms"` // Delay before the first retry, doubled per retry
+	MaxDelayMs  int `yaml:"max_delay_ms"`  // Upper bound of the retry delay
+}
+
+// BreakerConfig controls the circuit breaker shared by all API calls
+type BreakerConfig struct {
+	FailureThreshold int `yaml:"failure_threshold"` // Consecutive transient failures that open it, 0 = disabled
+	CooldownSeconds  int `yaml:"cooldown_seconds"`  // Time open before a trial call
 }
 
 type AuthConfig struct {
@@ -54,7 +119,41 @@ type User struct {
 }
 
 type StoreConfig struct {
-	MaxContracts int `yaml:"max_contracts"` // Maximum contracts to keep in memory, 0 = unlimited
+	Driver       string `yaml:"driver"`        // memory, sqlite, postgres
+	MaxContracts int    `yaml:"max_contracts"` // Maximum contracts to keep in memory, 0 = unlimited
+	Path         string `yaml:"path"`          // SQLite database file
+	DSN          string `yaml:"dsn"`           // PostgreSQL connection string
+}
+
+// ParserRoute selects a document parser by file extension, falling back to
+// Default for extensions without an entry
+type ParserRoute struct {
+	Default     string            `yaml:"default"`      // Parser name, e.g. mineru
+	ByExtension map[string]string `yaml:"by_extension"` // ".docx" → parser name
+}
+
+// ParserConfig routes uploads to document parsers. Tenant routes take
+// precedence over the global route.
+type ParserConfig struct {
+	ParserRoute `yaml:",inline"`
+	Tenants     map[string]ParserRoute `yaml:"tenants"`
+}
+
+// QueueConfig controls the background parse job queue
+type QueueConfig struct {
+	Workers             int `yaml:"workers"`               // Jobs run concurrently
+	PollIntervalSeconds int `yaml:"poll_interval_seconds"` // Delay between polls of a parse task
+	MaxAttempts         int `yaml:"max_attempts"`          // Polls before a task times out
+	MaxBackoffSeconds   int `yaml:"max_backoff_seconds"`   // Upper bound of the delay after failed attempts
+	LeaseSeconds        int `yaml:"lease_seconds"`         // How long a running job is hidden from other replicas
+}
+
+// NormalizationConfig defines the text normalization profiles comparisons
+// select by name. A profile lists the differences it ignores: whitespace,
+// punctuation, width, case, chinese (traditional/simplified) and numbering.
+type NormalizationConfig struct {
+	Default  string              `yaml:"default"`  // Profile of comparisons that select none
+	Profiles map[string][]string `yaml:"profiles"` // Profile name → rules
 }
 
 var GlobalConfig *Config
@@ -77,18 +176,99 @@ func Load(path string) (*Config, error) {
 	if cfg.Minio.ExpireDays == 0 {
 		cfg.Minio.ExpireDays = 7
 	}
+	if cfg.Storage.Driver == "" {
+		cfg.Storage.Driver = "minio"
+	}
+	if cfg.Storage.Path == "" {
+		cfg.Storage.Path = "data"
+	}
+	if cfg.Storage.BaseURL == "" {
+		cfg.Storage.BaseURL = fmt.Sprintf("http://localhost:%d", cfg.Server.Port)
+	}
+	if cfg.Storage.SigningKey == "" {
+		cfg.Storage.SigningKey = cfg.Auth.JWTSecret
+	}
+	if cfg.Storage.ExpireHours == 0 {
+		cfg.Storage.ExpireHours = cfg.Minio.ExpireDays * 24
+	}
+	if cfg.Storage.ReconcileIntervalMinutes == 0 {
+		cfg.Storage.ReconcileIntervalMinutes = 60
+	}
+	if cfg.Storage.OrphanMinAgeMinutes == 0 {
+		cfg.Storage.OrphanMinAgeMinutes = 60
+	}
 	if cfg.Auth.TokenExpireHours == 0 {
 		cfg.Auth.TokenExpireHours = 24
 	}
 	if cfg.Mineru.ModelVersion == "" {
 		cfg.Mineru.ModelVersion = "vlm"
 	}
+	if cfg.Mineru.Retry.MaxAttempts == 0 {
+		cfg.Mineru.Retry.MaxAttempts = 3
+	}
+	if cfg.Mineru.Retry.BaseDelayMs == 0 {
+		cfg.Mineru.Retry.BaseDelayMs = 500
+	}
+	if cfg.Mineru.Retry.MaxDelayMs == 0 {
+		cfg.Mineru.Retry.MaxDelayMs = 10000
+	}
+	if cfg.Mineru.Breaker.FailureThreshold == 0 {
+		cfg.Mineru.Breaker.FailureThreshold = 5
+	}
+	if cfg.Mineru.Breaker.CooldownSeconds == 0 {
+		cfg.Mineru.Breaker.CooldownSeconds = 30
+	}
+	if cfg.Mineru.ResultLimits.MaxDownloadBytes == 0 {
+		cfg.Mineru.ResultLimits.MaxDownloadBytes = DefaultResultLimits.MaxDownloadBytes
+	}
+	if cfg.Mineru.ResultLimits.MaxUncompressedBytes == 0 {
+		cfg.Mineru.ResultLimits.MaxUncompressedBytes = DefaultResultLimits.MaxUncompressedBytes
+	}
+	if cfg.Mineru.ResultLimits.MaxEntries == 0 {
+		cfg.Mineru.ResultLimits.MaxEntries = DefaultResultLimits.MaxEntries
+	}
 	if cfg.Log.Level == "" {
 		cfg.Log.Level = "info"
 	}
 	if cfg.Log.Format == "" {
 		cfg.Log.Format = "text"
 	}
+	if cfg.Store.Driver == "" {
+		cfg.Store.Driver = "memory"
+	}
+	if cfg.Store.Driver == "sqlite" && cfg.Store.Path == "" {
+		cfg.Store.Path = "contractdiff.db"
+	}
+	if cfg.Parser.Default == "" {
+		cfg.Parser.Default = "mineru"
+	}
+	if cfg.Parser.ByExtension == nil {
+		cfg.Parser.ByExtension = map[string]string{".docx": "docx"}
+	}
+	if cfg.Queue.Workers == 0 {
+		cfg.Queue.Workers = 4
+	}
+	if cfg.Queue.PollIntervalSeconds == 0 {
+		cfg.Queue.PollIntervalSeconds = 5
+	}
+	if cfg.Queue.MaxAttempts == 0 {
+		cfg.Queue.MaxAttempts = 60
+	}
+	if cfg.Queue.MaxBackoffSeconds == 0 {
+		cfg.Queue.MaxBackoffSeconds = 300
+	}
+	if cfg.Queue.LeaseSeconds == 0 {
+		cfg.Queue.LeaseSeconds = 600
+	}
+	if cfg.Normalization.Default == "" {
+		cfg.Normalization.Default = "standard"
+	}
+
+	// Callbacks are verified with uid + seed; without both, anyone could
+	// forge a finished task
+	if cfg.Mineru.CallbackURL != "" && (cfg.Mineru.UID == "" || cfg.Mineru.Seed == "") {
+		return nil, fmt.Errorf("mineru.callback_url requires mineru.uid and mineru.seed")
+	}
 
 	GlobalConfig = &cfg
 	return &cfg, nil
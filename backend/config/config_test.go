@@ -21,6 +21,12 @@ mineru:
   api_url: "https://api.mineru.test"
   api_token: "test-token"
   model_version: "vlm"
+  options:
+    language: "ch"
+  tenants:
+    tenant1:
+      is_ocr: true
+      page_ranges: "1-10"
 auth:
   jwt_secret: "test-secret"
   token_expire_hours: 48
@@ -28,7 +34,15 @@ log:
   level: "debug"
   format: "json"
 store:
+  driver: "sqlite"
   max_contracts: 50
+parser:
+  default: "mineru"
+  by_extension:
+    .pdf: "mineru"
+  tenants:
+    tenant1:
+      default: "docx"
 users:
   - username: "testuser"
     password: "testpass"
@@ -73,6 +87,24 @@ users:
 	if cfg.Store.MaxContracts != 50 {
 		t.Errorf("Expected max_contracts 50, got %d", cfg.Store.MaxContracts)
 	}
+	if cfg.Store.Driver != "sqlite" {
+		t.Errorf("Expected store driver sqlite, got %s", cfg.Store.Driver)
+	}
+	if cfg.Store.Path != "contractdiff.db" {
+		t.Errorf("Expected default sqlite path contractdiff.db, got %s", cfg.Store.Path)
+	}
+	if cfg.Parser.ByExtension[".pdf"] != "mineru" || cfg.Parser.ByExtension[".docx"] != "" {
+		t.Errorf("Expected configured by_extension to replace the default, got %v", cfg.Parser.ByExtension)
+	}
+	if cfg.Parser.Tenants["tenant1"].Default != "docx" {
+		t.Errorf("Expected tenant1 parser docx, got %v", cfg.Parser.Tenants)
+	}
+	if cfg.Mineru.Options.Language != "ch" {
+		t.Errorf("Expected default language ch, got %s", cfg.Mineru.Options.Language)
+	}
+	if opts := cfg.Mineru.Tenants["tenant1"]; opts.IsOCR == nil || !*opts.IsOCR || opts.PageRanges != "1-10" || opts.EnableTable != nil {
+		t.Errorf("Unexpected tenant1 extraction options: %+v", opts)
+	}
 	if len(cfg.Users) != 1 {
 		t.Errorf("Expected 1 user, got %d", len(cfg.Users))
 	}
@@ -125,6 +157,68 @@ minio:
 	if cfg.Log.Format != "text" {
 		t.Errorf("Expected default log format text, got %s", cfg.Log.Format)
 	}
+	if cfg.Store.Driver != "memory" {
+		t.Errorf("Expected default store driver memory, got %s", cfg.Store.Driver)
+	}
+	if cfg.Parser.Default != "mineru" {
+		t.Errorf("Expected default parser mineru, got %s", cfg.Parser.Default)
+	}
+	if cfg.Parser.ByExtension[".docx"] != "docx" {
+		t.Errorf("Expected .docx to route to docx, got %v", cfg.Parser.ByExtension)
+	}
+	if cfg.Queue.Workers != 4 || cfg.Queue.PollIntervalSeconds != 5 || cfg.Queue.MaxAttempts != 60 || cfg.Queue.MaxBackoffSeconds != 300 || cfg.Queue.LeaseSeconds != 600 {
+		t.Errorf("Unexpected queue defaults: %+v", cfg.Queue)
+	}
+	if cfg.Mineru.Retry.MaxAttempts != 3 || cfg.Mineru.Retry.BaseDelayMs != 500 || cfg.Mineru.Retry.MaxDelayMs != 10000 {
+		t.Errorf("Unexpected retry defaults: %+v", cfg.Mineru.Retry)
+	}
+	if cfg.Mineru.Breaker.FailureThreshold != 5 || cfg.Mineru.Breaker.CooldownSeconds != 30 {
+		t.Errorf("Unexpected breaker defaults: %+v", cfg.Mineru.Breaker)
+	}
+	if cfg.Mineru.ResultLimits != DefaultResultLimits {
+		t.Errorf("Unexpected result limit defaults: %+v", cfg.Mineru.ResultLimits)
+	}
+	if cfg.Storage.Driver != "minio" || cfg.Storage.BaseURL != "http://localhost:8080" || cfg.Storage.ExpireHours != 7*24 {
+		t.Errorf("Unexpected storage defaults: %+v", cfg.Storage)
+	}
+	if cfg.Storage.ReconcileIntervalMinutes != 60 || cfg.Storage.OrphanMinAgeMinutes != 60 {
+		t.Errorf("Unexpected reconciler defaults: %+v", cfg.Storage)
+	}
+	if cfg.Normalization.Default != "standard" {
+		t.Errorf("Expected default normalization profile standard, got %s", cfg.Normalization.Default)
+	}
+}
+
+func TestLoadRejectsUnverifiableCallbacks(t *testing.T) {
+	tests := []struct {
+		name    string
+		mineru  string
+		wantErr bool
+	}{
+		{"callback without uid", `{callback_url: "http://example.com/cb", seed: "s"}`, true},
+		{"callback without seed", `{callback_url: "http://example.com/cb", uid: "u"}`, true},
+		{"callback with both", `{callback_url: "http://example.com/cb", uid: "u", seed: "s"}`, false},
+		{"polling only", `{}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpFile, err := os.CreateTemp("", "config-callback-*.yaml")
+			if err != nil {
+				t.Fatalf("Failed to create temp file: %v", err)
+			}
+			defer os.Remove(tmpFile.Name())
+			if _, err := tmpFile.WriteString("mineru: " + tt.mineru + "\n"); err != nil {
+				t.Fatalf("Failed to write config: %v", err)
+			}
+			tmpFile.Close()
+
+			_, err = Load(tmpFile.Name())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
 }
 
 func TestLoadNonExistent(t *testing.T) {
@@ -0,0 +1,170 @@
+package diff
+
+import "slices"
+
+// Alignment scoring. A pair's quality is mapped to a score between -1 and
+// 1, and each unmatched paragraph costs GapPenalty, so two paragraphs are
+// paired when their quality exceeds 0.5 - GapPenalty, i.e. 0.4. This is far
+// below SimilarityThreshold, as the order of the paragraphs and their
+// clause numbers back up a pair.
+const (
+	GapPenalty = 0.1
+	// NumberingWeight is the share of a numbered pair's quality that comes
+	// from its clause numbers, the rest coming from text similarity
+	NumberingWeight = 0.3
+)
+
+// numberingScore scores how well the clause numbers of two paragraphs
+// agree: 1 for the same path, 0.5 for the same number under different
+// parents, 0 otherwise
+func numberingScore(a, b []clauseNumber) float64 {
+	if len(a) == 0 || len(b) == 0 || a[len(a)-1] != b[len(b)-1] {
+		return 0
+	}
+	if len(a) != len(b) {
+		return 0.5
+	}
+	for k := range a {
+		if a[k] != b[k] {
+			return 0.5
+		}
+	}
+	return 1
+}
+
+// alignQuality scores a candidate pair between 0 and 1. Clause numbers
+// only count when at least one paragraph is numbered.
+func alignQuality(similarity float64, leftPath, rightPath []clauseNumber) float64 {
+	if leftPath == nil && rightPath == nil {
+		return similarity
+	}
+	return (1-NumberingWeight)*similarity + NumberingWeight*numberingScore(leftPath, rightPath)
+}
+
+// Alignment traceback steps
+const (
+	stepPair byte = iota
+	stepLeft
+	stepRight
+)
+
+// AlignParagraphs pairs paragraphs with an order-preserving global
+// alignment (Needleman-Wunsch) over their similarity, using the clause
+// numbering hierarchy as context. Unlike MatchParagraphs, a repeated
+// number such as the "1." of every chapter only matches within the same
+// chapter, and one poor match cannot displace the rest. Each pair carries
+// its alignment quality. Texts are compared as normalized by norm.
+func AlignParagraphs(left, right []Paragraph, norm *Normalizer) []Pair {
+	n, m := len(left), len(right)
+	leftPaths, rightPaths := numberingPaths(left), numberingPaths(right)
+	leftGrams, rightGrams := make([][]uint64, n), make([][]uint64, m)
+	for i := range left {
+		leftGrams[i] = sortedBigrams(norm.NormalizeParagraph(left[i].Text))
+	}
+	for j := range right {
+		rightGrams[j] = sortedBigrams(norm.NormalizeParagraph(right[j].Text))
+	}
+
+	// score[i][j] is the best score aligning left[:i] with right[:j]
+	score := make([][]float64, n+1)
+	step := make([][]byte, n+1)
+	quality := make([][]float64, n+1)
+	for i := range score {
+		score[i] = make([]float64, m+1)
+		step[i] = make([]byte, m+1)
+		quality[i] = make([]float64, m+1)
+	}
+	for i := 1; i <= n; i++ {
+		score[i][0] = score[i-1][0] - GapPenalty
+		step[i][0] = stepLeft
+	}
+	for j := 1; j <= m; j++ {
+		score[0][j] = score[0][j-1] - GapPenalty
+		step[0][j] = stepRight
+	}
+	for i := 1; i <= n; i++ {
+		for j := 1; j <= m; j++ {
+			sim := gramSimilarity(left[i-1].Text, right[j-1].Text, leftGrams[i-1], rightGrams[j-1], norm)
+			q := alignQuality(sim, leftPaths[i-1], rightPaths[j-1])
+			quality[i][j] = q
+
+			// On ties the traceback takes added paragraphs first, so that
+			// removed paragraphs come first in the result
+			best, from := score[i-1][j-1]+2*q-1, stepPair
+			if s := score[i][j-1] - GapPenalty; s > best {
+				best, from = s, stepRight
+			}
+			if s := score[i-1][j] - GapPenalty; s > best {
+				best, from = s, stepLeft
+			}
+			score[i][j], step[i][j] = best, from
+		}
+	}
+
+	// Trace back from the end, then reverse
+	pairs := make([]Pair, 0, max(n, m))
+	for i, j := n, m; i > 0 || j > 0; {
+		switch step[i][j] {
+		case stepPair:
+			matchType := MatchSimilarity
+			if numberingScore(leftPaths[i-1], rightPaths[j-1]) == 1 {
+				matchType = MatchNumber
+			}
+			pairs = append(pairs, Pair{
+				Left:       left[i-1],
+				Right:      right[j-1],
+				Similarity: norm.Similarity(left[i-1].Text, right[j-1].Text),
+				Quality:    quality[i][j],
+				IsMatch:    true,
+				MatchType:  matchType,
+			})
+			i, j = i-1, j-1
+		case stepLeft:
+			pairs = append(pairs, Pair{Left: left[i-1], Right: Paragraph{PageIdx: left[i-1].PageIdx}})
+			i--
+		case stepRight:
+			pairs = append(pairs, Pair{Left: Paragraph{PageIdx: right[j-1].PageIdx}, Right: right[j-1]})
+			j--
+		}
+	}
+	for a, b := 0, len(pairs)-1; a < b; a, b = a+1, b-1 {
+		pairs[a], pairs[b] = pairs[b], pairs[a]
+	}
+	return pairs
+}
+
+// sortedBigrams returns the distinct character bigrams of a normalized
+// text, each packed into an integer, in ascending order
+func sortedBigrams(normalized string) []uint64 {
+	runes := []rune(normalized)
+	if len(runes) < 2 {
+		return nil
+	}
+	grams := make([]uint64, 0, len(runes)-1)
+	for i := 0; i+1 < len(runes); i++ {
+		grams = append(grams, uint64(runes[i])<<32|uint64(runes[i+1]))
+	}
+	slices.Sort(grams)
+	return slices.Compact(grams)
+}
+
+// gramSimilarity is Normalizer.Similarity over bigrams from sortedBigrams
+func gramSimilarity(a, b string, gramsA, gramsB []uint64, norm *Normalizer) float64 {
+	if len(gramsA) == 0 || len(gramsB) == 0 {
+		// Texts too short for bigrams
+		return norm.Similarity(a, b)
+	}
+	intersection := 0
+	for x, y := 0, 0; x < len(gramsA) && y < len(gramsB); {
+		switch {
+		case gramsA[x] < gramsB[y]:
+			x++
+		case gramsA[x] > gramsB[y]:
+			y++
+		default:
+			intersection++
+			x, y = x+1, y+1
+		}
+	}
+	return float64(intersection) / float64(len(gramsA)+len(gramsB)-intersection)
+}
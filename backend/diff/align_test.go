@@ -0,0 +1,135 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNumberingPaths(t *testing.T) {
+	paragraphs := []Paragraph{
+		{Text: "第一章 总则"},
+		{Text: "1. 定义"},
+		{Text: "1.1本合同所称货物"},
+		{Text: "（一）原材料"},
+		{Text: "说明文字"},
+		{Text: "2. 适用范围"},
+		{Text: "第二章 付款"},
+		{Text: "1. 付款方式"},
+	}
+
+	var got []string
+	for _, path := range numberingPaths(paragraphs) {
+		var s string
+		for _, n := range path {
+			s += n.style + ":" + n.number + "/"
+		}
+		got = append(got, s)
+	}
+	expected := []string{
+		"chapter:1/",
+		"chapter:1/arabic:1/",
+		"chapter:1/arabic:1/arabic.:1.1/",
+		"chapter:1/arabic:1/arabic.:1.1/paren-chinese:1/",
+		"",
+		"chapter:1/arabic:2/",
+		"chapter:2/",
+		"chapter:2/arabic:1/",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected paths\n%v\ngot\n%v", expected, got)
+	}
+}
+
+func TestAlignParagraphsRepeatedNumbering(t *testing.T) {
+	left := []Paragraph{
+		{Text: "第一章 总则"},
+		{Text: "1. 本合同适用于甲乙双方之间的货物买卖。"},
+		{Text: "第二章 付款"},
+		{Text: "1. 甲方应于验收合格后三十日内付款。"},
+	}
+	// The first chapter lost its clause, so both remaining "1." clauses
+	// belong to chapter two
+	right := []Paragraph{
+		{Text: "第一章 总则"},
+		{Text: "第二章 付款"},
+		{Text: "1. 甲方应于验收合格后六十日内付款。"},
+	}
+
+	pairs := AlignParagraphs(left, right, DefaultNormalizer)
+	if len(pairs) != 4 {
+		t.Fatalf("Expected 4 pairs, got %d: %+v", len(pairs), pairs)
+	}
+	if pairs[1].IsMatch || pairs[1].Left.Text != left[1].Text {
+		t.Errorf("Expected chapter one's clause to be removed, got %+v", pairs[1])
+	}
+	last := pairs[3]
+	if !last.IsMatch || last.Left.Text != left[3].Text || last.Right.Text != right[2].Text {
+		t.Errorf("Expected the chapter two clauses to be paired, got %+v", last)
+	}
+	if last.MatchType != MatchNumber {
+		t.Errorf("Expected match type %q, got %q", MatchNumber, last.MatchType)
+	}
+
+	// Greedy matching pairs the first "1." it finds
+	greedy := MatchParagraphs(left, right, DefaultNormalizer)
+	for _, p := range greedy {
+		if p.Left.Text == left[1].Text && p.Right.Text == right[2].Text {
+			return
+		}
+	}
+	t.Error("Expected greedy matching to pair clauses of different chapters")
+}
+
+func TestAlignParagraphsQuality(t *testing.T) {
+	left := []Paragraph{
+		{Text: "本合同一式两份，双方各执一份。", PageIdx: 0},
+		{Text: "旧的条款内容完全不同", PageIdx: 1},
+		{Text: "本合同自双方签字盖章之日起生效。", PageIdx: 1},
+	}
+	right := []Paragraph{
+		{Text: "本合同一式两份，双方各执一份。", PageIdx: 0},
+		{Text: "新增加的附加条款", PageIdx: 1},
+		{Text: "本合同自双方签字之日起生效。", PageIdx: 1},
+	}
+
+	pairs := AlignParagraphs(left, right, DefaultNormalizer)
+	if len(pairs) != 4 {
+		t.Fatalf("Expected 4 pairs, got %d: %+v", len(pairs), pairs)
+	}
+	if pairs[0].Quality != 1 || pairs[0].MatchType != MatchSimilarity {
+		t.Errorf("Expected identical paragraphs to have quality 1, got %+v", pairs[0])
+	}
+	if pairs[1].IsMatch || pairs[1].Right.Text != "" || pairs[2].IsMatch || pairs[2].Left.Text != "" {
+		t.Errorf("Expected the dissimilar paragraphs to be removed and added, got %+v, %+v", pairs[1], pairs[2])
+	}
+	if pairs[1].Quality != 0 {
+		t.Errorf("Expected unmatched paragraphs to have quality 0, got %v", pairs[1].Quality)
+	}
+	if q := pairs[3].Quality; !pairs[3].IsMatch || q <= 0.4 || q >= 1 {
+		t.Errorf("Expected the edited paragraph to be paired with partial quality, got %+v", pairs[3])
+	}
+}
+
+func TestAlignParagraphsRenumbered(t *testing.T) {
+	left := []Paragraph{
+		{Text: "第三条 乙方应按照约定的时间和地点交付货物。"},
+	}
+	right := []Paragraph{
+		{Text: "第四条 乙方应按照约定的时间和地点交付货物。"},
+	}
+
+	pairs := AlignParagraphs(left, right, DefaultNormalizer)
+	if len(pairs) != 1 || !pairs[0].IsMatch || pairs[0].MatchType != MatchSimilarity {
+		t.Errorf("Expected the renumbered clause to be paired by similarity, got %+v", pairs)
+	}
+}
+
+func TestAlignParagraphsEmpty(t *testing.T) {
+	if pairs := AlignParagraphs(nil, nil, DefaultNormalizer); len(pairs) != 0 {
+		t.Errorf("Expected no pairs, got %+v", pairs)
+	}
+	pairs := AlignParagraphs(nil, []Paragraph{{Text: "新增条款", PageIdx: 2}}, DefaultNormalizer)
+	if len(pairs) != 1 || pairs[0].IsMatch || pairs[0].Left.PageIdx != 2 {
+		t.Errorf("Expected one added paragraph, got %+v", pairs)
+	}
+}
@@ -0,0 +1,52 @@
+package diff
+
+// traditionalChinese lists common traditional characters of contracts, and
+// simplifiedChinese their simplified forms at the same positions
+var (
+	traditionalChinese = "" +
+		"與為條約協議價錢貨幣內個們這來時後於從對發證書據權責務違賠償額數萬億圓塊費稅險擔" +
+		"買賣購銷貸債還賬戶銀號碼單審計會員經營業產檢驗運輸倉裝屆滿終義規範則項節標準記錄" +
+		"報說請訴訟爭調決執損補續變動轉讓託處辦開關係聯絡絕結紙張簽蓋圖樣檔將應當須該無爲" +
+		"兩雙幾歲歷曆週鐘點間過進達邊區縣鄉鎮樓廳電話郵網傳聲稱謂認識讀寫譯語詞誤確實際現" +
+		"狀況態勢響樂藝術車馬門問聞閱陽陰陸隊階隨難雜離鮮魚鳥專東絲嚴麗舉烏喬習亂虧雲亞畝" +
+		"親僅侖儀眾優傘偉傷倫偽體餘備憑劃劉剛創刪別劑剝劇勁勞勵勸勻華衛卻廠厲壓厭參敘臺葉" +
+		"嘆嚇嗎啟喪團園圍國聖場壞堅壇墊堯塗墳壯復夠頭奪獎奮婦媽孫學寧寶寵憲寬賓寢尋導爾塵" +
+		"嘗屍層屬岡島嶺帥師帳帶幫廣莊慶廬廟廢異棄彈強歸彥徹徑憶憂懷總戀懇惡惱愛慣慘慚懶戲" +
+		"戰撲擴掃揚擾撫拋搶護擬攏揀擁攔擰撥擇掛摯撈撿換搗擄擲撣攙擱摟攪攜攝擺搖擯攤攢撐攆" +
+		"擷擼攛斂斃斕鬥斬斷曠暢晝顯晉曬曉曄暈暉暫曖機殺楊榮構槍傑極棧欄樹橋橫櫃歡歐殘殼毀" +
+		"氣漢湯溝沒滬淚潑澤潔灑濁測濟瀏渾濃濤澇淺漿湧潤澀漲溫滅燈靈災爐煉爛煙煩燒熱爺牽犧" +
+		"獨獄獅獵豬貓獻環瑪璽瓊畫療瘋癢皚盞監盤盧睜瞞礦磚礎禮禍種積穩窮竊競筆築簡籃類糧緊" +
+		"紅純級紛組細給統綜綠維緒練編緣縮績織繳繼罰罷羅聰職聽肅脅脈腎腫腦腳膠臉臨舊艦蘇蘭" +
+		"蕭薦藥蟲蠶蠟衝襯襲覽覺視觀觸訂討訓許設評試詩誠詳誌課論諸謀講謝譜貝負財販貧貫貴貿" +
+		"資賊賞賦賢質賴贈贊趕趙躍踐軍軌軟較載輔輕輛輝輪辭遞遠適遷選遺邏鄰醫釋釐針鈔鋼錯鍵" +
+		"鎖鏈鑑長閉閑閒閣閘闡陣陳隱隸雞靜韓頁順預領頻題顧風飛飯飲飾養館驅驚髮鬆鬧魯鳳鴻麥" +
+		"黃齊齒龍龜"
+	simplifiedChinese = "" +
+		"与为条约协议价钱货币内个们这来时后于从对发证书据权责务违赔偿额数万亿圆块费税险担" +
+		"买卖购销贷债还账户银号码单审计会员经营业产检验运输仓装届满终义规范则项节标准记录" +
+		"报说请诉讼争调决执损补续变动转让托处办开关系联络绝结纸张签盖图样档将应当须该无为" +
+		"两双几岁历历周钟点间过进达边区县乡镇楼厅电话邮网传声称谓认识读写译语词误确实际现" +
+		"状况态势响乐艺术车马门问闻阅阳阴陆队阶随难杂离鲜鱼鸟专东丝严丽举乌乔习乱亏云亚亩" +
+		"亲仅仑仪众优伞伟伤伦伪体余备凭划刘刚创删别剂剥剧劲劳励劝匀华卫却厂厉压厌参叙台叶" +
+		"叹吓吗启丧团园围国圣场坏坚坛垫尧涂坟壮复够头夺奖奋妇妈孙学宁宝宠宪宽宾寝寻导尔尘" +
+		"尝尸层属冈岛岭帅师帐带帮广庄庆庐庙废异弃弹强归彦彻径忆忧怀总恋恳恶恼爱惯惨惭懒戏" +
+		"战扑扩扫扬扰抚抛抢护拟拢拣拥拦拧拨择挂挚捞捡换捣掳掷掸搀搁搂搅携摄摆摇摈摊攒撑撵" +
+		"撷撸撺敛毙斓斗斩断旷畅昼显晋晒晓晔晕晖暂暧机杀杨荣构枪杰极栈栏树桥横柜欢欧残壳毁" +
+		"气汉汤沟没沪泪泼泽洁洒浊测济浏浑浓涛涝浅浆涌润涩涨温灭灯灵灾炉炼烂烟烦烧热爷牵牺" +
+		"独狱狮猎猪猫献环玛玺琼画疗疯痒皑盏监盘卢睁瞒矿砖础礼祸种积稳穷窃竞笔筑简篮类粮紧" +
+		"红纯级纷组细给统综绿维绪练编缘缩绩织缴继罚罢罗聪职听肃胁脉肾肿脑脚胶脸临旧舰苏兰" +
+		"萧荐药虫蚕蜡冲衬袭览觉视观触订讨训许设评试诗诚详志课论诸谋讲谢谱贝负财贩贫贯贵贸" +
+		"资贼赏赋贤质赖赠赞赶赵跃践军轨软较载辅轻辆辉轮辞递远适迁选遗逻邻医释厘针钞钢错键" +
+		"锁链鉴长闭闲闲阁闸阐阵陈隐隶鸡静韩页顺预领频题顾风飞饭饮饰养馆驱惊发松闹鲁凤鸿麦" +
+		"黄齐齿龙龟"
+)
+
+// simplifiedForms maps a traditional character to its simplified form
+var simplifiedForms = func() map[rune]rune {
+	traditional, simplified := []rune(traditionalChinese), []rune(simplifiedChinese)
+	forms := make(map[rune]rune, len(traditional))
+	for i, r := range traditional {
+		forms[r] = simplified[i]
+	}
+	return forms
+}()
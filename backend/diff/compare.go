@@ -0,0 +1,369 @@
+package diff
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// SimilarityThreshold is the similarity above which MatchParagraphs pairs
+// two paragraphs that do not share a clause number. AlignParagraphs has no
+// such threshold; it pairs paragraphs whose alignment quality exceeds
+// 0.5 - GapPenalty.
+const SimilarityThreshold = 0.85
+
+// Pairing algorithms that Compare selects by name
+const (
+	AlgorithmGreedy = "greedy" // MatchParagraphs, as the web UI pairs paragraphs
+	AlgorithmAlign  = "align"  // AlignParagraphs, followed by DetectMoves
+)
+
+// DefaultAlgorithm is the pairing algorithm of comparisons that select
+// none, so that they pair paragraphs like the web UI
+const DefaultAlgorithm = AlgorithmGreedy
+
+// IsAlgorithm reports whether name is a pairing algorithm
+func IsAlgorithm(name string) bool {
+	return name == AlgorithmGreedy || name == AlgorithmAlign
+}
+
+// Diff operations, matching diff_match_patch
+const (
+	OpDelete = -1
+	OpEqual  = 0
+	OpInsert = 1
+)
+
+// Match types
+const (
+	MatchNumber     = "number"
+	MatchSimilarity = "similarity"
+)
+
+// Diff is a single character-level edit
+type Diff struct {
+	Op   int    `json:"op"`
+	Text string `json:"text"`
+}
+
+// Pair is a left/right paragraph pairing. An unmatched paragraph is paired
+// with an empty paragraph on the other side.
+type Pair struct {
+	Left       Paragraph `json:"left"`
+	Right      Paragraph `json:"right"`
+	Similarity float64   `json:"similarity"`
+	Quality    float64   `json:"quality"` // Alignment quality, 0 to 1, set by AlignParagraphs
+	IsMatch    bool      `json:"is_match"`
+	MatchType  string    `json:"match_type,omitempty"`
+}
+
+// PairDiff is a paragraph pair with its character-level diff
+type PairDiff struct {
+	Pair
+	Diffs   []Diff `json:"diffs"`
+	HasDiff bool   `json:"has_diff"`
+	Hunks   []Hunk `json:"hunks,omitempty"` // Set by ClassifyHunks
+}
+
+// Stats counts the added and removed hunks of a comparison
+type Stats struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Total   int `json:"total"`
+}
+
+// Change types
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+	ChangeMoved    = "moved"
+)
+
+// Location is the position of a paragraph in a contract
+type Location struct {
+	Clause  string `json:"clause,omitempty"`
+	PageIdx int    `json:"page_idx"`
+}
+
+// Change reports a changed pair by the clause it belongs to
+type Change struct {
+	Pair        int       `json:"pair"` // Index in Result.Pairs
+	Type        string    `json:"type"`
+	Clause      string    `json:"clause,omitempty"`
+	From        *Location `json:"from,omitempty"`       // Source of a move, in the left contract
+	To          *Location `json:"to,omitempty"`         // Destination of a move, in the right contract
+	Modified    bool      `json:"modified,omitempty"`   // A moved paragraph was edited as well
+	Categories  []string  `json:"categories,omitempty"` // Categories of the pair's hunks, e.g. amount
+	Description string    `json:"description"`          // e.g. 第十二条第（三）款 modified
+}
+
+// Result is the outcome of comparing two contracts
+type Result struct {
+	Pairs   []PairDiff `json:"pairs"`
+	Changes []Change   `json:"changes"`
+	Stats   Stats      `json:"stats"`
+}
+
+// Compare pairs the paragraphs of two contracts with algorithm, or
+// DefaultAlgorithm if it is not one, diffs each pair, tags the hunks by the
+// entities they change and lists the changes by clause. Differences that
+// norm ignores are not changes.
+func Compare(left, right []Paragraph, norm *Normalizer, algorithm string) *Result {
+	left, right = slices.Clone(left), slices.Clone(right)
+	AnnotateClauses(left)
+	AnnotateClauses(right)
+	var paired []Pair
+	if algorithm == AlgorithmAlign {
+		paired = DetectMoves(AlignParagraphs(left, right, norm), norm)
+	} else {
+		paired = MatchParagraphs(left, right, norm)
+	}
+	pairs := DiffPairs(paired, norm)
+	parties := append(DefinedParties(left), DefinedParties(right)...)
+	ClassifyHunks(pairs, NewEntityRecognizer(parties), norm)
+
+	var stats Stats
+	for _, p := range pairs {
+		for _, d := range p.Diffs {
+			switch d.Op {
+			case OpInsert:
+				stats.Added++
+			case OpDelete:
+				stats.Removed++
+			}
+		}
+	}
+	stats.Total = stats.Added + stats.Removed
+
+	return &Result{Pairs: pairs, Changes: listChanges(pairs, norm), Stats: stats}
+}
+
+// listChanges lists the added, removed and modified pairs. Added
+// paragraphs are cited by their clause in the right contract, the others
+// by their clause in the left one.
+func listChanges(pairs []PairDiff, norm *Normalizer) []Change {
+	changes := []Change{}
+	for i, p := range pairs {
+		var change Change
+		switch {
+		case p.MatchType == MatchMoved:
+			changes = append(changes, movedChange(i, p, norm))
+			continue
+		case !p.IsMatch && p.Left.Text == "":
+			change = Change{Type: ChangeAdded, Clause: p.Right.Clause}
+		case !p.IsMatch:
+			change = Change{Type: ChangeRemoved, Clause: p.Left.Clause}
+		case p.HasDiff:
+			change = Change{Type: ChangeModified, Clause: cmp.Or(p.Left.Clause, p.Right.Clause)}
+		default:
+			continue
+		}
+		change.Pair = i
+		change.Categories = hunkCategories(p)
+		if change.Clause != "" {
+			change.Description = change.Clause + " " + change.Type
+		} else {
+			change.Description = fmt.Sprintf("Paragraph on page %d %s", pairPage(p.Pair)+1, change.Type)
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// movedChange reports a moved pair with its source and destination. The
+// clause numbers are expected to change with the move, so only edits to
+// the rest of the text count as modifications.
+func movedChange(i int, p PairDiff, norm *Normalizer) Change {
+	from := &Location{Clause: p.Left.Clause, PageIdx: p.Left.PageIdx}
+	to := &Location{Clause: p.Right.Clause, PageIdx: p.Right.PageIdx}
+	change := Change{
+		Pair:     i,
+		Type:     ChangeMoved,
+		Clause:   cmp.Or(to.Clause, from.Clause),
+		From:     from,
+		To:       to,
+		Modified: norm.Normalize(clauseBody(p.Left.Text)) != norm.Normalize(clauseBody(p.Right.Text)),
+	}
+	if change.Modified {
+		change.Categories = hunkCategories(p)
+	}
+
+	describe := func(l *Location) string {
+		if l.Clause != "" {
+			return l.Clause
+		}
+		return fmt.Sprintf("page %d", l.PageIdx+1)
+	}
+	change.Description = fmt.Sprintf("%s moved to %s", describe(from), describe(to))
+	if from.Clause == "" {
+		change.Description = "Paragraph on " + change.Description
+	}
+	if change.Modified {
+		change.Description += " and modified"
+	}
+	return change
+}
+
+// MatchParagraphs pairs paragraphs greedily, first by clause number and
+// then by similarity above SimilarityThreshold, as the web UI does with
+// DefaultNormalizer. Texts are compared as normalized by norm.
+func MatchParagraphs(left, right []Paragraph, norm *Normalizer) []Pair {
+	matched1 := make([]bool, len(left))
+	matched2 := make([]bool, len(right))
+	var pairs []Pair
+
+	// First pass: match by clause number
+	for i := range left {
+		num1 := ExtractSectionNumber(left[i].Text)
+		if num1 == "" {
+			continue
+		}
+		normNum1 := NormalizeNumber(num1)
+
+		for j := range right {
+			if matched2[j] {
+				continue
+			}
+			num2 := ExtractSectionNumber(right[j].Text)
+			if num2 == "" {
+				continue
+			}
+			if normNum1 == NormalizeNumber(num2) {
+				matched1[i] = true
+				matched2[j] = true
+				pairs = append(pairs, Pair{
+					Left:       left[i],
+					Right:      right[j],
+					Similarity: norm.Similarity(left[i].Text, right[j].Text),
+					IsMatch:    true,
+					MatchType:  MatchNumber,
+				})
+				break
+			}
+		}
+	}
+
+	// Second pass: match the remaining paragraphs by similarity
+	for i := range left {
+		if matched1[i] {
+			continue
+		}
+
+		bestMatch := -1
+		bestScore := SimilarityThreshold
+		for j := range right {
+			if matched2[j] {
+				continue
+			}
+			if s := norm.Similarity(left[i].Text, right[j].Text); s > bestScore {
+				bestScore = s
+				bestMatch = j
+			}
+		}
+
+		if bestMatch != -1 {
+			matched1[i] = true
+			matched2[bestMatch] = true
+			pairs = append(pairs, Pair{
+				Left:       left[i],
+				Right:      right[bestMatch],
+				Similarity: bestScore,
+				IsMatch:    true,
+				MatchType:  MatchSimilarity,
+			})
+		}
+	}
+
+	// Unmatched left paragraphs were removed
+	for i := range left {
+		if !matched1[i] {
+			pairs = append(pairs, Pair{
+				Left:  left[i],
+				Right: Paragraph{PageIdx: left[i].PageIdx},
+			})
+		}
+	}
+
+	// Unmatched right paragraphs were added
+	for j := range right {
+		if !matched2[j] {
+			pairs = append(pairs, Pair{
+				Left:  Paragraph{PageIdx: right[j].PageIdx},
+				Right: right[j],
+			})
+		}
+	}
+
+	// Order by page
+	sort.SliceStable(pairs, func(a, b int) bool {
+		return pairPage(pairs[a]) < pairPage(pairs[b])
+	})
+
+	return pairs
+}
+
+func pairPage(p Pair) int {
+	return max(p.Left.PageIdx, p.Right.PageIdx, 0)
+}
+
+// DiffPairs computes the character diff of each pair. Pairs that only
+// differ in what norm ignores, e.g. whitespace, have no diff.
+func DiffPairs(pairs []Pair, norm *Normalizer) []PairDiff {
+	results := make([]PairDiff, 0, len(pairs))
+
+	for _, pair := range pairs {
+		if norm.NormalizeParagraph(pair.Left.Text) == norm.NormalizeParagraph(pair.Right.Text) {
+			text := pair.Left.Text
+			if text == "" {
+				text = pair.Right.Text
+			}
+			results = append(results, PairDiff{
+				Pair:  pair,
+				Diffs: []Diff{{Op: OpEqual, Text: text}},
+			})
+			continue
+		}
+
+		diffs := ComputeDiff(pair.Left.Text, pair.Right.Text)
+		leftNumber, rightNumber := norm.numberEnd(pair.Left.Text), norm.numberEnd(pair.Right.Text)
+		hasRealDiff := false
+		leftPos, rightPos := 0, 0
+		for _, d := range diffs {
+			switch d.Op {
+			case OpEqual:
+				leftPos += len(d.Text)
+				rightPos += len(d.Text)
+			case OpDelete:
+				hasRealDiff = hasRealDiff || norm.Normalize(pastNumber(d.Text, leftPos, leftNumber)) != ""
+				leftPos += len(d.Text)
+			case OpInsert:
+				hasRealDiff = hasRealDiff || norm.Normalize(pastNumber(d.Text, rightPos, rightNumber)) != ""
+				rightPos += len(d.Text)
+			}
+		}
+
+		results = append(results, PairDiff{
+			Pair:    pair,
+			Diffs:   diffs,
+			HasDiff: hasRealDiff,
+		})
+	}
+
+	return results
+}
+
+// ComputeDiff computes a semantically cleaned-up character diff of two texts
+func ComputeDiff(text1, text2 string) []Diff {
+	dmp := diffmatchpatch.New()
+	diffs := dmp.DiffCleanupSemantic(dmp.DiffMain(text1, text2, true))
+
+	result := make([]Diff, len(diffs))
+	for i, d := range diffs {
+		result[i] = Diff{Op: int(d.Type), Text: d.Text}
+	}
+	return result
+}
@@ -0,0 +1,131 @@
+package diff
+
+import "testing"
+
+func TestMatchParagraphsByNumber(t *testing.T) {
+	left := []Paragraph{
+		{Text: "第一条 甲方应于收货后付款。", PageIdx: 0},
+		{Text: "第二条 乙方负责运输。", PageIdx: 0},
+	}
+	right := []Paragraph{
+		{Text: "第二条 乙方负责运输及保险。", PageIdx: 0},
+		{Text: "第一条 甲方应于验收后付款。", PageIdx: 0},
+	}
+
+	pairs := MatchParagraphs(left, right, DefaultNormalizer)
+	if len(pairs) != 2 {
+		t.Fatalf("Expected 2 pairs, got %d", len(pairs))
+	}
+	for _, p := range pairs {
+		if p.MatchType != MatchNumber {
+			t.Errorf("Expected match type %q, got %q", MatchNumber, p.MatchType)
+		}
+		if ExtractSectionNumber(p.Left.Text) != ExtractSectionNumber(p.Right.Text) {
+			t.Errorf("Mismatched pair: %q / %q", p.Left.Text, p.Right.Text)
+		}
+	}
+}
+
+func TestMatchParagraphsUnmatched(t *testing.T) {
+	left := []Paragraph{
+		{Text: "本合同一式两份，双方各执一份。", PageIdx: 0},
+		{Text: "旧的条款内容完全不同", PageIdx: 1},
+	}
+	right := []Paragraph{
+		{Text: "本合同一式两份，双方各执一份。", PageIdx: 0},
+		{Text: "新增加的附加条款", PageIdx: 2},
+	}
+
+	pairs := MatchParagraphs(left, right, DefaultNormalizer)
+	if len(pairs) != 3 {
+		t.Fatalf("Expected 3 pairs, got %d", len(pairs))
+	}
+	if pairs[0].MatchType != MatchSimilarity || pairs[0].Similarity != 1.0 {
+		t.Errorf("Expected identical paragraphs to match by similarity, got %+v", pairs[0])
+	}
+	if pairs[1].Right.Text != "" || pairs[1].Right.PageIdx != 1 {
+		t.Errorf("Expected removed paragraph second, got %+v", pairs[1])
+	}
+	if pairs[2].Left.Text != "" || pairs[2].Right.PageIdx != 2 {
+		t.Errorf("Expected added paragraph last, got %+v", pairs[2])
+	}
+}
+
+func TestCompare(t *testing.T) {
+	left := []Paragraph{
+		{Text: "第一条 货款为人民币10000元。"},
+		{Text: "第二条 双方签字后生效。"},
+	}
+	right := []Paragraph{
+		{Text: "第一条 货款为人民币12000元。"},
+		{Text: "第二条 双方 签字后生效."},
+	}
+
+	result := Compare(left, right, DefaultNormalizer, DefaultAlgorithm)
+	if len(result.Pairs) != 2 {
+		t.Fatalf("Expected 2 pairs, got %d", len(result.Pairs))
+	}
+
+	first := result.Pairs[0]
+	if !first.HasDiff {
+		t.Error("Expected first pair to have a diff")
+	}
+	if result.Stats.Added != 1 || result.Stats.Removed != 1 || result.Stats.Total != 2 {
+		t.Errorf("Unexpected stats: %+v", result.Stats)
+	}
+
+	second := result.Pairs[1]
+	if second.HasDiff {
+		t.Error("Expected whitespace and punctuation width change to have no diff")
+	}
+	if len(second.Diffs) != 1 || second.Diffs[0].Op != OpEqual {
+		t.Errorf("Expected a single equal diff, got %+v", second.Diffs)
+	}
+}
+
+func TestCompareAlgorithms(t *testing.T) {
+	left := []Paragraph{
+		{Text: "第一章 总则"},
+		{Text: "1. 本合同适用于甲乙双方之间的货物买卖。"},
+		{Text: "第二章 付款"},
+		{Text: "1. 甲方应于验收合格后三十日内付款。"},
+	}
+	right := []Paragraph{
+		{Text: "第一章 总则"},
+		{Text: "第二章 付款"},
+		{Text: "1. 甲方应于验收合格后六十日内付款。"},
+	}
+
+	pairedWith := func(result *Result, text string) string {
+		for _, p := range result.Pairs {
+			if p.Left.Text == text {
+				return p.Right.Text
+			}
+		}
+		return ""
+	}
+	// The default pairs like the web UI, taking the first "1." it finds
+	if got := pairedWith(Compare(left, right, DefaultNormalizer, ""), left[1].Text); got != right[2].Text {
+		t.Errorf("Expected greedy pairing of chapter one's clause, got %q", got)
+	}
+	if got := pairedWith(Compare(left, right, DefaultNormalizer, AlgorithmAlign), left[3].Text); got != right[2].Text {
+		t.Errorf("Expected aligned pairing of chapter two's clause, got %q", got)
+	}
+}
+
+func TestComputeDiff(t *testing.T) {
+	diffs := ComputeDiff("付款期限为三十日", "付款期限为六十日")
+
+	var deleted, inserted string
+	for _, d := range diffs {
+		switch d.Op {
+		case OpDelete:
+			deleted += d.Text
+		case OpInsert:
+			inserted += d.Text
+		}
+	}
+	if deleted != "三" || inserted != "六" {
+		t.Errorf("Expected 三 -> 六, got %q -> %q", deleted, inserted)
+	}
+}
@@ -0,0 +1,315 @@
+package diff
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Change categories of hunks, by the entity they change
+const (
+	CategoryAmount     = "amount"
+	CategoryDate       = "date"
+	CategoryDuration   = "duration"
+	CategoryPercentage = "percentage"
+	CategoryParty      = "party"
+	CategoryText       = "text" // No recognized entity
+)
+
+// Entity is a value recognized in a text, located by byte offsets
+type Entity struct {
+	Category string
+	Value    string // Normalized, e.g. CNY 12000.00, 2024-03-01, 30 days, 5%
+	Start    int
+	End      int
+}
+
+const cnDigits = `零〇一二两三四五六七八九十百千万壹贰叁肆伍陆柒捌玖拾佰仟萬`
+
+var (
+	amountPattern = regexp.MustCompile(`(?i)(人民币|RMB|CNY|USD|US\$|美元|¥|￥|\$)?\s*(\d{1,3}(?:,\d{3})+(?:\.\d+)?|\d+(?:\.\d+)?)\s*(万元|万美元|美元|元|万)?`)
+	// 大写 amounts such as 壹万贰仟元整 or 叁佰伍拾元伍角
+	chineseAmountPattern = regexp.MustCompile(`(人民币|美元)?\s*([` + cnDigits + `]+)[圆元](?:零?([` + cnDigits + `])角)?(?:零?([` + cnDigits + `])分)?[整正]?`)
+
+	datePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(\d{4})\s*年\s*(\d{1,2})\s*月(?:\s*(\d{1,2})\s*[日号])?`),
+		regexp.MustCompile(`([零〇一二三四五六七八九]{4})年([一二三四五六七八九十]{1,3})月(?:([一二三四五六七八九十]{1,3})[日号])?`),
+		regexp.MustCompile(`(\d{4})[-/.](\d{1,2})[-/.](\d{1,2})`),
+	}
+
+	durationPattern = regexp.MustCompile(`(\d+|[` + cnDigits + `]+)\s*个?\s*(工作日|自然日|日历日|天|日|周|星期|月|年|小时)`)
+
+	percentPatterns = []struct {
+		pattern *regexp.Regexp
+		scale   float64 // Multiplier to percent
+	}{
+		{regexp.MustCompile(`(\d+(?:\.\d+)?)\s*[%％]`), 1},
+		{regexp.MustCompile(`百分之([\d.]+|[` + cnDigits + `点]+)`), 1},
+		{regexp.MustCompile(`千分之([\d.]+|[` + cnDigits + `点]+)`), 0.1},
+		{regexp.MustCompile(`万分之([\d.]+|[` + cnDigits + `点]+)`), 0.01},
+	}
+
+	durationUnits = map[string]string{
+		"工作日": "working days",
+		"自然日": "days",
+		"日历日": "days",
+		"天":   "days",
+		"日":   "days",
+		"周":   "weeks",
+		"星期":  "weeks",
+		"月":   "months",
+		"年":   "years",
+		"小时":  "hours",
+	}
+
+	partyRoles = `甲方|乙方|丙方|丁方|买方|卖方|出卖人|买受人|出租方|承租方|出租人|承租人|委托方|受托方|委托人|受托人|发包方|承包方|发包人|承包人|供方|需方|贷款人|借款人|保证人`
+	// 甲方（买方）：北京某某科技有限公司
+	partyDeclarationPattern = regexp.MustCompile(`^(` + partyRoles + `)\s*(?:[（(][^）)]*[）)])?\s*[:：]\s*([^\s，,；;。]+)`)
+	// 北京某某科技有限公司（以下简称“甲方”）
+	partyAliasPattern = regexp.MustCompile(`([^\s，,；;。：:（(“"]{2,40}?)\s*[（(]以下简称[：:]?\s*["“「]?([^"”」）)]+?)["”」]?[）)]`)
+	// ABC Trading Co., Ltd. (the "Buyer")
+	englishPartyPattern = regexp.MustCompile(`([A-Z][\w&.,\- ]*?(?:Ltd\.?|Limited|Inc\.?|LLC|Corporation|Corp\.?|GmbH|PLC))\s*\((?:the\s+)?["“]([^"”]+)["”]\)`)
+)
+
+// Party is a contracting party defined in a contract
+type Party struct {
+	Role string `json:"role"` // e.g. 甲方 or Buyer
+	Name string `json:"name"`
+}
+
+// DefinedParties returns the parties a contract defines, e.g. by
+// "甲方：某某有限公司" or "某某有限公司（以下简称“甲方”）"
+func DefinedParties(paragraphs []Paragraph) []Party {
+	var parties []Party
+	seen := make(map[Party]bool)
+	add := func(role, name string) {
+		p := Party{Role: trimSpace(role), Name: trimSpace(name)}
+		if p.Role == "" || p.Name == "" || seen[p] {
+			return
+		}
+		seen[p] = true
+		parties = append(parties, p)
+	}
+
+	for _, p := range paragraphs {
+		text := trimSpace(p.Text)
+		if m := partyDeclarationPattern.FindStringSubmatch(text); m != nil {
+			add(m[1], m[2])
+		}
+		for _, m := range partyAliasPattern.FindAllStringSubmatch(text, -1) {
+			add(m[2], m[1])
+		}
+		for _, m := range englishPartyPattern.FindAllStringSubmatch(text, -1) {
+			add(m[2], m[1])
+		}
+	}
+	return parties
+}
+
+// EntityRecognizer finds amounts, dates, durations, percentages and the
+// names and roles of defined parties in texts
+type EntityRecognizer struct {
+	partyTerms []string // Longest first, so names win over their prefixes
+}
+
+func NewEntityRecognizer(parties []Party) *EntityRecognizer {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, p := range parties {
+		for _, term := range []string{p.Name, p.Role} {
+			if !seen[term] {
+				seen[term] = true
+				terms = append(terms, term)
+			}
+		}
+	}
+	slices.SortStableFunc(terms, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+	return &EntityRecognizer{partyTerms: terms}
+}
+
+// Find returns the entities of a text ordered by position. Entities do not
+// overlap; amounts take precedence over dates, then percentages, durations
+// and parties.
+func (r *EntityRecognizer) Find(text string) []Entity {
+	var entities []Entity
+	add := func(category, value string, start, end int) {
+		for _, e := range entities {
+			if start < e.End && e.Start < end {
+				return
+			}
+		}
+		entities = append(entities, Entity{Category: category, Value: value, Start: start, End: end})
+	}
+
+	for _, m := range chineseAmountPattern.FindAllStringSubmatchIndex(text, -1) {
+		if value, ok := chineseAmountValue(text, m); ok {
+			add(CategoryAmount, value, m[0], m[1])
+		}
+	}
+	for _, m := range amountPattern.FindAllStringSubmatchIndex(text, -1) {
+		if value, ok := amountValue(text, m); ok {
+			add(CategoryAmount, value, m[0], m[1])
+		}
+	}
+	for _, pattern := range datePatterns {
+		for _, m := range pattern.FindAllStringSubmatchIndex(text, -1) {
+			if value, ok := dateValue(text, m); ok {
+				add(CategoryDate, value, m[0], m[1])
+			}
+		}
+	}
+	for _, p := range percentPatterns {
+		for _, m := range p.pattern.FindAllStringSubmatchIndex(text, -1) {
+			if n, ok := parseDecimal(text[m[2]:m[3]]); ok {
+				add(CategoryPercentage, strconv.FormatFloat(n*p.scale, 'f', -1, 64)+"%", m[0], m[1])
+			}
+		}
+	}
+	for _, m := range durationPattern.FindAllStringSubmatchIndex(text, -1) {
+		if value, ok := durationValue(text, m); ok {
+			add(CategoryDuration, value, m[0], m[1])
+		}
+	}
+	for _, term := range r.partyTerms {
+		for offset := 0; ; {
+			i := strings.Index(text[offset:], term)
+			if i < 0 {
+				break
+			}
+			start := offset + i
+			add(CategoryParty, term, start, start+len(term))
+			offset = start + len(term)
+		}
+	}
+
+	slices.SortFunc(entities, func(a, b Entity) int { return cmp.Compare(a.Start, b.Start) })
+	return entities
+}
+
+// submatch returns the text of submatch n of a FindStringSubmatchIndex
+// result, or "" if it did not participate
+func submatch(text string, m []int, n int) string {
+	if m[2*n] < 0 {
+		return ""
+	}
+	return text[m[2*n]:m[2*n+1]]
+}
+
+func amountValue(text string, m []int) (string, bool) {
+	prefix, digits, unit := submatch(text, m, 1), submatch(text, m, 2), submatch(text, m, 3)
+	// A bare number, or 万 without a currency, is not an amount
+	if prefix == "" && (unit == "" || unit == "万") {
+		return "", false
+	}
+	n, err := strconv.ParseFloat(strings.ReplaceAll(digits, ",", ""), 64)
+	if err != nil {
+		return "", false
+	}
+	if strings.HasPrefix(unit, "万") {
+		n *= 10000
+	}
+	currency := "CNY"
+	switch strings.ToUpper(prefix) {
+	case "USD", "US$", "$", "美元":
+		currency = "USD"
+	}
+	if strings.Contains(unit, "美元") {
+		currency = "USD"
+	}
+	return fmt.Sprintf("%s %.2f", currency, n), true
+}
+
+func chineseAmountValue(text string, m []int) (string, bool) {
+	numeral := submatch(text, m, 2)
+	// The 万 of 12万元 is a unit of the Arabic amount
+	if first := []rune(numeral)[0]; chineseMyriads[first] > 0 || chineseUnits[first] > 10 {
+		return "", false
+	}
+	n, ok := ParseChineseNumeral(numeral)
+	if !ok {
+		return "", false
+	}
+	value := float64(n)
+	if jiao := submatch(text, m, 3); jiao != "" {
+		d, _ := ParseChineseNumeral(jiao)
+		value += float64(d) / 10
+	}
+	if fen := submatch(text, m, 4); fen != "" {
+		d, _ := ParseChineseNumeral(fen)
+		value += float64(d) / 100
+	}
+	currency := "CNY"
+	if submatch(text, m, 1) == "美元" {
+		currency = "USD"
+	}
+	return fmt.Sprintf("%s %.2f", currency, value), true
+}
+
+func dateValue(text string, m []int) (string, bool) {
+	parts := make([]int, 0, 3)
+	for n := 1; n <= 3; n++ {
+		s := submatch(text, m, n)
+		if s == "" {
+			break
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			var ok bool
+			if v, ok = ParseChineseNumeral(s); !ok {
+				return "", false
+			}
+		}
+		parts = append(parts, v)
+	}
+	if len(parts) < 2 || parts[1] < 1 || parts[1] > 12 {
+		return "", false
+	}
+	if len(parts) == 2 {
+		return fmt.Sprintf("%04d-%02d", parts[0], parts[1]), true
+	}
+	if parts[2] < 1 || parts[2] > 31 {
+		return "", false
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", parts[0], parts[1], parts[2]), true
+}
+
+func durationValue(text string, m []int) (string, bool) {
+	number, unit := submatch(text, m, 1), submatch(text, m, 2)
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		var ok bool
+		if n, ok = ParseChineseNumeral(number); !ok {
+			return "", false
+		}
+	} else if unit == "年" && len(number) == 4 {
+		// 2024年 is a year, not a duration
+		return "", false
+	}
+	return fmt.Sprintf("%d %s", n, durationUnits[unit]), true
+}
+
+// parseDecimal parses Arabic decimals and Chinese ones such as 零点五
+func parseDecimal(s string) (float64, bool) {
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return n, true
+	}
+	integer, fraction, _ := strings.Cut(s, "点")
+	n, ok := ParseChineseNumeral(integer)
+	if !ok {
+		return 0, false
+	}
+	value := float64(n)
+	scale := 0.1
+	for _, r := range fraction {
+		d, ok := chineseDigits[r]
+		if !ok {
+			return 0, false
+		}
+		value += float64(d) * scale
+		scale /= 10
+	}
+	return value, true
+}
@@ -0,0 +1,181 @@
+package diff
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestEntityRecognizerFind(t *testing.T) {
+	recognizer := NewEntityRecognizer([]Party{{Role: "甲方", Name: "北京星河科技有限公司"}})
+	tests := []struct {
+		text     string
+		category string
+		value    string
+	}{
+		{"合同总价为人民币120,000元", CategoryAmount, "CNY 120000.00"},
+		{"合同总价为12万元", CategoryAmount, "CNY 120000.00"},
+		{"大写：壹拾贰万元整", CategoryAmount, "CNY 120000.00"},
+		{"叁佰伍拾元伍角", CategoryAmount, "CNY 350.50"},
+		{"USD 1,500.5", CategoryAmount, "USD 1500.50"},
+		{"5000美元", CategoryAmount, "USD 5000.00"},
+		{"于2024年3月1日前", CategoryDate, "2024-03-01"},
+		{"二〇二四年三月十五日", CategoryDate, "2024-03-15"},
+		{"自2024-03-01起", CategoryDate, "2024-03-01"},
+		{"2024年12月", CategoryDate, "2024-12"},
+		{"三十日内", CategoryDuration, "30 days"},
+		{"10个工作日内", CategoryDuration, "10 working days"},
+		{"保修期为两年", CategoryDuration, "2 years"},
+		{"三个月", CategoryDuration, "3 months"},
+		{"违约金为5%", CategoryPercentage, "5%"},
+		{"百分之二十", CategoryPercentage, "20%"},
+		{"每日万分之五", CategoryPercentage, "0.05%"},
+		{"百分之零点五", CategoryPercentage, "0.5%"},
+		{"北京星河科技有限公司应", CategoryParty, "北京星河科技有限公司"},
+		{"由甲方承担", CategoryParty, "甲方"},
+	}
+	for _, tt := range tests {
+		entities := recognizer.Find(tt.text)
+		if len(entities) != 1 {
+			t.Errorf("Find(%q): expected 1 entity, got %+v", tt.text, entities)
+			continue
+		}
+		if e := entities[0]; e.Category != tt.category || e.Value != tt.value {
+			t.Errorf("Find(%q) = %s %q, expected %s %q", tt.text, e.Category, e.Value, tt.category, tt.value)
+		}
+	}
+}
+
+func TestEntityRecognizerFindIgnoresPlainNumbers(t *testing.T) {
+	recognizer := NewEntityRecognizer(nil)
+	for _, text := range []string{"共3份", "本合同一式两份", "2024年度预算"} {
+		if entities := recognizer.Find(text); len(entities) != 0 {
+			t.Errorf("Find(%q): expected no entities, got %+v", text, entities)
+		}
+	}
+}
+
+func TestDefinedParties(t *testing.T) {
+	paragraphs := []Paragraph{
+		{Text: "甲方（买方）：北京星河科技有限公司"},
+		{Text: "上海远航贸易有限公司（以下简称“乙方”）"},
+		{Text: "ABC Trading Co., Ltd. (the \"Seller\") agrees to sell"},
+	}
+	expected := []Party{
+		{Role: "甲方", Name: "北京星河科技有限公司"},
+		{Role: "乙方", Name: "上海远航贸易有限公司"},
+		{Role: "Seller", Name: "ABC Trading Co., Ltd."},
+	}
+	if parties := DefinedParties(paragraphs); !reflect.DeepEqual(parties, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, parties)
+	}
+}
+
+func TestCompareClassifiesHunks(t *testing.T) {
+	left := []Paragraph{
+		{Text: "甲方：北京星河科技有限公司"},
+		{Text: "第一条 合同总价为人民币100,000元，甲方应于2024年3月1日前支付。"},
+		{Text: "第二条 乙方应在收到货款后三十日内交货，逾期按日支付合同总价5%的违约金。"},
+		{Text: "第三条 本合同未尽事宜由双方另行协商解决，协商不成的提交仲裁。"},
+	}
+	right := []Paragraph{
+		{Text: "甲方：北京星河科技有限公司"},
+		{Text: "第一条 合同总价为人民币120,000元，甲方应于2024年6月1日前支付。"},
+		{Text: "第二条 乙方应在收到货款后六十日内交货，逾期按日支付合同总价3%的违约金。"},
+		{Text: "第三条 本合同未尽事宜由双方另行友好协商解决，协商不成的提交仲裁。"},
+	}
+
+	result := Compare(left, right, DefaultNormalizer, DefaultAlgorithm)
+	var hunks []Hunk
+	for _, p := range result.Pairs {
+		hunks = append(hunks, p.Hunks...)
+	}
+	expected := []struct{ category, old, new string }{
+		{CategoryAmount, "CNY 100000.00", "CNY 120000.00"},
+		{CategoryDate, "2024-03-01", "2024-06-01"},
+		{CategoryDuration, "30 days", "60 days"},
+		{CategoryPercentage, "5%", "3%"},
+		{CategoryText, "", ""},
+	}
+	if len(hunks) != len(expected) {
+		t.Fatalf("Expected %d hunks, got %+v", len(expected), hunks)
+	}
+	for i, e := range expected {
+		h := hunks[i]
+		if !slices.Equal(h.Categories, []string{e.category}) || h.OldValue != e.old || h.NewValue != e.new {
+			t.Errorf("Hunk %d: expected %s %q → %q, got %v %q → %q", i, e.category, e.old, e.new, h.Categories, h.OldValue, h.NewValue)
+		}
+	}
+
+	var categories [][]string
+	for _, c := range result.Changes {
+		categories = append(categories, c.Categories)
+	}
+	expectedCategories := [][]string{
+		{CategoryAmount, CategoryDate},
+		{CategoryDuration, CategoryPercentage},
+		{CategoryText},
+	}
+	if !reflect.DeepEqual(categories, expectedCategories) {
+		t.Errorf("Expected change categories %v, got %v", expectedCategories, categories)
+	}
+}
+
+func TestCompareClassifiesPartyChange(t *testing.T) {
+	left := []Paragraph{
+		{Text: "北京星河科技有限公司（以下简称“甲方”）"},
+		{Text: "上海远航贸易有限公司（以下简称“乙方”）"},
+		{Text: "第五条 运输费用由甲方承担，保险费用由双方平均分摊。"},
+	}
+	right := []Paragraph{
+		{Text: "北京星河科技有限公司（以下简称“甲方”）"},
+		{Text: "上海远航贸易有限公司（以下简称“乙方”）"},
+		{Text: "第五条 运输费用由乙方承担，保险费用由双方平均分摊。"},
+	}
+
+	result := Compare(left, right, DefaultNormalizer, DefaultAlgorithm)
+	var hunks []Hunk
+	for _, p := range result.Pairs {
+		if p.MatchType == MatchNumber {
+			hunks = p.Hunks
+		}
+	}
+	if len(hunks) != 1 {
+		t.Fatalf("Expected 1 hunk, got %+v", hunks)
+	}
+	if h := hunks[0]; !slices.Equal(h.Categories, []string{CategoryParty}) || h.OldValue != "甲方" || h.NewValue != "乙方" {
+		t.Errorf("Expected party 甲方 → 乙方, got %v %q → %q", h.Categories, h.OldValue, h.NewValue)
+	}
+}
+
+func TestCompareClassifiesAddedAndRemovedParagraphs(t *testing.T) {
+	left := []Paragraph{
+		{Text: "第一条 甲方应于验收合格后三十日内付款。"},
+		{Text: "第二条 逾期付款的，按日支付应付金额0.05%的违约金。"},
+	}
+	right := []Paragraph{
+		{Text: "第一条 甲方应于验收合格后三十日内付款。"},
+		{Text: "第三条 乙方应于2024年6月1日前交付人民币50,000元的保证金。"},
+	}
+
+	result := Compare(left, right, DefaultNormalizer, DefaultAlgorithm)
+	byType := map[string]Change{}
+	for _, c := range result.Changes {
+		byType[c.Type] = c
+	}
+	if c := byType[ChangeRemoved]; !slices.Equal(c.Categories, []string{CategoryPercentage}) {
+		t.Errorf("Expected the removed clause to change a percentage, got %+v", c)
+	}
+	added, ok := byType[ChangeAdded]
+	if !ok {
+		t.Fatalf("Expected an added clause, got %+v", result.Changes)
+	}
+	hunks := result.Pairs[added.Pair].Hunks
+	if len(hunks) != 1 {
+		t.Fatalf("Expected 1 hunk, got %+v", hunks)
+	}
+	// A hunk lists every category it touches
+	if h := hunks[0]; !slices.Equal(h.Categories, []string{CategoryDate, CategoryAmount}) || h.OldValue != "" || h.NewValue != "2024-06-01; CNY 50000.00" {
+		t.Errorf("Expected a new date and amount, got %v %q → %q", h.Categories, h.OldValue, h.NewValue)
+	}
+}
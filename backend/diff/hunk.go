@@ -0,0 +1,130 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+)
+
+// Hunk is a run of consecutive edits in a pair's diff, tagged with the
+// categories of the entities it changes
+type Hunk struct {
+	Start      int      `json:"start"` // Index of the first edit in PairDiff.Diffs
+	End        int      `json:"end"`   // Index after the last edit
+	Categories []string `json:"categories"`
+	Old        string   `json:"old,omitempty"`       // Deleted text
+	New        string   `json:"new,omitempty"`       // Inserted text
+	OldValue   string   `json:"old_value,omitempty"` // Normalized entity values before the change, e.g. CNY 10000.00
+	NewValue   string   `json:"new_value,omitempty"` // Normalized entity values after the change
+}
+
+// span is a byte range of a text
+type span struct {
+	start, end int
+}
+
+// overlaps reports whether an entity lies in the span. An empty span is an
+// insertion point, which only touches entities around it.
+func (s span) overlaps(e Entity) bool {
+	if s.start == s.end {
+		return e.Start < s.start && s.start < e.End
+	}
+	return e.Start < s.end && s.start < e.End
+}
+
+// ClassifyHunks splits the diffs of changed pairs into hunks and tags each
+// hunk with the entities it changes, comparing the normalized values of the
+// entities on both sides. An added or removed paragraph is a single hunk
+// with entities on one side only. Edits that touch no entity are
+// CategoryText, and hunks that norm ignores, such as whitespace, are
+// skipped.
+func ClassifyHunks(pairs []PairDiff, recognizer *EntityRecognizer, norm *Normalizer) {
+	for i := range pairs {
+		p := &pairs[i]
+		if !p.HasDiff {
+			continue
+		}
+		leftEntities := recognizer.Find(p.Left.Text)
+		rightEntities := recognizer.Find(p.Right.Text)
+		leftNumber, rightNumber := norm.numberEnd(p.Left.Text), norm.numberEnd(p.Right.Text)
+
+		leftPos, rightPos := 0, 0
+		for k := 0; k < len(p.Diffs); {
+			if p.Diffs[k].Op == OpEqual {
+				leftPos += len(p.Diffs[k].Text)
+				rightPos += len(p.Diffs[k].Text)
+				k++
+				continue
+			}
+
+			hunk := Hunk{Start: k}
+			left, right := span{start: leftPos}, span{start: rightPos}
+			var deleted, inserted strings.Builder
+			for ; k < len(p.Diffs) && p.Diffs[k].Op != OpEqual; k++ {
+				d := p.Diffs[k]
+				if d.Op == OpDelete {
+					deleted.WriteString(d.Text)
+					leftPos += len(d.Text)
+				} else {
+					inserted.WriteString(d.Text)
+					rightPos += len(d.Text)
+				}
+			}
+			left.end, right.end = leftPos, rightPos
+			hunk.End = k
+			hunk.Old, hunk.New = deleted.String(), inserted.String()
+			if norm.Normalize(pastNumber(hunk.Old, left.start, leftNumber)) == norm.Normalize(pastNumber(hunk.New, right.start, rightNumber)) {
+				continue
+			}
+
+			classifyHunk(&hunk, entitiesIn(leftEntities, left), entitiesIn(rightEntities, right))
+			p.Hunks = append(p.Hunks, hunk)
+		}
+	}
+}
+
+func entitiesIn(entities []Entity, s span) []Entity {
+	var result []Entity
+	for _, e := range entities {
+		if s.overlaps(e) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// classifyHunk takes the distinct categories of the entities the hunk
+// touches, left side first, and lists the values of those entities
+func classifyHunk(hunk *Hunk, left, right []Entity) {
+	for _, e := range slices.Concat(left, right) {
+		if !slices.Contains(hunk.Categories, e.Category) {
+			hunk.Categories = append(hunk.Categories, e.Category)
+		}
+	}
+	if len(hunk.Categories) == 0 {
+		hunk.Categories = []string{CategoryText}
+		return
+	}
+
+	values := func(entities []Entity) string {
+		result := make([]string, len(entities))
+		for k, e := range entities {
+			result[k] = e.Value
+		}
+		return strings.Join(result, "; ")
+	}
+	hunk.OldValue, hunk.NewValue = values(left), values(right)
+}
+
+// hunkCategories returns the distinct categories of a pair's hunks, in
+// order of appearance
+func hunkCategories(p PairDiff) []string {
+	var categories []string
+	for _, h := range p.Hunks {
+		for _, c := range h.Categories {
+			if !slices.Contains(categories, c) {
+				categories = append(categories, c)
+			}
+		}
+	}
+	return categories
+}
@@ -0,0 +1,140 @@
+package diff
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// MoveThreshold is the minimum similarity of two paragraphs, ignoring
+// their clause numbers, for a removed paragraph and an added one to be
+// reported as a move
+const MoveThreshold = 0.8
+
+// minMoveLength is the length in runes below which paragraphs are too
+// short to tell a move from a coincidence
+const minMoveLength = 8
+
+// MatchMoved is the match type of a paragraph moved to another position
+const MatchMoved = "moved"
+
+// clauseBody returns the text of a paragraph after its clause number
+func clauseBody(text string) string {
+	return trimSpace(text[clauseNumberEnd(text):])
+}
+
+// clauseNumberEnd returns the byte offset in a paragraph where the text
+// after its leading clause number starts, or 0 if it has none
+func clauseNumberEnd(text string) int {
+	start := len(text) - len(strings.TrimLeftFunc(text, isJSSpace))
+	for _, s := range numberingStyles {
+		m := s.pattern.FindStringSubmatchIndex(text[start:])
+		if m == nil {
+			continue
+		}
+		end := m[1]
+		if k := s.pattern.SubexpIndex("next"); k > 0 && m[2*k] >= 0 {
+			end = m[2*k]
+		}
+		return len(text) - len(strings.TrimLeftFunc(text[start+end:], isJSSpace))
+	}
+	return 0
+}
+
+// DetectMoves pairs paragraphs that alignment left unmatched, or matched
+// poorly, with a highly similar paragraph elsewhere, e.g. a clause moved
+// from 第八条 to 第十五条. A moved pair takes the position of its right
+// paragraph, and the paragraphs it leaves behind become removed or added.
+func DetectMoves(pairs []Pair, norm *Normalizer) []Pair {
+	type candidate struct {
+		src, dst   int // Positions of the pairs holding the left and right paragraph
+		similarity float64
+	}
+
+	type body struct {
+		pos   int
+		text  string
+		grams []uint64
+	}
+	// bodies returns the texts, without clause numbers, of the paragraphs
+	// that may have moved
+	bodies := func(side func(Pair) Paragraph) []body {
+		var result []body
+		for k, pair := range pairs {
+			p := side(pair)
+			if p.Text == "" || (pair.IsMatch && pair.Similarity >= MoveThreshold) {
+				continue
+			}
+			text := clauseBody(p.Text)
+			if utf8.RuneCountInString(norm.Normalize(text)) < minMoveLength {
+				continue
+			}
+			result = append(result, body{pos: k, text: text, grams: sortedBigrams(norm.Normalize(text))})
+		}
+		return result
+	}
+	sources := bodies(func(p Pair) Paragraph { return p.Left })
+	destinations := bodies(func(p Pair) Paragraph { return p.Right })
+
+	var candidates []candidate
+	for _, from := range sources {
+		for _, to := range destinations {
+			if from.pos == to.pos {
+				continue
+			}
+			if s := gramSimilarity(from.text, to.text, from.grams, to.grams, norm); s >= MoveThreshold {
+				candidates = append(candidates, candidate{src: from.pos, dst: to.pos, similarity: s})
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return pairs
+	}
+
+	// Take the most similar moves first
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		return cmp.Compare(b.similarity, a.similarity)
+	})
+	movedFrom := make(map[int]bool)    // Pairs whose left paragraph moved away
+	movedTo := make(map[int]candidate) // Pairs whose right paragraph is a move destination
+	for _, c := range candidates {
+		if movedFrom[c.src] {
+			continue
+		}
+		if _, ok := movedTo[c.dst]; ok {
+			continue
+		}
+		movedFrom[c.src] = true
+		movedTo[c.dst] = c
+	}
+
+	result := make([]Pair, 0, len(pairs)+len(movedTo))
+	for k, pair := range pairs {
+		move, isDestination := movedTo[k]
+		switch {
+		case isDestination:
+			// A left paragraph staying here is now removed
+			if pair.Left.Text != "" && !movedFrom[k] {
+				result = append(result, Pair{Left: pair.Left, Right: Paragraph{PageIdx: pair.Left.PageIdx}})
+			}
+			left := pairs[move.src].Left
+			result = append(result, Pair{
+				Left:       left,
+				Right:      pair.Right,
+				Similarity: norm.Similarity(left.Text, pair.Right.Text),
+				Quality:    move.similarity,
+				IsMatch:    true,
+				MatchType:  MatchMoved,
+			})
+		case movedFrom[k]:
+			// A right paragraph staying here is now added
+			if pair.Right.Text != "" {
+				result = append(result, Pair{Left: Paragraph{PageIdx: pair.Right.PageIdx}, Right: pair.Right})
+			}
+		default:
+			result = append(result, pair)
+		}
+	}
+	return result
+}
@@ -0,0 +1,95 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+const confidentiality = "保密：双方应对在履行本合同过程中知悉的对方商业秘密承担保密义务，保密期限为合同终止后三年。"
+
+func TestCompareMovedClause(t *testing.T) {
+	left := []Paragraph{
+		{Text: "第一条 交货：乙方应于合同签订后十日内交付全部货物。", PageIdx: 0},
+		{Text: "第二条 " + confidentiality, PageIdx: 0},
+		{Text: "第三条 付款：甲方应于验收合格后三十日内支付货款。", PageIdx: 1},
+		{Text: "第四条 争议解决：因本合同引起的争议提交仲裁委员会仲裁。", PageIdx: 1},
+	}
+	right := []Paragraph{
+		{Text: "第一条 交货：乙方应于合同签订后十日内交付全部货物。", PageIdx: 0},
+		{Text: "第二条 付款：甲方应于验收合格后三十日内支付货款。", PageIdx: 0},
+		{Text: "第三条 争议解决：因本合同引起的争议提交仲裁委员会仲裁。", PageIdx: 1},
+		{Text: "第四条 " + strings.Replace(confidentiality, "三年", "五年", 1), PageIdx: 2},
+	}
+
+	result := Compare(left, right, DefaultNormalizer, AlgorithmAlign)
+	if len(result.Pairs) != 4 {
+		t.Fatalf("Expected 4 pairs, got %d: %+v", len(result.Pairs), result.Pairs)
+	}
+	moved := result.Pairs[3]
+	if moved.MatchType != MatchMoved || moved.Left.Text != left[1].Text || moved.Right.Text != right[3].Text {
+		t.Fatalf("Expected the confidentiality clause to be moved to the end, got %+v", moved.Pair)
+	}
+	if !moved.HasDiff {
+		t.Error("Expected the inner edit to be diffed")
+	}
+	var inserted string
+	for _, d := range moved.Diffs {
+		if d.Op == OpInsert {
+			inserted += d.Text
+		}
+	}
+	if !strings.Contains(inserted, "五") {
+		t.Errorf("Expected the inner edit 三 -> 五 in the diff, got %+v", moved.Diffs)
+	}
+
+	// The clauses after the move were renumbered
+	if len(result.Changes) != 3 || result.Changes[0].Description != "第三条 modified" {
+		t.Fatalf("Expected two renumbered clauses and a move, got %+v", result.Changes)
+	}
+	change := result.Changes[2]
+	if change.Type != ChangeMoved || change.Pair != 3 || !change.Modified {
+		t.Errorf("Unexpected change %+v", change)
+	}
+	if change.From.Clause != "第二条" || change.From.PageIdx != 0 || change.To.Clause != "第四条" || change.To.PageIdx != 2 {
+		t.Errorf("Unexpected move locations %+v -> %+v", change.From, change.To)
+	}
+	if change.Description != "第二条 moved to 第四条 and modified" {
+		t.Errorf("Unexpected description %q", change.Description)
+	}
+}
+
+func TestDetectMovesBreaksPoorMatch(t *testing.T) {
+	// The clause number paired the confidentiality clause with a
+	// different clause of the same number
+	pairs := []Pair{
+		{
+			Left:       Paragraph{Text: "第八条 " + confidentiality},
+			Right:      Paragraph{Text: "第八条 不可抗力：因不可抗力不能履行合同的，部分或全部免除责任。"},
+			Similarity: 0.2,
+			IsMatch:    true,
+			MatchType:  MatchNumber,
+		},
+		{Left: Paragraph{PageIdx: 3}, Right: Paragraph{Text: "第十五条 " + confidentiality, PageIdx: 3}},
+	}
+
+	result := DetectMoves(pairs, DefaultNormalizer)
+	if len(result) != 2 {
+		t.Fatalf("Expected 2 pairs, got %d: %+v", len(result), result)
+	}
+	if result[0].IsMatch || result[0].Left.Text != "" || !strings.Contains(result[0].Right.Text, "不可抗力") {
+		t.Errorf("Expected the force majeure clause to be added, got %+v", result[0])
+	}
+	if result[1].MatchType != MatchMoved || result[1].Quality != 1 {
+		t.Errorf("Expected an unedited move, got %+v", result[1])
+	}
+}
+
+func TestDetectMovesIgnoresShortParagraphs(t *testing.T) {
+	pairs := []Pair{
+		{Left: Paragraph{Text: "1. 甲方签字"}, Right: Paragraph{}},
+		{Left: Paragraph{}, Right: Paragraph{Text: "5. 甲方签字"}},
+	}
+	if result := DetectMoves(pairs, DefaultNormalizer); len(result) != 2 || result[1].MatchType == MatchMoved {
+		t.Errorf("Expected short paragraphs not to be moved, got %+v", result)
+	}
+}
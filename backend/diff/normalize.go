@@ -0,0 +1,300 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Normalization rules, each ignoring one kind of difference
+const (
+	RuleWhitespace  = "whitespace"  // Whitespace and zero-width characters
+	RulePunctuation = "punctuation" // Full/half-width punctuation and quote styles
+	RuleWidth       = "width"       // Full-width letters and digits such as ＡＢ１２
+	RuleCase        = "case"
+	RuleChinese     = "chinese"   // Traditional and simplified Chinese
+	RuleNumbering   = "numbering" // Leading clause numbers, e.g. after renumbering
+)
+
+// DefaultRules are the rules of DefaultNormalizer
+var DefaultRules = []string{RuleWhitespace, RulePunctuation, RuleCase}
+
+// DefaultNormalizer ignores whitespace, full/half-width punctuation,
+// zero-width characters and case
+var DefaultNormalizer = MustNormalizer(DefaultRules...)
+
+var (
+	punctuationReplacer = strings.NewReplacer(
+		"，", ",",
+		"。", ".",
+		"：", ":",
+		"；", ";",
+		"（", "(",
+		"）", ")",
+		"'", `"`,
+		"“", `"`,
+		"”", `"`,
+		"‘", `"`,
+		"’", `"`,
+		"【", "[",
+		"】", "]",
+		"—", "-",
+	)
+
+	zeroWidth = map[rune]bool{'\u200b': true, '\u200c': true, '\u200d': true}
+
+	sectionNumberPatterns = []*regexp.Regexp{
+		// Arabic numbering: 1. 1.1 1.1.1 1、 1）
+		regexp.MustCompile(`^(\d+(?:\.\d+)*)[\.、）\)]\s*`),
+		// Chinese numbering: 一、 （一） 第一条 第一章
+		regexp.MustCompile(`^[（(]?([零〇一二两三四五六七八九十百]+)[）)、]\s*`),
+		regexp.MustCompile(`^第([零〇一二两三四五六七八九十百千\d]+)[条章节款项]\s*`),
+		// Parenthesized Arabic numbering: (1) （1）
+		regexp.MustCompile(`^[（(](\d+)[）)]\s*`),
+		// Letter numbering: a. A. a) A)
+		regexp.MustCompile(`^([a-zA-Z])[\.）\)]\s*`),
+	}
+)
+
+// Normalizer normalizes texts for comparison by a set of rules
+type Normalizer struct {
+	rules map[string]bool
+}
+
+// NewNormalizer returns a normalizer applying the given rules, e.g.
+// RuleWhitespace and RuleCase
+func NewNormalizer(rules ...string) (*Normalizer, error) {
+	n := &Normalizer{rules: make(map[string]bool, len(rules))}
+	for _, rule := range rules {
+		switch rule {
+		case RuleWhitespace, RulePunctuation, RuleWidth, RuleCase, RuleChinese, RuleNumbering:
+			n.rules[rule] = true
+		default:
+			return nil, fmt.Errorf("unknown normalization rule %q", rule)
+		}
+	}
+	return n, nil
+}
+
+// MustNormalizer is NewNormalizer for rules known to be valid
+func MustNormalizer(rules ...string) *Normalizer {
+	n, err := NewNormalizer(rules...)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// Normalize normalizes a text by the normalizer's rules. RuleNumbering
+// only applies to whole paragraphs, see NormalizeParagraph, as a fragment
+// of a diff such as "12.50" would read as a clause number.
+func (n *Normalizer) Normalize(text string) string {
+	if text == "" {
+		return ""
+	}
+
+	if n.rules[RuleWidth] || n.rules[RuleChinese] || n.rules[RuleWhitespace] {
+		text = strings.Map(func(r rune) rune {
+			if n.rules[RuleWhitespace] && (isJSSpace(r) || zeroWidth[r]) {
+				return -1
+			}
+			if n.rules[RuleWidth] && r >= '！' && r <= '～' && !isFullWidthPunctuation(r) {
+				return r - '！' + '!'
+			}
+			if n.rules[RuleChinese] {
+				if simplified, ok := simplifiedForms[r]; ok {
+					return simplified
+				}
+			}
+			return r
+		}, text)
+	}
+	if n.rules[RulePunctuation] {
+		text = punctuationReplacer.Replace(text)
+	}
+	if n.rules[RuleCase] {
+		text = strings.ToLower(text)
+	}
+	return text
+}
+
+// NormalizeParagraph normalizes a paragraph by the normalizer's rules,
+// including RuleNumbering
+func (n *Normalizer) NormalizeParagraph(text string) string {
+	return n.Normalize(text[n.numberEnd(text):])
+}
+
+// numberEnd returns the byte offset where a paragraph's text starts after
+// the clause number that RuleNumbering ignores, or 0
+func (n *Normalizer) numberEnd(text string) int {
+	if !n.rules[RuleNumbering] {
+		return 0
+	}
+	return clauseNumberEnd(text)
+}
+
+// pastNumber returns the part of an edit starting at pos in a paragraph
+// that lies after the paragraph's clause number ending at end
+func pastNumber(edit string, pos, end int) string {
+	return edit[min(max(end-pos, 0), len(edit)):]
+}
+
+// isFullWidthPunctuation reports whether a full-width character is
+// punctuation, which RulePunctuation folds rather than RuleWidth
+func isFullWidthPunctuation(r rune) bool {
+	half := r - '！' + '!'
+	return !unicode.IsLetter(half) && !unicode.IsDigit(half)
+}
+
+// NormalizeText normalizes text for comparison with DefaultNormalizer
+func NormalizeText(text string) string {
+	return DefaultNormalizer.Normalize(text)
+}
+
+// ExtractSectionNumber returns the leading clause number of a paragraph
+// (1.、1.1、（一）、第一条 ...), or "" if there is none
+func ExtractSectionNumber(text string) string {
+	if text == "" {
+		return ""
+	}
+
+	trimmed := trimSpace(text)
+	for _, pattern := range sectionNumberPatterns {
+		if m := pattern.FindStringSubmatch(trimmed); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// NormalizeNumber converts a clause number to a comparable form, mapping
+// Chinese numerals to Arabic digits
+func NormalizeNumber(num string) string {
+	if num == "" {
+		return ""
+	}
+	if n, ok := ParseChineseNumeral(num); ok {
+		return strconv.Itoa(n)
+	}
+	return strings.ToLower(num)
+}
+
+// Similarity returns the Jaccard similarity of the character bigrams of the
+// texts normalized by DefaultNormalizer, between 0 and 1
+func Similarity(a, b string) float64 {
+	return DefaultNormalizer.Similarity(a, b)
+}
+
+// Similarity returns the Jaccard similarity of the character bigrams of the
+// normalized texts, between 0 and 1
+func (n *Normalizer) Similarity(a, b string) float64 {
+	s1 := []rune(n.NormalizeParagraph(a))
+	s2 := []rune(n.NormalizeParagraph(b))
+
+	if string(s1) == string(s2) {
+		return 1.0
+	}
+	if len(s1) == 0 || len(s2) == 0 {
+		return 0.0
+	}
+
+	ngrams1 := bigrams(s1)
+	ngrams2 := bigrams(s2)
+	if len(ngrams1) == 0 && len(ngrams2) == 0 {
+		return 1.0
+	}
+
+	intersection := 0
+	for g := range ngrams1 {
+		if _, ok := ngrams2[g]; ok {
+			intersection++
+		}
+	}
+	union := len(ngrams1) + len(ngrams2) - intersection
+
+	return float64(intersection) / float64(union)
+}
+
+func bigrams(s []rune) map[string]struct{} {
+	set := make(map[string]struct{})
+	for i := 0; i+2 <= len(s); i++ {
+		set[string(s[i:i+2])] = struct{}{}
+	}
+	return set
+}
+
+// allRules lists the normalization rules in the order they are reported
+var allRules = []string{RuleWhitespace, RulePunctuation, RuleWidth, RuleCase, RuleChinese, RuleNumbering}
+
+// Rules returns the rules the normalizer applies
+func (n *Normalizer) Rules() []string {
+	rules := []string{}
+	for _, rule := range allRules {
+		if n.rules[rule] {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// DefaultProfile is the normalization profile with DefaultRules, available
+// unless configured otherwise
+const DefaultProfile = "standard"
+
+// Profiles are named normalizers that comparisons select by name
+type Profiles struct {
+	normalizers map[string]*Normalizer
+	defaultName string
+}
+
+// NewProfiles builds the normalizers of profiles given by their rules, e.g.
+// "strict" → [whitespace]. DefaultProfile is added unless defined, and
+// defaultName, or DefaultProfile if empty, is used when none is selected.
+func NewProfiles(profiles map[string][]string, defaultName string) (*Profiles, error) {
+	p := &Profiles{normalizers: make(map[string]*Normalizer), defaultName: defaultName}
+	if p.defaultName == "" {
+		p.defaultName = DefaultProfile
+	}
+	for name, rules := range profiles {
+		n, err := NewNormalizer(rules...)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		p.normalizers[name] = n
+	}
+	if _, ok := p.normalizers[DefaultProfile]; !ok {
+		p.normalizers[DefaultProfile] = DefaultNormalizer
+	}
+	if _, ok := p.normalizers[p.defaultName]; !ok {
+		return nil, fmt.Errorf("default profile %s is not defined", p.defaultName)
+	}
+	return p, nil
+}
+
+// Get returns the normalizer of a profile, or of the default profile if
+// name is empty, together with the profile name
+func (p *Profiles) Get(name string) (*Normalizer, string, bool) {
+	if name == "" {
+		name = p.defaultName
+	}
+	n, ok := p.normalizers[name]
+	return n, name, ok
+}
+
+// Default returns the name of the default profile
+func (p *Profiles) Default() string {
+	return p.defaultName
+}
+
+// Names returns the profile names in alphabetical order
+func (p *Profiles) Names() []string {
+	names := make([]string, 0, len(p.normalizers))
+	for name := range p.normalizers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
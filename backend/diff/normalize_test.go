@@ -0,0 +1,188 @@
+package diff
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"甲方 应当\n付款。", "甲方应当付款."},
+		{"（一）ABC：费用；", "(一)abc:费用;"},
+		{"It's【A】—B", `it"s[a]-b`},
+		{"零\u200b宽\ufeff字符", "零宽字符"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeText(tt.input); got != tt.expected {
+			t.Errorf("NormalizeText(%q): expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestExtractSectionNumber(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1. 总则", "1"},
+		{"1.2.3、 定义", "1.2.3"},
+		{"（三）付款方式", "三"},
+		{"第十二条 违约责任", "十二"},
+		{"(4) 其他", "4"},
+		{"b) option", "b"},
+		{"本合同自签订之日起生效", ""},
+	}
+
+	for _, tt := range tests {
+		if got := ExtractSectionNumber(tt.input); got != tt.expected {
+			t.Errorf("ExtractSectionNumber(%q): expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestNormalizeNumber(t *testing.T) {
+	tests := map[string]string{
+		"":     "",
+		"三":    "3",
+		"十二":   "12",
+		"1.2":  "1.2",
+		"B":    "b",
+		"二十一":  "21",
+		"一百零五": "105",
+	}
+
+	for input, expected := range tests {
+		if got := NormalizeNumber(input); got != expected {
+			t.Errorf("NormalizeNumber(%q): expected %q, got %q", input, expected, got)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	if s := Similarity("甲方付款", "甲方 付款"); s != 1.0 {
+		t.Errorf("Expected 1.0 for normalized-equal texts, got %f", s)
+	}
+	if s := Similarity("", "甲方"); s != 0.0 {
+		t.Errorf("Expected 0.0 for empty text, got %f", s)
+	}
+	// Bigrams: {ab, bc} vs {ab, bd} => 1/3
+	if s := Similarity("abc", "abd"); s < 0.333 || s > 0.334 {
+		t.Errorf("Expected 1/3, got %f", s)
+	}
+}
+
+func TestNormalizerRules(t *testing.T) {
+	tests := []struct {
+		rules    []string
+		input    string
+		expected string
+	}{
+		{nil, "甲方 应当，付款", "甲方 应当，付款"},
+		{[]string{RuleWhitespace}, "甲方 应当，付款", "甲方应当，付款"},
+		{[]string{RulePunctuation}, "“甲方”：付款", `"甲方":付款`},
+		{[]string{RuleWidth}, "ＡＢＣ１２３，", "ABC123，"},
+		{[]string{RuleCase}, "ABC", "abc"},
+		{[]string{RuleChinese}, "甲方應於驗收後付款", "甲方应于验收后付款"},
+		{[]string{RuleNumbering}, "12.50万元", "12.50万元"},
+		{[]string{RuleWidth, RuleCase, RuleWhitespace}, "Ｎｏ． １", "no．1"},
+	}
+
+	for _, tt := range tests {
+		n, err := NewNormalizer(tt.rules...)
+		if err != nil {
+			t.Fatalf("NewNormalizer(%v): %v", tt.rules, err)
+		}
+		if got := n.Normalize(tt.input); got != tt.expected {
+			t.Errorf("%v Normalize(%q): expected %q, got %q", tt.rules, tt.input, tt.expected, got)
+		}
+	}
+
+	if _, err := NewNormalizer("accents"); err == nil {
+		t.Error("Expected an error for an unknown rule")
+	}
+}
+
+func TestNormalizeParagraph(t *testing.T) {
+	n := MustNormalizer(RuleNumbering)
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"第十二条 违约责任", "违约责任"},
+		{"1.1甲方应付款", "甲方应付款"},
+		{"3. 服务费", "服务费"},
+		{"乙方应付款", "乙方应付款"},
+	}
+	for _, tt := range tests {
+		if got := n.NormalizeParagraph(tt.input); got != tt.expected {
+			t.Errorf("NormalizeParagraph(%q): expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+	if got := DefaultNormalizer.NormalizeParagraph("第十二条 违约责任"); got != "第十二条违约责任" {
+		t.Errorf("Expected the clause number to be kept without RuleNumbering, got %q", got)
+	}
+}
+
+func TestCompareNumbersIgnoringNumbering(t *testing.T) {
+	lenient := MustNormalizer(RuleWhitespace, RulePunctuation, RuleWidth, RuleCase, RuleChinese, RuleNumbering)
+
+	left := []Paragraph{{Text: "第三条 乙方应支付服务费12.50万元。"}}
+	right := []Paragraph{{Text: "第三条 乙方应支付服务费13.75万元。"}}
+	result := Compare(left, right, lenient, DefaultAlgorithm)
+	if len(result.Pairs) != 1 || !result.Pairs[0].HasDiff {
+		t.Fatalf("Expected the amount edit to be a diff, got %+v", result.Pairs)
+	}
+	if len(result.Changes) != 1 || !slices.Contains(result.Changes[0].Categories, CategoryAmount) {
+		t.Errorf("Expected an amount change, got %+v", result.Changes)
+	}
+
+	renumbered := []Paragraph{{Text: "第四条 乙方应支付服务费12.50万元。"}}
+	if result := Compare(left, renumbered, lenient, DefaultAlgorithm); len(result.Changes) != 0 || result.Pairs[0].HasDiff {
+		t.Errorf("Expected renumbering to be ignored, got %+v", result.Changes)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	profiles, err := NewProfiles(map[string][]string{"strict": {RuleWhitespace}}, "strict")
+	if err != nil {
+		t.Fatalf("NewProfiles: %v", err)
+	}
+	if _, name, ok := profiles.Get(""); !ok || name != "strict" {
+		t.Errorf("Expected the strict profile by default, got %s", name)
+	}
+	if n, _, ok := profiles.Get(DefaultProfile); !ok || n != DefaultNormalizer {
+		t.Error("Expected the standard profile to be added")
+	}
+	if _, _, ok := profiles.Get("loose"); ok {
+		t.Error("Expected no loose profile")
+	}
+
+	if _, err := NewProfiles(map[string][]string{"bad": {"accents"}}, ""); err == nil {
+		t.Error("Expected an error for an unknown rule")
+	}
+	if _, err := NewProfiles(nil, "missing"); err == nil {
+		t.Error("Expected an error for an undefined default profile")
+	}
+}
+
+func TestCompareWithProfile(t *testing.T) {
+	left := []Paragraph{{Text: "第一条 甲方應於驗收合格後三十日內付款，逾期支付違約金。"}}
+	right := []Paragraph{{Text: "第一条 甲方应于验收合格后三十日内付款,逾期支付违约金。"}}
+
+	if result := Compare(left, right, DefaultNormalizer, DefaultAlgorithm); len(result.Changes) != 1 {
+		t.Errorf("Expected the standard profile to report the change, got %+v", result.Changes)
+	}
+	lenient := MustNormalizer(RuleWhitespace, RulePunctuation, RuleChinese)
+	if result := Compare(left, right, lenient, DefaultAlgorithm); len(result.Changes) != 0 || result.Stats.Total != 0 {
+		t.Errorf("Expected no changes ignoring traditional characters, got %+v", result.Changes)
+	}
+	strict := MustNormalizer(RuleWhitespace, RuleChinese)
+	if result := Compare(left, right, strict, DefaultAlgorithm); len(result.Changes) != 1 {
+		t.Errorf("Expected the comma to count without punctuation folding, got %+v", result.Changes)
+	}
+}
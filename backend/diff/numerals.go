@@ -0,0 +1,99 @@
+package diff
+
+import "strings"
+
+var (
+	chineseDigits = map[rune]int{
+		'零': 0, '〇': 0,
+		'一': 1, '壹': 1,
+		'二': 2, '两': 2, '贰': 2,
+		'三': 3, '叁': 3,
+		'四': 4, '肆': 4,
+		'五': 5, '伍': 5,
+		'六': 6, '陆': 6,
+		'七': 7, '柒': 7,
+		'八': 8, '捌': 8,
+		'九': 9, '玖': 9,
+	}
+	chineseUnits = map[rune]int{
+		'十': 10, '拾': 10,
+		'百': 100, '佰': 100,
+		'千': 1000, '仟': 1000,
+	}
+	chineseMyriads = map[rune]int{
+		'万': 10000, '萬': 10000,
+	}
+	romanValues = map[rune]int{'i': 1, 'v': 5, 'x': 10, 'l': 50, 'c': 100, 'd': 500, 'm': 1000}
+)
+
+// ParseChineseNumeral converts a Chinese numeral to an integer, e.g. 十二
+// to 12 and 一百零五 to 105. Financial forms (壹贰叁) are accepted, and
+// numerals without units (二〇二四) are read digit by digit.
+func ParseChineseNumeral(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+
+	hasUnit := false
+	for _, r := range s {
+		_, isDigit := chineseDigits[r]
+		_, isUnit := chineseUnits[r]
+		_, isMyriad := chineseMyriads[r]
+		if !isDigit && !isUnit && !isMyriad {
+			return 0, false
+		}
+		hasUnit = hasUnit || isUnit || isMyriad
+	}
+
+	if !hasUnit {
+		n := 0
+		for _, r := range s {
+			n = n*10 + chineseDigits[r]
+		}
+		return n, true
+	}
+
+	// total holds completed myriad groups, section the current group below
+	// 10000 and digit the pending digit
+	total, section, digit := 0, 0, 0
+	for _, r := range s {
+		if d, ok := chineseDigits[r]; ok {
+			digit = d
+			continue
+		}
+		if unit, ok := chineseUnits[r]; ok {
+			if digit == 0 && unit == 10 {
+				// 十二 means 一十二
+				digit = 1
+			}
+			section += digit * unit
+			digit = 0
+			continue
+		}
+		myriad := chineseMyriads[r]
+		total = (total + section + digit) * myriad
+		section, digit = 0, 0
+	}
+	return total + section + digit, true
+}
+
+// parseRomanNumeral converts a Roman numeral such as IV or xii to an integer
+func parseRomanNumeral(s string) (int, bool) {
+	s = strings.ToLower(s)
+	if s == "" {
+		return 0, false
+	}
+	n := 0
+	for i, r := range s {
+		v, ok := romanValues[r]
+		if !ok {
+			return 0, false
+		}
+		if i+1 < len(s) && v < romanValues[rune(s[i+1])] {
+			n -= v
+		} else {
+			n += v
+		}
+	}
+	return n, true
+}
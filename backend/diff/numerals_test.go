@@ -0,0 +1,46 @@
+package diff
+
+import "testing"
+
+func TestParseChineseNumeral(t *testing.T) {
+	tests := map[string]int{
+		"一":     1,
+		"十":     10,
+		"十五":    15,
+		"二十":    20,
+		"九十九":   99,
+		"一百":    100,
+		"一百零五":  105,
+		"一百一十":  110,
+		"两千零二十": 2020,
+		"一万二千":  12000,
+		"十二万":   120000,
+		"壹佰贰拾叁": 123,
+		"二〇二四":  2024,
+		"零":     0,
+	}
+	for input, expected := range tests {
+		got, ok := ParseChineseNumeral(input)
+		if !ok || got != expected {
+			t.Errorf("ParseChineseNumeral(%q): expected %d, got %d, %v", input, expected, got, ok)
+		}
+	}
+
+	for _, input := range []string{"", "12", "十a", "第一"} {
+		if _, ok := ParseChineseNumeral(input); ok {
+			t.Errorf("ParseChineseNumeral(%q): expected failure", input)
+		}
+	}
+}
+
+func TestParseRomanNumeral(t *testing.T) {
+	tests := map[string]int{"I": 1, "iv": 4, "IX": 9, "XII": 12, "XL": 40, "MCMXCIV": 1994}
+	for input, expected := range tests {
+		if got, ok := parseRomanNumeral(input); !ok || got != expected {
+			t.Errorf("parseRomanNumeral(%q): expected %d, got %d, %v", input, expected, got, ok)
+		}
+	}
+	if _, ok := parseRomanNumeral("IIa"); ok {
+		t.Error("Expected failure for a non-Roman numeral")
+	}
+}
@@ -0,0 +1,240 @@
+package diff
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Clause numbering styles
+const (
+	StyleChapter      = "chapter"   // 第一章, Chapter 1
+	StyleSection      = "section"   // 第一节, Section 1.1
+	StyleArticle      = "article"   // 第一条, Article 1
+	StyleParagraph    = "paragraph" // 第一款
+	StyleItem         = "item"      // 第一项
+	StyleClause       = "clause"    // Clause 1
+	StyleArabic       = "arabic"    // 1. 1.1 1、
+	StyleChinese      = "chinese"   // 一、
+	StyleParenChinese = "paren-chinese"
+	StyleParenArabic  = "paren-arabic"
+	StyleParenLetter  = "paren-letter"
+	StyleLetter       = "letter" // a. A)
+)
+
+// maxTitleLength is the length in runes up to which a clause title is kept
+const maxTitleLength = 40
+
+const chineseNumeral = `[零〇一二两三四五六七八九十百千壹贰叁肆伍陆柒捌玖拾佰仟\d]+`
+
+var numberingStyles = []struct {
+	style   string
+	pattern *regexp.Regexp
+}{
+	{StyleChapter, regexp.MustCompile(`^第(` + chineseNumeral + `)章`)},
+	{StyleChapter, regexp.MustCompile(`(?i)^chapter\s+(\d+|[ivxlcdm]+)\b`)},
+	{StyleSection, regexp.MustCompile(`^第(` + chineseNumeral + `)节`)},
+	{StyleSection, regexp.MustCompile(`(?i)^section\s+(\d+(?:\.\d+)*|[ivxlcdm]+)\b`)},
+	{StyleArticle, regexp.MustCompile(`^第(` + chineseNumeral + `)条`)},
+	{StyleArticle, regexp.MustCompile(`(?i)^article\s+(\d+|[ivxlcdm]+)\b`)},
+	{StyleParagraph, regexp.MustCompile(`^第(` + chineseNumeral + `)款`)},
+	{StyleItem, regexp.MustCompile(`^第(` + chineseNumeral + `)项`)},
+	{StyleClause, regexp.MustCompile(`(?i)^clause\s+(\d+(?:\.\d+)*)\b`)},
+	// 1.1 is a sub-clause of 1. and must be tried first. The character
+	// captured as next only tells 1.1 from 1.15 and is not part of the number.
+	{StyleArabic, regexp.MustCompile(`^(\d+(?:\.\d+)+)(?P<next>\D|$)`)},
+	{StyleArabic, regexp.MustCompile(`^(\d+)(?:[、）\)]|\.(?P<next>\D|$))`)},
+	{StyleChinese, regexp.MustCompile(`^([零〇一二两三四五六七八九十百]+)、`)},
+	{StyleParenChinese, regexp.MustCompile(`^[（(]([零〇一二两三四五六七八九十百]+)[）)]`)},
+	{StyleParenArabic, regexp.MustCompile(`^[（(](\d+)[）)]`)},
+	{StyleParenLetter, regexp.MustCompile(`^[（(]([a-zA-Z])[）)]`)},
+	{StyleLetter, regexp.MustCompile(`^([a-zA-Z])[\.）\)]`)},
+}
+
+// clauseNumber is a clause number together with its numbering level, so
+// that "1." and "（一）" are different levels even if both normalize to 1
+type clauseNumber struct {
+	style  string
+	number string
+}
+
+// Clause is a node of a contract's clause tree
+type Clause struct {
+	Style     string    `json:"style"`           // One of the Style constants
+	Number    string    `json:"number"`          // Normalized number, e.g. 12 for 第十二条
+	Label     string    `json:"label"`           // Number as written, e.g. 第十二条 or （三）
+	Reference string    `json:"reference"`       // Citation of the clause, e.g. 第十二条第（三）款
+	Title     string    `json:"title,omitempty"` // Start of the clause text
+	PageIdx   int       `json:"page_idx"`
+	Children  []*Clause `json:"children,omitempty"`
+
+	key clauseNumber
+}
+
+// parseClause parses the leading clause number of a paragraph
+func parseClause(p Paragraph) (*Clause, bool) {
+	trimmed := trimSpace(p.Text)
+	for _, s := range numberingStyles {
+		m := s.pattern.FindStringSubmatch(trimmed)
+		if m == nil {
+			continue
+		}
+
+		number := NormalizeNumber(m[1])
+		switch s.style {
+		case StyleChapter, StyleSection, StyleArticle:
+			if n, ok := parseRomanNumeral(m[1]); ok {
+				number = strconv.Itoa(n)
+			}
+		}
+		level := s.style
+		if s.style == StyleArabic {
+			// 1.2 is a level below 1.
+			level += strings.Repeat(".", strings.Count(m[1], "."))
+		}
+
+		return &Clause{
+			Style:   s.style,
+			Number:  number,
+			Label:   clauseLabel(m[0]),
+			Title:   clauseTitle(trimmed[len(m[0]):]),
+			PageIdx: p.PageIdx,
+			key:     clauseNumber{style: level, number: number},
+		}, true
+	}
+	return nil, false
+}
+
+// clauseLabel trims the punctuation following a clause number, keeping
+// balanced parentheses: "1." → "1", "a)" → "a", "（三）" → "（三）"
+func clauseLabel(s string) string {
+	label := strings.TrimRight(trimSpace(s), ".、")
+	if !strings.ContainsAny(label, "(（") {
+		label = strings.TrimRight(label, ")）")
+	}
+	return label
+}
+
+// clauseTitle returns the start of a clause text, up to the first break in
+// the sentence
+func clauseTitle(rest string) string {
+	title := strings.TrimLeft(trimSpace(rest), ":：.、 ")
+	if i := strings.IndexAny(title, "。；;，,：:"); i >= 0 {
+		title = title[:i]
+	}
+	title = trimSpace(title)
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		title = string([]rune(title)[:maxTitleLength]) + "…"
+	}
+	return title
+}
+
+// clauseReference cites the innermost clause of a path. Citations start at
+// the article, as articles are numbered throughout a contract, and levels
+// below a Chinese article read as 款 and 项.
+func clauseReference(path []*Clause) string {
+	start := 0
+	for k, c := range path {
+		if c.Style == StyleArticle {
+			start = k
+		}
+	}
+	chineseArticle := path[start].Style == StyleArticle && strings.HasPrefix(path[start].Label, "第")
+
+	var b strings.Builder
+	sub := 0 // Levels below a Chinese article
+	for k, c := range path[start:] {
+		label := c.Label
+		if chineseArticle && k > 0 {
+			switch {
+			case c.Style == StyleParagraph:
+				sub = 1
+			case c.Style == StyleItem:
+				sub = 2
+			case sub == 0:
+				label, sub = "第"+label+"款", 1
+			case sub == 1:
+				label, sub = "第"+label+"项", 2
+			}
+		}
+		if k > 0 && !strings.HasPrefix(label, "第") && !strings.HasPrefix(label, "(") && !strings.HasPrefix(label, "（") {
+			b.WriteString(" ")
+		}
+		b.WriteString(label)
+	}
+	return b.String()
+}
+
+// buildOutline builds the clause tree of paragraphs. A numbering style seen
+// before closes the levels opened after it, so the "1." of each chapter is
+// a child of that chapter. It also returns the path of open clauses at
+// each paragraph and whether the paragraph starts a clause.
+func buildOutline(paragraphs []Paragraph) (roots []*Clause, paths [][]*Clause, starts []bool) {
+	paths = make([][]*Clause, len(paragraphs))
+	starts = make([]bool, len(paragraphs))
+	var stack []*Clause
+	for i, p := range paragraphs {
+		clause, ok := parseClause(p)
+		if ok {
+			level := len(stack)
+			for k, open := range stack {
+				if open.key.style == clause.key.style {
+					level = k
+					break
+				}
+			}
+			stack = append(stack[:level], clause)
+			clause.Reference = clauseReference(stack)
+			if level == 0 {
+				roots = append(roots, clause)
+			} else {
+				parent := stack[level-1]
+				parent.Children = append(parent.Children, clause)
+			}
+			starts[i] = true
+		}
+		paths[i] = append([]*Clause(nil), stack...)
+	}
+	return roots, paths, starts
+}
+
+// ParseOutline returns the clause tree of a contract's paragraphs:
+// 章/节/条/款/项, Chapter/Section/Article/Clause and enumerations such as
+// 1.1, （一）, (1) and a.
+func ParseOutline(paragraphs []Paragraph) []*Clause {
+	roots, _, _ := buildOutline(paragraphs)
+	if roots == nil {
+		return []*Clause{}
+	}
+	return roots
+}
+
+// AnnotateClauses sets the clause reference of each paragraph to the
+// innermost clause containing it
+func AnnotateClauses(paragraphs []Paragraph) {
+	_, paths, _ := buildOutline(paragraphs)
+	for i, path := range paths {
+		if len(path) > 0 {
+			paragraphs[i].Clause = path[len(path)-1].Reference
+		}
+	}
+}
+
+// numberingPaths returns the clause numbers leading to each paragraph that
+// starts a clause, e.g. [第二章, 1.] for the first numbered clause of
+// chapter two. Other paragraphs have a nil path.
+func numberingPaths(paragraphs []Paragraph) [][]clauseNumber {
+	_, paths, starts := buildOutline(paragraphs)
+	numbers := make([][]clauseNumber, len(paragraphs))
+	for i, path := range paths {
+		if !starts[i] {
+			continue
+		}
+		numbers[i] = make([]clauseNumber, len(path))
+		for k, c := range path {
+			numbers[i][k] = c.key
+		}
+	}
+	return numbers
+}
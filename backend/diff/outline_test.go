@@ -0,0 +1,147 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+// outlineRefs flattens a clause tree into indented references
+func outlineRefs(clauses []*Clause, indent string) []string {
+	var refs []string
+	for _, c := range clauses {
+		refs = append(refs, indent+c.Reference)
+		refs = append(refs, outlineRefs(c.Children, indent+"  ")...)
+	}
+	return refs
+}
+
+func TestParseOutlineChinese(t *testing.T) {
+	paragraphs := []Paragraph{
+		{Text: "采购合同"},
+		{Text: "第一章 总则"},
+		{Text: "第一条 定义：本合同所称货物是指附件一所列物品。"},
+		{Text: "第二章 价款与支付"},
+		{Text: "第十二条 付款", PageIdx: 3},
+		{Text: "（一）预付款为合同总价的百分之三十；"},
+		{Text: "（二）进度款按月支付；"},
+		{Text: "（三）尾款于验收合格后支付："},
+		{Text: "1. 质保金为百分之五；"},
+		{Text: "2. 质保期满后退还。"},
+		{Text: "第一百零五条 附则"},
+	}
+
+	clauses := ParseOutline(paragraphs)
+	expected := []string{
+		"第一章",
+		"  第一条",
+		"第二章",
+		"  第十二条",
+		"    第十二条第（一）款",
+		"    第十二条第（二）款",
+		"    第十二条第（三）款",
+		"      第十二条第（三）款第1项",
+		"      第十二条第（三）款第2项",
+		"  第一百零五条",
+	}
+	if got := outlineRefs(clauses, ""); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected outline\n%v\ngot\n%v", expected, got)
+	}
+
+	article := clauses[1].Children[0]
+	if article.Style != StyleArticle || article.Number != "12" || article.Label != "第十二条" || article.Title != "付款" || article.PageIdx != 3 {
+		t.Errorf("Unexpected article %+v", article)
+	}
+	if title := clauses[0].Children[0].Title; title != "定义" {
+		t.Errorf("Expected the title to end at the first break, got %q", title)
+	}
+	if n := clauses[1].Children[1].Number; n != "105" {
+		t.Errorf("Expected 第一百零五条 to be number 105, got %s", n)
+	}
+}
+
+func TestParseOutlineEnglish(t *testing.T) {
+	paragraphs := []Paragraph{
+		{Text: "ARTICLE IV Payment"},
+		{Text: "Section 4.1 Fees. The Customer shall pay..."},
+		{Text: "(a) within thirty days;"},
+		{Text: "(b) in US dollars."},
+		{Text: "Section 4.2 Taxes"},
+		{Text: "Article 5 Term"},
+	}
+
+	clauses := ParseOutline(paragraphs)
+	expected := []string{
+		"ARTICLE IV",
+		"  ARTICLE IV Section 4.1",
+		"    ARTICLE IV Section 4.1(a)",
+		"    ARTICLE IV Section 4.1(b)",
+		"  ARTICLE IV Section 4.2",
+		"Article 5",
+	}
+	if got := outlineRefs(clauses, ""); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected outline\n%v\ngot\n%v", expected, got)
+	}
+	if clauses[0].Number != "4" || clauses[0].Title != "Payment" {
+		t.Errorf("Expected Article IV to be number 4, got %+v", clauses[0])
+	}
+}
+
+func TestParseOutlineEmpty(t *testing.T) {
+	clauses := ParseOutline([]Paragraph{{Text: "本合同由双方签订。"}})
+	if clauses == nil || len(clauses) != 0 {
+		t.Errorf("Expected an empty outline, got %+v", clauses)
+	}
+}
+
+func TestAnnotateClauses(t *testing.T) {
+	paragraphs := []Paragraph{
+		{Text: "鉴于双方友好协商"},
+		{Text: "第三条 交货"},
+		{Text: "乙方应按时交货。"},
+	}
+	AnnotateClauses(paragraphs)
+	if paragraphs[0].Clause != "" || paragraphs[1].Clause != "第三条" || paragraphs[2].Clause != "第三条" {
+		t.Errorf("Unexpected clauses %q, %q, %q", paragraphs[0].Clause, paragraphs[1].Clause, paragraphs[2].Clause)
+	}
+}
+
+func TestCompareChanges(t *testing.T) {
+	left := []Paragraph{
+		{Text: "第十二条 付款"},
+		{Text: "（一）预付款为合同总价的百分之三十；"},
+		{Text: "（二）进度款按月支付；"},
+		{Text: "（三）尾款于验收合格后三十日内支付。"},
+		{Text: "第十三条 违约责任"},
+	}
+	right := []Paragraph{
+		{Text: "第十二条 付款"},
+		{Text: "（一）预付款为合同总价的百分之三十；"},
+		{Text: "（二）进度款按月支付；"},
+		{Text: "（三）尾款于验收合格后六十日内支付。"},
+		{Text: "第十三条 违约责任"},
+		{Text: "（一）逾期付款的，每日按未付金额的万分之五支付违约金。", PageIdx: 4},
+	}
+
+	result := Compare(left, right, DefaultNormalizer, DefaultAlgorithm)
+	var descriptions []string
+	for _, c := range result.Changes {
+		descriptions = append(descriptions, c.Description)
+	}
+	expected := []string{"第十二条第（三）款 modified", "第十三条第（一）款 added"}
+	if !reflect.DeepEqual(descriptions, expected) {
+		t.Errorf("Expected changes %v, got %v", expected, descriptions)
+	}
+	if pair := result.Pairs[result.Changes[0].Pair]; pair.Left.Text != left[3].Text {
+		t.Errorf("Expected the change to point at its pair, got %+v", pair)
+	}
+
+	// The caller's paragraphs are left untouched
+	if left[3].Clause != "" {
+		t.Error("Expected Compare not to modify its arguments")
+	}
+
+	result = Compare([]Paragraph{{Text: "甲方：某公司"}}, []Paragraph{{Text: "甲方：某某有限公司"}, {Text: "附件清单", PageIdx: 1}}, DefaultNormalizer, DefaultAlgorithm)
+	if n := len(result.Changes); n == 0 || result.Changes[n-1].Description != "Paragraph on page 2 added" {
+		t.Errorf("Expected unnumbered changes to be described by page, got %+v", result.Changes)
+	}
+}
@@ -0,0 +1,133 @@
+package diff
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/AnTengye/contractdiff/backend/document"
+)
+
+// Paragraph is a block of text extracted from a parsed contract
+type Paragraph struct {
+	Text    string `json:"text"`
+	Type    string `json:"type,omitempty"`
+	PageIdx int    `json:"page_idx"`
+	Clause  string `json:"clause,omitempty"` // Reference of the clause containing it, set by AnnotateClauses
+}
+
+var (
+	sentenceEndingPattern = regexp.MustCompile(`[。！？.!?；;：:]$`)
+
+	sectionStartPatterns = []*regexp.Regexp{
+		// Arabic numbering: 1. 1.1 1.1.1 1、 1）
+		regexp.MustCompile(`^\d+(?:\.\d+)*[\.、）\)]\s*`),
+		// Chinese numbering: 一、 （一） 第一条 第一章
+		regexp.MustCompile(`^[（(]?[一二三四五六七八九十]+[）)、]\s*`),
+		regexp.MustCompile(`^第[一二三四五六七八九十\d]+[条章节款项]\s*`),
+		// Parenthesized Arabic numbering: (1) （1）
+		regexp.MustCompile(`^[（(]\d+[）)]\s*`),
+		// Letter numbering: a. A. a) A)
+		regexp.MustCompile(`^[a-zA-Z][\.）\)]\s*`),
+	}
+)
+
+// ParseParagraphs extracts text paragraphs from MinerU JSON (pdf_info/para_blocks)
+// and merges paragraphs that were split across pages, mirroring parseContractJSON
+// in the web UI.
+func ParseParagraphs(data any) []Paragraph {
+	doc, err := document.Parse(data)
+	if err != nil {
+		return nil
+	}
+
+	var paragraphs []Paragraph
+	for _, page := range doc.Pages {
+		for _, block := range page.Blocks {
+			blockText := block.OwnText()
+
+			// Nested blocks (lists, tables) produce one paragraph per sub-block
+			if len(block.Children) > 0 {
+				for _, subBlock := range block.Children {
+					blockText += subBlock.OwnText()
+					if blockText != "" {
+						subType := subBlock.Type
+						if subType == "" {
+							subType = block.Type
+						}
+						paragraphs = append(paragraphs, Paragraph{
+							Text:    trimSpace(blockText),
+							Type:    subType,
+							PageIdx: page.Index,
+						})
+						blockText = ""
+					}
+				}
+			} else if blockText != "" {
+				paragraphs = append(paragraphs, Paragraph{
+					Text:    trimSpace(blockText),
+					Type:    block.Type,
+					PageIdx: page.Index,
+				})
+			}
+		}
+	}
+
+	return mergeCrossPageParagraphs(paragraphs)
+}
+
+// mergeCrossPageParagraphs joins paragraphs that continue an unfinished sentence
+func mergeCrossPageParagraphs(paragraphs []Paragraph) []Paragraph {
+	if len(paragraphs) <= 1 {
+		return paragraphs
+	}
+
+	merged := make([]Paragraph, 0, len(paragraphs))
+	for i := 0; i < len(paragraphs); i++ {
+		current := paragraphs[i]
+		for i+1 < len(paragraphs) && shouldMergeParagraphs(current, paragraphs[i+1]) {
+			current.Text += paragraphs[i+1].Text
+			i++
+		}
+		merged = append(merged, current)
+	}
+	return merged
+}
+
+// shouldMergeParagraphs reports whether next continues prev: prev does not end
+// a sentence and next does not start a new numbered clause
+func shouldMergeParagraphs(prev, next Paragraph) bool {
+	if endsWithCompleteSentence(prev.Text) {
+		return false
+	}
+	return !startsWithSectionNumber(next.Text)
+}
+
+func endsWithCompleteSentence(text string) bool {
+	if text == "" {
+		return true
+	}
+	return sentenceEndingPattern.MatchString(trimSpace(text))
+}
+
+func startsWithSectionNumber(text string) bool {
+	if text == "" {
+		return false
+	}
+	trimmed := trimSpace(text)
+	for _, pattern := range sectionStartPatterns {
+		if pattern.MatchString(trimmed) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimSpace trims whitespace the way JavaScript's String.prototype.trim does
+func trimSpace(s string) string {
+	return strings.TrimFunc(s, isJSSpace)
+}
+
+func isJSSpace(r rune) bool {
+	return unicode.IsSpace(r) || r == '\ufeff'
+}
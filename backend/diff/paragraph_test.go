@@ -0,0 +1,78 @@
+package diff
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func mustDecode(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("Failed to decode JSON: %v", err)
+	}
+	return v
+}
+
+func TestParseParagraphs(t *testing.T) {
+	data := mustDecode(t, `{
+		"pdf_info": [
+			{
+				"page_idx": 0,
+				"para_blocks": [
+					{"type": "title", "lines": [{"spans": [{"content": "采购合同"}]}]},
+					{"type": "text", "lines": [{"spans": [{"content": " 第一条 甲方应按时"}, {"content": "付款，"}]}]}
+				]
+			},
+			{
+				"page_idx": 1,
+				"para_blocks": [
+					{"type": "text", "lines": [{"spans": [{"content": "逾期按日计息。"}]}]},
+					{"type": "list", "blocks": [
+						{"type": "text", "lines": [{"spans": [{"content": "1. 交货"}]}]},
+						{"lines": [{"spans": [{"content": "2. 验收"}]}]}
+					]}
+				]
+			}
+		]
+	}`)
+
+	paragraphs := ParseParagraphs(data)
+
+	expected := []Paragraph{
+		{Text: "采购合同", Type: "title", PageIdx: 0},
+		{Text: "第一条 甲方应按时付款，逾期按日计息。", Type: "text", PageIdx: 0},
+		{Text: "1. 交货", Type: "text", PageIdx: 1},
+		{Text: "2. 验收", Type: "list", PageIdx: 1},
+	}
+
+	if len(paragraphs) != len(expected) {
+		t.Fatalf("Expected %d paragraphs, got %d: %+v", len(expected), len(paragraphs), paragraphs)
+	}
+	for i := range expected {
+		if paragraphs[i] != expected[i] {
+			t.Errorf("Paragraph %d: expected %+v, got %+v", i, expected[i], paragraphs[i])
+		}
+	}
+}
+
+func TestParseParagraphsInvalidInput(t *testing.T) {
+	if got := ParseParagraphs(nil); len(got) != 0 {
+		t.Errorf("Expected no paragraphs for nil input, got %d", len(got))
+	}
+	if got := ParseParagraphs(map[string]interface{}{"pdf_info": "bad"}); len(got) != 0 {
+		t.Errorf("Expected no paragraphs for malformed input, got %d", len(got))
+	}
+}
+
+func TestMergeCrossPageParagraphsKeepsNumberedClauses(t *testing.T) {
+	paragraphs := []Paragraph{
+		{Text: "甲方应当", PageIdx: 0},
+		{Text: "（二）乙方应当", PageIdx: 1},
+	}
+
+	merged := mergeCrossPageParagraphs(paragraphs)
+	if len(merged) != 2 {
+		t.Errorf("Expected numbered clause not to be merged, got %d paragraphs", len(merged))
+	}
+}
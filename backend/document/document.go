@@ -0,0 +1,161 @@
+package document
+
+import (
+	"strings"
+)
+
+// Block types. Composite blocks (table, image, list) hold their parts as
+// children, e.g. table_body and table_caption.
+const (
+	BlockText     = "text"
+	BlockTitle    = "title"
+	BlockList     = "list"
+	BlockTable    = "table"
+	BlockImage    = "image"
+	BlockEquation = "interline_equation"
+	BlockHeader   = "header"
+	BlockFooter   = "footer"
+)
+
+// Document is a parsed document
+type Document struct {
+	Backend string `json:"backend,omitempty"` // Producer, e.g. pipeline, vlm or docx
+	Pages   []Page `json:"pages"`
+}
+
+// Page is a page of a document. Sizes are in points.
+type Page struct {
+	Index     int     `json:"index"`
+	Width     float64 `json:"width"`
+	Height    float64 `json:"height"`
+	Blocks    []Block `json:"blocks"`
+	Discarded []Block `json:"discarded,omitempty"` // Headers, footers, page numbers
+}
+
+// Block is a layout block in reading order
+type Block struct {
+	Type     string  `json:"type"`
+	BBox     BBox    `json:"bbox,omitempty"`
+	Spans    []Span  `json:"spans,omitempty"`
+	Children []Block `json:"children,omitempty"`
+}
+
+// Span is a run of content within a block. Line numbers the source line
+// the span was on, counting from 0 within its block.
+type Span struct {
+	Type      string `json:"type"`
+	Content   string `json:"content"`
+	HTML      string `json:"html,omitempty"`       // Table spans
+	ImagePath string `json:"image_path,omitempty"` // Image and table spans
+	BBox      BBox   `json:"bbox,omitempty"`
+	Line      int    `json:"line"`
+}
+
+// Parse converts parser output, e.g. Contract.JSONData, to a Document
+func Parse(v any) (*Document, error) {
+	m, err := MiddleFrom(v)
+	if err != nil {
+		return nil, err
+	}
+	return m.Document(), nil
+}
+
+// Document normalizes the middle.json document
+func (m *Middle) Document() *Document {
+	doc := &Document{
+		Backend: string(m.Backend),
+		Pages:   make([]Page, 0, len(m.PDFInfo)),
+	}
+	for i, p := range m.PDFInfo {
+		page := Page{Index: int(p.PageIdx)}
+		if p.PageIdx == 0 {
+			page.Index = i // page_idx is missing in some releases
+		}
+		if len(p.PageSize) >= 2 {
+			page.Width, page.Height = p.PageSize[0], p.PageSize[1]
+		}
+
+		blocks := p.ParaBlocks
+		if blocks == nil {
+			blocks = p.PreprocBlocks
+		}
+		page.Blocks = normalizeBlocks(blocks)
+		page.Discarded = normalizeBlocks(p.DiscardedBlocks)
+		doc.Pages = append(doc.Pages, page)
+	}
+	return doc
+}
+
+func normalizeBlocks(blocks []MiddleBlock) []Block {
+	result := make([]Block, 0, len(blocks))
+	for _, b := range blocks {
+		block := Block{Type: string(b.Type), BBox: b.BBox}
+		for i, line := range b.Lines {
+			for _, s := range line.Spans {
+				content := s.Content
+				if content == "" {
+					content = s.Text
+				}
+				block.Spans = append(block.Spans, Span{
+					Type:      string(s.Type),
+					Content:   string(content),
+					HTML:      string(s.HTML),
+					ImagePath: string(s.ImagePath),
+					BBox:      s.BBox,
+					Line:      i,
+				})
+			}
+		}
+		if len(b.Blocks) > 0 {
+			block.Children = normalizeBlocks(b.Blocks)
+		}
+		result = append(result, block)
+	}
+	return result
+}
+
+// OwnText concatenates the block's own spans, without its children
+func (b *Block) OwnText() string {
+	var sb strings.Builder
+	for _, s := range b.Spans {
+		sb.WriteString(s.Content)
+	}
+	return sb.String()
+}
+
+// Text concatenates the block's spans and then those of its children
+func (b *Block) Text() string {
+	var sb strings.Builder
+	sb.WriteString(b.OwnText())
+	for i := range b.Children {
+		sb.WriteString(b.Children[i].Text())
+	}
+	return sb.String()
+}
+
+// Text concatenates the text of the page's blocks, one block per line
+func (p *Page) Text() string {
+	texts := make([]string, 0, len(p.Blocks))
+	for i := range p.Blocks {
+		if text := p.Blocks[i].Text(); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// Walk calls fn for every block of the document in reading order, parents
+// before their children, with the index of the page it is on. Discarded
+// blocks are skipped.
+func (d *Document) Walk(fn func(pageIdx int, b *Block)) {
+	var walk func(pageIdx int, blocks []Block)
+	walk = func(pageIdx int, blocks []Block) {
+		for i := range blocks {
+			fn(pageIdx, &blocks[i])
+			walk(pageIdx, blocks[i].Children)
+		}
+	}
+	for i := range d.Pages {
+		walk(d.Pages[i].Index, d.Pages[i].Blocks)
+	}
+}
@@ -0,0 +1,169 @@
+package document
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const testMiddle = `{
+	"_backend": "vlm",
+	"_version_name": "2.1.0",
+	"pdf_info": [
+		{
+			"page_idx": 0,
+			"page_size": [595, 842],
+			"para_blocks": [
+				{"type": "title", "bbox": [72, 72, 523, 90], "index": 0, "lines": [
+					{"bbox": [72, 72, 523, 90], "spans": [{"type": "text", "content": "采购合同", "bbox": [72, 72, 200, 90], "score": 0.99}]}
+				]},
+				{"type": "text", "bbox": [72, 100, 523, 140], "lines": [
+					{"spans": [{"type": "text", "content": "第一条 甲方"}]},
+					{"spans": [{"type": "text", "content": "应按时付款。"}]}
+				]},
+				{"type": "table", "bbox": [72, 150, 523, 300], "blocks": [
+					{"type": "table_caption", "lines": [{"spans": [{"type": "text", "content": "价格表"}]}]},
+					{"type": "table_body", "lines": [{"spans": [{"type": "table", "html": "<table><tr><td>1</td></tr></table>", "image_path": "t.jpg"}]}]}
+				]}
+			],
+			"discarded_blocks": [
+				{"type": "footer", "lines": [{"spans": [{"type": "text", "content": "第 1 页"}]}]}
+			]
+		},
+		{
+			"page_idx": 1,
+			"page_size": [595, 842],
+			"para_blocks": []
+		}
+	]
+}`
+
+func TestParse(t *testing.T) {
+	var v any
+	if err := json.Unmarshal([]byte(testMiddle), &v); err != nil {
+		t.Fatalf("Failed to decode JSON: %v", err)
+	}
+
+	doc, err := Parse(v)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if doc.Backend != "vlm" || len(doc.Pages) != 2 {
+		t.Fatalf("Expected 2 pages from vlm, got %s %d", doc.Backend, len(doc.Pages))
+	}
+
+	page := doc.Pages[0]
+	if page.Width != 595 || page.Height != 842 || len(page.Blocks) != 3 || len(page.Discarded) != 1 {
+		t.Fatalf("Unexpected page: %+v", page)
+	}
+
+	title := page.Blocks[0]
+	if title.Type != BlockTitle || title.Text() != "采购合同" {
+		t.Errorf("Expected title '采购合同', got %s '%s'", title.Type, title.Text())
+	}
+	if len(title.BBox) != 4 || title.Spans[0].BBox[2] != 200 {
+		t.Errorf("Expected bboxes to be kept, got %v %v", title.BBox, title.Spans[0].BBox)
+	}
+
+	text := page.Blocks[1]
+	if text.Text() != "第一条 甲方应按时付款。" || text.Spans[1].Line != 1 {
+		t.Errorf("Unexpected text block: %+v", text)
+	}
+
+	table := page.Blocks[2]
+	if len(table.Children) != 2 || table.OwnText() != "" || table.Text() != "价格表" {
+		t.Errorf("Unexpected table block: %+v", table)
+	}
+	if span := table.Children[1].Spans[0]; span.HTML == "" || span.ImagePath != "t.jpg" || span.Content != "" {
+		t.Errorf("Expected table HTML kept out of content, got %+v", span)
+	}
+
+	if got := page.Text(); got != "采购合同\n第一条 甲方应按时付款。\n价格表" {
+		t.Errorf("Unexpected page text: %q", got)
+	}
+
+	var types []string
+	doc.Walk(func(pageIdx int, b *Block) {
+		types = append(types, b.Type)
+	})
+	if len(types) != 5 || types[2] != BlockTable || types[3] != "table_caption" {
+		t.Errorf("Unexpected walk order: %v", types)
+	}
+}
+
+func TestDecodeMiddleToleratesDrift(t *testing.T) {
+	m, err := DecodeMiddle([]byte(`{
+		"pdf_info": [
+			{
+				"page_size": ["612", "792"],
+				"preproc_blocks": [
+					{"type": "text", "bbox": null, "index": "3", "new_field": {"x": 1}, "lines": [
+						{"bbox": "bad", "spans": [{"type": "text", "text": "旧版本字段", "score": "0.5"}]}
+					]}
+				]
+			},
+			{"page_idx": 1, "para_blocks": [{"type": 7, "lines": []}]}
+		],
+		"_backend": 2
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	doc := m.Document()
+	if doc.Backend != "2" || len(doc.Pages) != 2 {
+		t.Fatalf("Unexpected document: %+v", doc)
+	}
+	page := doc.Pages[0]
+	if page.Width != 612 || page.Height != 792 {
+		t.Errorf("Expected page size from strings, got %vx%v", page.Width, page.Height)
+	}
+	if len(page.Blocks) != 1 || page.Blocks[0].Text() != "旧版本字段" || page.Blocks[0].BBox != nil {
+		t.Errorf("Expected preproc block with text, got %+v", page.Blocks)
+	}
+	if doc.Pages[1].Index != 1 || doc.Pages[1].Blocks[0].Type != "7" {
+		t.Errorf("Unexpected second page: %+v", doc.Pages[1])
+	}
+
+	if _, err := DecodeMiddle([]byte(`{"pdf_info": {}}`)); err == nil {
+		t.Error("Expected error for pdf_info that is not a list")
+	}
+}
+
+func TestDecodeMiddleContentList(t *testing.T) {
+	m, err := DecodeMiddle([]byte(`[
+		{"type": "text", "text": "采购合同", "text_level": 1, "page_idx": 0},
+		{"type": "text", "text": "第一条", "page_idx": 0},
+		{"type": "table", "table_body": "<table></table>", "page_idx": 2}
+	]`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	doc := m.Document()
+	if len(doc.Pages) != 3 {
+		t.Fatalf("Expected 3 pages, got %d", len(doc.Pages))
+	}
+	if blocks := doc.Pages[0].Blocks; len(blocks) != 2 || blocks[0].Type != BlockTitle || blocks[1].Text() != "第一条" {
+		t.Errorf("Unexpected first page: %+v", blocks)
+	}
+	if len(doc.Pages[1].Blocks) != 0 || doc.Pages[2].Blocks[0].Spans[0].HTML != "<table></table>" {
+		t.Errorf("Unexpected pages: %+v", doc.Pages[1:])
+	}
+
+	for _, pageIdx := range []string{"-1", "10000", "1e12"} {
+		if _, err := DecodeMiddle([]byte(`[{"type": "text", "text": "第一条", "page_idx": ` + pageIdx + `}]`)); err == nil {
+			t.Errorf("Expected error for page_idx %s", pageIdx)
+		}
+	}
+}
+
+func TestMiddleFrom(t *testing.T) {
+	for _, v := range []any{nil, []byte(testMiddle), json.RawMessage(testMiddle), testMiddle} {
+		if _, err := MiddleFrom(v); err != nil {
+			t.Errorf("Unexpected error for %T: %v", v, err)
+		}
+	}
+	if _, err := MiddleFrom(func() {}); err == nil {
+		t.Error("Expected error for a value that is not JSON")
+	}
+}
@@ -0,0 +1,215 @@
+// Package document models parser output. Middle mirrors MinerU's
+// middle.json (pdf_info → para_blocks → lines → spans); Document is the
+// normalized form server-side features work with.
+package document
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Middle is a MinerU middle.json document. Decoding is lenient: unknown
+// fields are ignored, numbers may be strings, and scalars or boxes of an
+// unexpected type decode to their zero value instead of failing the
+// document.
+type Middle struct {
+	PDFInfo     []MiddlePage `json:"pdf_info"`
+	Backend     flexString   `json:"_backend"`      // pipeline, vlm, docx, ...
+	VersionName flexString   `json:"_version_name"` // MinerU version that produced the file
+}
+
+// MiddlePage is an entry of pdf_info
+type MiddlePage struct {
+	PageIdx         flexInt       `json:"page_idx"`
+	PageSize        BBox          `json:"page_size"` // [width, height]
+	ParaBlocks      []MiddleBlock `json:"para_blocks"`
+	PreprocBlocks   []MiddleBlock `json:"preproc_blocks"` // Used when para_blocks is absent
+	DiscardedBlocks []MiddleBlock `json:"discarded_blocks"`
+}
+
+// MiddleBlock is a layout block. Composite blocks such as tables, images
+// and lists hold their parts in Blocks.
+type MiddleBlock struct {
+	Type   flexString    `json:"type"`
+	BBox   BBox          `json:"bbox"`
+	Index  flexInt       `json:"index"`
+	Lines  []MiddleLine  `json:"lines"`
+	Blocks []MiddleBlock `json:"blocks"`
+}
+
+// MiddleLine is a line of spans
+type MiddleLine struct {
+	BBox  BBox         `json:"bbox"`
+	Spans []MiddleSpan `json:"spans"`
+}
+
+// MiddleSpan is a run of content. Text spans carry Content; table spans
+// may carry HTML and image spans ImagePath instead.
+type MiddleSpan struct {
+	Type      flexString `json:"type"`
+	Content   flexString `json:"content"`
+	Text      flexString `json:"text"` // Older releases
+	HTML      flexString `json:"html"`
+	ImagePath flexString `json:"image_path"`
+	BBox      BBox       `json:"bbox"`
+	Score     flexFloat  `json:"score"`
+}
+
+// DecodeMiddle decodes middle.json. A content_list.json array is accepted
+// as well and converted to one block per item.
+func DecodeMiddle(data []byte) (*Middle, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var items []contentItem
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("failed to decode content list: %w", err)
+		}
+		return middleFromContentList(items)
+	}
+
+	var m Middle
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode middle.json: %w", err)
+	}
+	return &m, nil
+}
+
+// MiddleFrom converts a decoded JSON value, such as Contract.JSONData, to
+// Middle
+func MiddleFrom(v any) (*Middle, error) {
+	switch d := v.(type) {
+	case nil:
+		return &Middle{}, nil
+	case *Middle:
+		return d, nil
+	case []byte:
+		return DecodeMiddle(d)
+	case json.RawMessage:
+		return DecodeMiddle(d)
+	case string:
+		return DecodeMiddle([]byte(d))
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode document: %w", err)
+	}
+	return DecodeMiddle(data)
+}
+
+// contentItem is an entry of content_list.json
+type contentItem struct {
+	Type      flexString `json:"type"`
+	Text      flexString `json:"text"`
+	TextLevel flexInt    `json:"text_level"`
+	TableBody flexString `json:"table_body"`
+	ImgPath   flexString `json:"img_path"`
+	PageIdx   flexInt    `json:"page_idx"`
+	BBox      BBox       `json:"bbox"`
+}
+
+// MaxContentListPages bounds the page_idx of content list items. A content
+// list declares no page count, and pages are allocated up to the highest
+// index.
+const MaxContentListPages = 10000
+
+func middleFromContentList(items []contentItem) (*Middle, error) {
+	m := &Middle{}
+	for i, item := range items {
+		if item.PageIdx < 0 || item.PageIdx >= MaxContentListPages {
+			return nil, fmt.Errorf("content list item %d has page_idx %d outside [0, %d)", i, item.PageIdx, MaxContentListPages)
+		}
+		for len(m.PDFInfo) <= int(item.PageIdx) {
+			m.PDFInfo = append(m.PDFInfo, MiddlePage{PageIdx: flexInt(len(m.PDFInfo))})
+		}
+
+		block := MiddleBlock{Type: item.Type, BBox: item.BBox}
+		span := MiddleSpan{Type: "text", Content: item.Text, BBox: item.BBox}
+		switch {
+		case item.Type == "table":
+			span = MiddleSpan{Type: "table", HTML: item.TableBody, BBox: item.BBox}
+		case item.Type == "image":
+			span = MiddleSpan{Type: "image", ImagePath: item.ImgPath, BBox: item.BBox}
+		case item.TextLevel > 0:
+			block.Type = BlockTitle
+		}
+		block.Lines = []MiddleLine{{BBox: item.BBox, Spans: []MiddleSpan{span}}}
+
+		page := &m.PDFInfo[item.PageIdx]
+		block.Index = flexInt(len(page.ParaBlocks))
+		page.ParaBlocks = append(page.ParaBlocks, block)
+	}
+	return m, nil
+}
+
+// BBox is a box as [x0, y0, x1, y1] in points, or [width, height] for page
+// sizes. Malformed values decode to nil.
+type BBox []float64
+
+func (b *BBox) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil || raw == nil {
+		*b = nil
+		return nil
+	}
+	box := make(BBox, 0, len(raw))
+	for _, r := range raw {
+		var f flexFloat
+		f.UnmarshalJSON(r)
+		box = append(box, float64(f))
+	}
+	*b = box
+	return nil
+}
+
+// flexString decodes strings and numbers; other values decode to ""
+type flexString string
+
+func (s *flexString) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch t := v.(type) {
+	case string:
+		*s = flexString(t)
+	case float64:
+		*s = flexString(strconv.FormatFloat(t, 'f', -1, 64))
+	default:
+		*s = ""
+	}
+	return nil
+}
+
+// flexFloat decodes numbers and numeric strings; other values decode to 0
+type flexFloat float64
+
+func (f *flexFloat) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*f = 0
+	switch t := v.(type) {
+	case float64:
+		*f = flexFloat(t)
+	case string:
+		if n, err := strconv.ParseFloat(t, 64); err == nil {
+			*f = flexFloat(n)
+		}
+	}
+	return nil
+}
+
+// flexInt decodes like flexFloat, truncating to an integer
+type flexInt int
+
+func (i *flexInt) UnmarshalJSON(data []byte) error {
+	var f flexFloat
+	if err := f.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	*i = flexInt(f)
+	return nil
+}
@@ -2,8 +2,11 @@ package handler
 
 import (
 	"encoding/json"
+	"log/slog"
 	"net/http"
+	"sync/atomic"
 
+	"github.com/AnTengye/contractdiff/backend/middleware"
 	"github.com/AnTengye/contractdiff/backend/model"
 	"github.com/AnTengye/contractdiff/backend/service"
 	"github.com/gin-gonic/gin"
@@ -11,31 +14,55 @@ import (
 
 type CallbackHandler struct {
 	mineruService *service.MineruService
-	store         *service.ContractStore
+	queue         *service.ParseQueue // Ingests results like the poller does
+	store         service.ContractStore
+	stats         callbackCounters
 }
 
-func NewCallbackHandler(mineruSvc *service.MineruService) *CallbackHandler {
+func NewCallbackHandler(mineruSvc *service.MineruService, queue *service.ParseQueue) *CallbackHandler {
 	return &CallbackHandler{
 		mineruService: mineruSvc,
+		queue:         queue,
 		store:         service.GetContractStore(),
 	}
 }
 
+// CallbackStats counts callbacks by outcome
+type CallbackStats struct {
+	Accepted   int64 `json:"accepted"`
+	Forged     int64 `json:"forged"`     // Checksum did not verify
+	Mismatched int64 `json:"mismatched"` // Task ID differs from the contract's task
+	Duplicate  int64 `json:"duplicate"`  // Contract had already finished
+}
+
+type callbackCounters struct {
+	accepted   atomic.Int64
+	forged     atomic.Int64
+	mismatched atomic.Int64
+	duplicate  atomic.Int64
+}
+
+// Stats returns the callback counters since startup
+func (h *CallbackHandler) Stats() CallbackStats {
+	return CallbackStats{
+		Accepted:   h.stats.accepted.Load(),
+		Forged:     h.stats.forged.Load(),
+		Mismatched: h.stats.mismatched.Load(),
+		Duplicate:  h.stats.duplicate.Load(),
+	}
+}
+
 type CallbackRequest struct {
 	Checksum string `json:"checksum"`
 	Content  string `json:"content"`
 }
 
 type CallbackContent struct {
-	TaskID    string `json:"task_id"`
-	DataID    string `json:"data_id"`
-	State     string `json:"state"`
-	FullPages []struct {
-		PageNo  int    `json:"page_no"`
-		MDURL   string `json:"md_url"`
-		JsonURL string `json:"json_url"`
-	} `json:"full_pages"`
-	ErrorMsg string `json:"err_msg"`
+	TaskID     string `json:"task_id"`
+	DataID     string `json:"data_id"`
+	State      string `json:"state"`
+	FullZipURL string `json:"full_zip_url"`
+	ErrorMsg   string `json:"err_msg"`
 }
 
 // HandleCallback receives callback from MinerU
@@ -46,6 +73,27 @@ func (h *CallbackHandler) HandleCallback(c *gin.Context) {
 		return
 	}
 
+	// The endpoint is public; only MinerU knows uid + seed, so without them
+	// no callback can be trusted
+	if !h.mineruService.CallbacksConfigured() {
+		h.stats.forged.Add(1)
+		slog.Warn("rejected callback, mineru.uid or mineru.seed is not configured",
+			"request_id", middleware.GetRequestID(c),
+			"client_ip", c.ClientIP(),
+		)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Callbacks are not configured"})
+		return
+	}
+	if !h.mineruService.VerifyCallbackPayload(&service.MineruCallbackPayload{Checksum: req.Checksum, Content: req.Content}) {
+		h.stats.forged.Add(1)
+		slog.Warn("rejected callback with invalid checksum",
+			"request_id", middleware.GetRequestID(c),
+			"client_ip", c.ClientIP(),
+		)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid checksum"})
+		return
+	}
+
 	// Parse content
 	var content CallbackContent
 	if err := json.Unmarshal([]byte(req.Content), &content); err != nil {
@@ -54,28 +102,65 @@ func (h *CallbackHandler) HandleCallback(c *gin.Context) {
 	}
 
 	// Find contract by DataID (which is our contractID)
-	contract := h.store.Get(content.DataID)
+	contract, err := h.store.Get(content.DataID)
+	if err != nil {
+		slog.Error("failed to load contract for callback",
+			"contract_id", content.DataID,
+			"error", err,
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load contract"})
+		return
+	}
 	if contract == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Contract not found"})
 		return
 	}
 
-	// Update contract based on callback
-	switch content.State {
-	case "done":
-		if len(content.FullPages) > 0 && content.FullPages[0].JsonURL != "" {
-			jsonData, err := h.mineruService.FetchJSONResult(content.FullPages[0].JsonURL)
-			if err != nil {
-				h.store.UpdateStatus(contract.ID, model.StatusFailed, "Failed to fetch JSON: "+err.Error())
-			} else {
-				h.store.UpdateJSONData(contract.ID, jsonData)
-			}
-		} else {
-			h.store.UpdateStatus(contract.ID, model.StatusCompleted, "")
+	if content.TaskID == "" || content.TaskID != contract.MineruTaskID {
+		h.stats.mismatched.Add(1)
+		slog.Warn("rejected callback for unexpected task",
+			"contract_id", contract.ID,
+			"task_id", content.TaskID,
+			"expected_task_id", contract.MineruTaskID,
+		)
+		c.JSON(http.StatusConflict, gin.H{"error": "Task ID does not match contract"})
+		return
+	}
+
+	// Duplicate or late callbacks must not overwrite a finished contract
+	if contract.Status == model.StatusCompleted || contract.Status == model.StatusFailed {
+		h.stats.duplicate.Add(1)
+		slog.Info("ignored callback for finished contract",
+			"contract_id", contract.ID,
+			"task_id", content.TaskID,
+			"status", contract.Status,
+			"state", content.State,
+		)
+		c.JSON(http.StatusOK, gin.H{"message": "Callback ignored"})
+		return
+	}
+
+	// Finished tasks are handed to the queue, whose workers ingest them like
+	// polled ones after this responds
+	status := service.MineruCallbackStatus(content.State, content.FullZipURL, content.ErrorMsg)
+	if status.State == service.ParseStateDone || status.State == service.ParseStateFailed {
+		delivered, err := h.queue.Deliver(contract.ID, status)
+		if err != nil {
+			slog.Error("failed to update contract from callback",
+				"contract_id", contract.ID,
+				"error", err,
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update contract"})
+			return
+		}
+		if !delivered {
+			// The poller finished the contract first, or this is a replay
+			h.stats.duplicate.Add(1)
+			c.JSON(http.StatusOK, gin.H{"message": "Callback ignored"})
+			return
 		}
-	case "failed":
-		h.store.UpdateStatus(contract.ID, model.StatusFailed, content.ErrorMsg)
 	}
 
+	h.stats.accepted.Add(1)
 	c.JSON(http.StatusOK, gin.H{"message": "Callback received"})
 }
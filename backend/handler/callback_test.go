@@ -1,31 +1,111 @@
 package handler
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/AnTengye/contractdiff/backend/config"
 	"github.com/AnTengye/contractdiff/backend/model"
 	"github.com/AnTengye/contractdiff/backend/service"
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	testCallbackUID  = "test-uid"
+	testCallbackSeed = "test-seed"
+)
+
+func newTestCallbackHandler() *CallbackHandler {
+	return newTestCallbackHandlerWithSeed(testCallbackSeed)
+}
+
+func newTestCallbackHandlerWithSeed(seed string) *CallbackHandler {
+	return newTestCallbackHandlerWithSecrets(testCallbackUID, seed)
+}
+
+// newTestCallbackHandlerWithSecrets returns a handler whose results are
+// ingested by a parse queue over the global store
+func newTestCallbackHandlerWithSecrets(uid, seed string) *CallbackHandler {
+	mineruSvc := service.NewMineruService(&config.MineruConfig{
+		UID:  uid,
+		Seed: seed,
+	})
+	parsers, _ := service.NewParserRouter(&config.ParserConfig{}, service.NewMineruParser(mineruSvc))
+	queue := service.NewParseQueue(service.GetContractStore(), parsers, nil, &config.QueueConfig{})
+	return NewCallbackHandler(mineruSvc, queue)
+}
+
+// startQueue runs the handler's parse queue until the test ends
+func startQueue(t *testing.T, handler *CallbackHandler) {
+	t.Helper()
+	if err := handler.queue.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start queue: %v", err)
+	}
+	t.Cleanup(handler.queue.Stop)
+}
+
+// waitForContract waits until a queue worker moves the contract to status
+func waitForContract(t *testing.T, store service.ContractStore, id, status string) *model.Contract {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		contract, _ := store.Get(id)
+		if contract != nil && contract.Status == status {
+			return contract
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for status %s, got %+v", status, contract)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// signedCallback builds a callback body with a valid checksum for content
+func signedCallback(content string) map[string]interface{} {
+	hash := sha256.Sum256([]byte(testCallbackUID + testCallbackSeed + content))
+	return map[string]interface{}{
+		"checksum": hex.EncodeToString(hash[:]),
+		"content":  content,
+	}
+}
+
+func postCallback(handler *CallbackHandler, body map[string]interface{}) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.POST("/callback", handler.HandleCallback)
+
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", "/callback", bytes.NewBuffer(data))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
 func TestCallbackHandlerHandleCallback(t *testing.T) {
 	store := service.GetContractStore()
 
 	// Create a test contract
 	contract := &model.Contract{
-		ID:        "callback-test",
-		Tenant:    "tenant1",
-		Status:    model.StatusProcessing,
-		CreatedAt: time.Now(),
+		ID:           "callback-test",
+		Tenant:       "tenant1",
+		Status:       model.StatusProcessing,
+		MineruTaskID: "task-1",
+		CreatedAt:    time.Now(),
 	}
 	store.Save(contract)
 
-	handler := NewCallbackHandler(nil) // MineruService not needed for most tests
+	handler := newTestCallbackHandler()
 
 	tests := []struct {
 		name           string
@@ -33,35 +113,23 @@ func TestCallbackHandlerHandleCallback(t *testing.T) {
 		expectedStatus int
 	}{
 		{
-			name: "done callback",
-			body: map[string]interface{}{
-				"checksum": "test-checksum",
-				"content":  `{"task_id":"task-1","data_id":"callback-test","state":"done","full_pages":[]}`,
-			},
+			name:           "done callback",
+			body:           signedCallback(`{"task_id":"task-1","data_id":"callback-test","state":"done","full_pages":[]}`),
 			expectedStatus: http.StatusOK,
 		},
 		{
-			name: "failed callback",
-			body: map[string]interface{}{
-				"checksum": "test-checksum",
-				"content":  `{"task_id":"task-1","data_id":"callback-test","state":"failed","err_msg":"test error"}`,
-			},
+			name:           "failed callback",
+			body:           signedCallback(`{"task_id":"task-1","data_id":"callback-test","state":"failed","err_msg":"test error"}`),
 			expectedStatus: http.StatusOK,
 		},
 		{
-			name: "non-existent contract",
-			body: map[string]interface{}{
-				"checksum": "test-checksum",
-				"content":  `{"task_id":"task-1","data_id":"non-existent","state":"done"}`,
-			},
+			name:           "non-existent contract",
+			body:           signedCallback(`{"task_id":"task-1","data_id":"non-existent","state":"done"}`),
 			expectedStatus: http.StatusNotFound,
 		},
 		{
-			name: "invalid content format",
-			body: map[string]interface{}{
-				"checksum": "test-checksum",
-				"content":  "invalid json",
-			},
+			name:           "invalid content format",
+			body:           signedCallback("invalid json"),
 			expectedStatus: http.StatusBadRequest,
 		},
 	}
@@ -71,15 +139,7 @@ func TestCallbackHandlerHandleCallback(t *testing.T) {
 			// Reset contract status for each test
 			store.UpdateStatus("callback-test", model.StatusProcessing, "")
 
-			router := gin.New()
-			router.POST("/callback", handler.HandleCallback)
-
-			body, _ := json.Marshal(tt.body)
-			req := httptest.NewRequest("POST", "/callback", bytes.NewBuffer(body))
-			req.Header.Set("Content-Type", "application/json")
-			w := httptest.NewRecorder()
-
-			router.ServeHTTP(w, req)
+			w := postCallback(handler, tt.body)
 
 			if w.Code != tt.expectedStatus {
 				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
@@ -92,7 +152,7 @@ func TestCallbackHandlerHandleCallback(t *testing.T) {
 }
 
 func TestCallbackHandlerInvalidRequest(t *testing.T) {
-	handler := NewCallbackHandler(nil)
+	handler := newTestCallbackHandler()
 
 	router := gin.New()
 	router.POST("/callback", handler.HandleCallback)
@@ -112,41 +172,199 @@ func TestCallbackHandlerFailedState(t *testing.T) {
 	store := service.GetContractStore()
 
 	contract := &model.Contract{
-		ID:        "callback-failed-test",
-		Tenant:    "tenant1",
-		Status:    model.StatusProcessing,
-		CreatedAt: time.Now(),
+		ID:           "callback-failed-test",
+		Tenant:       "tenant1",
+		Status:       model.StatusProcessing,
+		MineruTaskID: "task-1",
+		CreatedAt:    time.Now(),
 	}
 	store.Save(contract)
 
-	handler := NewCallbackHandler(nil)
-
-	router := gin.New()
-	router.POST("/callback", handler.HandleCallback)
-
-	body, _ := json.Marshal(map[string]interface{}{
-		"checksum": "test-checksum",
-		"content":  `{"task_id":"task-1","data_id":"callback-failed-test","state":"failed","err_msg":"extraction failed"}`,
-	})
+	handler := newTestCallbackHandler()
+	startQueue(t, handler)
 
-	req := httptest.NewRequest("POST", "/callback", bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
+	w := postCallback(handler, signedCallback(
+		`{"task_id":"task-1","data_id":"callback-failed-test","state":"failed","err_msg":"extraction failed"}`,
+	))
 
 	if w.Code != http.StatusOK {
 		t.Errorf("Expected status 200, got %d", w.Code)
 	}
 
 	// Verify status was updated
-	updated := store.Get("callback-failed-test")
-	if updated.Status != model.StatusFailed {
-		t.Errorf("Expected status '%s', got '%s'", model.StatusFailed, updated.Status)
-	}
+	updated := waitForContract(t, store, "callback-failed-test", model.StatusFailed)
 	if updated.ErrorMsg != "extraction failed" {
 		t.Errorf("Expected error msg 'extraction failed', got '%s'", updated.ErrorMsg)
 	}
 
 	store.Delete("callback-failed-test")
 }
+
+func TestCallbackHandlerRejectsForgedAndMismatched(t *testing.T) {
+	store := service.GetContractStore()
+	store.Save(&model.Contract{
+		ID:           "callback-forged-test",
+		Tenant:       "tenant1",
+		Status:       model.StatusProcessing,
+		MineruTaskID: "task-1",
+		CreatedAt:    time.Now(),
+	})
+	defer store.Delete("callback-forged-test")
+
+	handler := newTestCallbackHandler()
+	content := `{"task_id":"task-1","data_id":"callback-forged-test","state":"done","full_pages":[]}`
+
+	w := postCallback(handler, map[string]interface{}{"checksum": "forged", "content": content})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for forged checksum, got %d", w.Code)
+	}
+
+	// A valid signature for a different seed is forged as well
+	other := newTestCallbackHandlerWithSeed("other-seed")
+	if w := postCallback(other, signedCallback(content)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for checksum with another seed, got %d", w.Code)
+	}
+
+	w = postCallback(handler, signedCallback(
+		`{"task_id":"task-2","data_id":"callback-forged-test","state":"done","full_pages":[]}`,
+	))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for mismatched task ID, got %d", w.Code)
+	}
+
+	contract, _ := store.Get("callback-forged-test")
+	if contract.Status != model.StatusProcessing {
+		t.Errorf("Expected status to stay '%s', got '%s'", model.StatusProcessing, contract.Status)
+	}
+
+	stats := handler.Stats()
+	if stats.Forged != 1 || stats.Mismatched != 1 || stats.Accepted != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestCallbackHandlerRejectsWithoutUID(t *testing.T) {
+	store := service.GetContractStore()
+	store.Save(&model.Contract{
+		ID:           "callback-no-uid-test",
+		Tenant:       "tenant1",
+		Status:       model.StatusProcessing,
+		MineruTaskID: "task-1",
+		CreatedAt:    time.Now(),
+	})
+	defer store.Delete("callback-no-uid-test")
+
+	// Without a UID the checksum only depends on the seed, which is not
+	// secret enough to trust
+	handler := newTestCallbackHandlerWithSecrets("", testCallbackSeed)
+	content := `{"task_id":"task-1","data_id":"callback-no-uid-test","state":"done","full_zip_url":"http://169.254.169.254/result.zip"}`
+	hash := sha256.Sum256([]byte(testCallbackSeed + content))
+	w := postCallback(handler, map[string]interface{}{"checksum": hex.EncodeToString(hash[:]), "content": content})
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without a UID, got %d", w.Code)
+	}
+
+	contract, _ := store.Get("callback-no-uid-test")
+	if contract.Status != model.StatusProcessing {
+		t.Errorf("Expected status to stay '%s', got '%s'", model.StatusProcessing, contract.Status)
+	}
+	if stats := handler.Stats(); stats.Forged != 1 || stats.Accepted != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestCallbackHandlerIgnoresDuplicates(t *testing.T) {
+	store := service.GetContractStore()
+	store.Save(&model.Contract{
+		ID:           "callback-duplicate-test",
+		Tenant:       "tenant1",
+		Status:       model.StatusProcessing,
+		MineruTaskID: "task-1",
+		CreatedAt:    time.Now(),
+	})
+	defer store.Delete("callback-duplicate-test")
+
+	handler := newTestCallbackHandler()
+	startQueue(t, handler)
+
+	done := signedCallback(`{"task_id":"task-1","data_id":"callback-duplicate-test","state":"done","full_pages":[]}`)
+	if w := postCallback(handler, done); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	waitForContract(t, store, "callback-duplicate-test", model.StatusCompleted)
+
+	// A replay and a late failure must not change the completed contract
+	if w := postCallback(handler, done); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for replayed callback, got %d", w.Code)
+	}
+	late := signedCallback(`{"task_id":"task-1","data_id":"callback-duplicate-test","state":"failed","err_msg":"late"}`)
+	if w := postCallback(handler, late); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for late callback, got %d", w.Code)
+	}
+
+	contract, _ := store.Get("callback-duplicate-test")
+	if contract.Status != model.StatusCompleted || contract.ErrorMsg != "" {
+		t.Errorf("Expected completed contract without error, got %s '%s'", contract.Status, contract.ErrorMsg)
+	}
+
+	stats := handler.Stats()
+	if stats.Accepted != 1 || stats.Duplicate != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestCallbackHandlerIngestsResultZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("result/middle.json")
+	io.WriteString(w, `{"pdf_info": [{"page_idx": 0, "para_blocks": []}], "_backend": "vlm"}`)
+	zw.Close()
+
+	var downloads atomic.Int32
+	results := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		w.Write(buf.Bytes())
+	}))
+	defer results.Close()
+
+	store := service.GetContractStore()
+	store.Save(&model.Contract{
+		ID:           "callback-zip-test",
+		Tenant:       "tenant1",
+		Status:       model.StatusProcessing,
+		MineruTaskID: "task-1",
+		CreatedAt:    time.Now(),
+	})
+	defer store.Delete("callback-zip-test")
+
+	handler := newTestCallbackHandler()
+	content, _ := json.Marshal(CallbackContent{
+		TaskID:     "task-1",
+		DataID:     "callback-zip-test",
+		State:      "done",
+		FullZipURL: results.URL + "/result.zip",
+	})
+	if w := postCallback(handler, signedCallback(string(content))); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	// The callback responds before the result is downloaded
+	if contract, _ := store.Get("callback-zip-test"); contract.Status != model.StatusProcessing || downloads.Load() != 0 {
+		t.Errorf("Expected the result to be left to the queue, got %s after %d downloads", contract.Status, downloads.Load())
+	}
+
+	// A queue worker stores what polling the same task would store
+	startQueue(t, handler)
+	contract := waitForContract(t, store, "callback-zip-test", model.StatusCompleted)
+	z, err := service.OpenResultZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()), config.ResultLimits{})
+	if err != nil {
+		t.Fatalf("Failed to open ZIP: %v", err)
+	}
+	expected, err := z.ExtractJSON()
+	if err != nil {
+		t.Fatalf("Failed to extract JSON: %v", err)
+	}
+	if contract.Status != model.StatusCompleted || !reflect.DeepEqual(contract.JSONData, expected) {
+		t.Errorf("Expected completed contract with %v, got %s %v", expected, contract.Status, contract.JSONData)
+	}
+}
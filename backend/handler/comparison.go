@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"cmp"
+	"log/slog"
+	"net/http"
+
+	"github.com/AnTengye/contractdiff/backend/diff"
+	"github.com/AnTengye/contractdiff/backend/middleware"
+	"github.com/AnTengye/contractdiff/backend/model"
+	"github.com/AnTengye/contractdiff/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+type ComparisonHandler struct {
+	store    service.ContractStore
+	profiles *diff.Profiles
+}
+
+func NewComparisonHandler(profiles *diff.Profiles) *ComparisonHandler {
+	return &ComparisonHandler{
+		store:    service.GetContractStore(),
+		profiles: profiles,
+	}
+}
+
+type CompareRequest struct {
+	LeftID        string `json:"left_id" binding:"required"`
+	RightID       string `json:"right_id" binding:"required"`
+	LeftRevision  int    `json:"left_revision"` // Run number of the left result, 0 = current
+	RightRevision int    `json:"right_revision"`
+	Profile       string `json:"profile"`   // Normalization profile, "" = default
+	Algorithm     string `json:"algorithm"` // Pairing algorithm, "" = diff.DefaultAlgorithm
+}
+
+// Compare compares two completed contracts and returns paragraph pairs with
+// character-level diffs. Earlier revisions can be compared as well, e.g.
+// the same contract parsed by two models.
+func (h *ComparisonHandler) Compare(c *gin.Context) {
+	tenant := middleware.GetTenant(c)
+	requestID := middleware.GetRequestID(c)
+
+	var req CompareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	norm, profile, ok := h.profiles.Get(req.Profile)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown normalization profile"})
+		return
+	}
+	algorithm := cmp.Or(req.Algorithm, diff.DefaultAlgorithm)
+	if !diff.IsAlgorithm(algorithm) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown pairing algorithm"})
+		return
+	}
+
+	left, ok := loadContract(c, h.store, req.LeftID, tenant)
+	if !ok {
+		return
+	}
+	right, ok := loadContract(c, h.store, req.RightID, tenant)
+	if !ok {
+		return
+	}
+	leftData, leftRevision, ok := revisionData(c, left, req.LeftRevision)
+	if !ok {
+		return
+	}
+	rightData, rightRevision, ok := revisionData(c, right, req.RightRevision)
+	if !ok {
+		return
+	}
+
+	result := diff.Compare(diff.ParseParagraphs(leftData), diff.ParseParagraphs(rightData), norm, algorithm)
+
+	slog.Info("contracts compared",
+		"request_id", requestID,
+		"tenant", tenant,
+		"left_id", left.ID,
+		"right_id", right.ID,
+		"left_revision", leftRevision,
+		"right_revision", rightRevision,
+		"profile", profile,
+		"algorithm", algorithm,
+		"pairs", len(result.Pairs),
+		"changes", result.Stats.Total,
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"left_id":        left.ID,
+		"right_id":       right.ID,
+		"left_revision":  leftRevision,
+		"right_revision": rightRevision,
+		"profile":        profile,
+		"algorithm":      algorithm,
+		"pairs":          result.Pairs,
+		"changes":        result.Changes,
+		"stats":          result.Stats,
+	})
+}
+
+// Profiles lists the normalization profiles comparisons can select, with
+// the rules of each
+func (h *ComparisonHandler) Profiles(c *gin.Context) {
+	profiles := []gin.H{}
+	for _, name := range h.profiles.Names() {
+		norm, _, _ := h.profiles.Get(name)
+		profiles = append(profiles, gin.H{"name": name, "rules": norm.Rules()})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"default":  h.profiles.Default(),
+		"profiles": profiles,
+	})
+}
+
+// revisionData returns the parse result of a contract's run number n, 0
+// meaning the current run, together with the run number. It writes a 404
+// or 409 response and returns false if there is no such result.
+func revisionData(c *gin.Context, contract *model.Contract, n int) (any, int, bool) {
+	current := service.CurrentRevision(contract)
+	if n == 0 || n == current {
+		if contract.Status != model.StatusCompleted {
+			c.JSON(http.StatusConflict, gin.H{"error": "Contract is not completed"})
+			return nil, 0, false
+		}
+		return contract.JSONData, current, true
+	}
+	revision := service.FindRevision(contract, n)
+	if revision == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return nil, 0, false
+	}
+	return revision.JSONData, n, true
+}
@@ -0,0 +1,386 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/diff"
+	"github.com/AnTengye/contractdiff/backend/model"
+	"github.com/gin-gonic/gin"
+)
+
+func testContractJSON(texts ...string) map[string]interface{} {
+	blocks := make([]interface{}, len(texts))
+	for i, text := range texts {
+		blocks[i] = map[string]interface{}{
+			"type": "text",
+			"lines": []interface{}{
+				map[string]interface{}{
+					"spans": []interface{}{
+						map[string]interface{}{"content": text},
+					},
+				},
+			},
+		}
+	}
+	return map[string]interface{}{
+		"pdf_info": []interface{}{
+			map[string]interface{}{"page_idx": float64(0), "para_blocks": blocks},
+		},
+	}
+}
+
+func testProfiles(t *testing.T) *diff.Profiles {
+	profiles, err := diff.NewProfiles(map[string][]string{"strict": {diff.RuleWhitespace}}, "")
+	if err != nil {
+		t.Fatalf("Failed to build profiles: %v", err)
+	}
+	return profiles
+}
+
+func TestComparisonHandlerCompare(t *testing.T) {
+	store := setupTestStore()
+
+	store.Save(&model.Contract{
+		ID:        "compare-left",
+		Tenant:    "tenant1",
+		Status:    model.StatusCompleted,
+		JSONData:  testContractJSON("第一条 货款为人民币10000元。", "第二条 双方签字后生效。"),
+		CreatedAt: time.Now(),
+	})
+	store.Save(&model.Contract{
+		ID:        "compare-right",
+		Tenant:    "tenant1",
+		Status:    model.StatusCompleted,
+		JSONData:  testContractJSON("第一条 货款为人民币12000元。", "第二条 双方签字后生效。"),
+		CreatedAt: time.Now(),
+	})
+	store.Save(&model.Contract{
+		ID:        "compare-pending",
+		Tenant:    "tenant1",
+		Status:    model.StatusProcessing,
+		CreatedAt: time.Now(),
+	})
+	defer store.Delete("compare-left")
+	defer store.Delete("compare-right")
+	defer store.Delete("compare-pending")
+
+	handler := &ComparisonHandler{store: store, profiles: testProfiles(t)}
+
+	tests := []struct {
+		name           string
+		tenant         string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "valid comparison",
+			tenant:         "tenant1",
+			body:           `{"left_id":"compare-left","right_id":"compare-right"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "aligned comparison",
+			tenant:         "tenant1",
+			body:           `{"left_id":"compare-left","right_id":"compare-right","algorithm":"align"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unknown algorithm",
+			tenant:         "tenant1",
+			body:           `{"left_id":"compare-left","right_id":"compare-right","algorithm":"fuzzy"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing right id",
+			tenant:         "tenant1",
+			body:           `{"left_id":"compare-left"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "wrong tenant",
+			tenant:         "tenant2",
+			body:           `{"left_id":"compare-left","right_id":"compare-right"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "not completed",
+			tenant:         "tenant1",
+			body:           `{"left_id":"compare-left","right_id":"compare-pending"}`,
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/comparisons", func(c *gin.Context) {
+				c.Set("tenant", tt.tenant)
+				handler.Compare(c)
+			})
+
+			req := httptest.NewRequest("POST", "/comparisons", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestComparisonHandlerCompareResponse(t *testing.T) {
+	store := setupTestStore()
+
+	store.Save(&model.Contract{
+		ID:        "compare-resp-left",
+		Tenant:    "tenant1",
+		Status:    model.StatusCompleted,
+		JSONData:  testContractJSON("第一条 付款期限为三十日。"),
+		CreatedAt: time.Now(),
+	})
+	store.Save(&model.Contract{
+		ID:        "compare-resp-right",
+		Tenant:    "tenant1",
+		Status:    model.StatusCompleted,
+		JSONData:  testContractJSON("第一条 付款期限为六十日。"),
+		CreatedAt: time.Now(),
+	})
+	defer store.Delete("compare-resp-left")
+	defer store.Delete("compare-resp-right")
+
+	handler := &ComparisonHandler{store: store, profiles: testProfiles(t)}
+
+	router := gin.New()
+	router.POST("/comparisons", func(c *gin.Context) {
+		c.Set("tenant", "tenant1")
+		handler.Compare(c)
+	})
+
+	body := `{"left_id":"compare-resp-left","right_id":"compare-resp-right","algorithm":"align"}`
+	req := httptest.NewRequest("POST", "/comparisons", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Algorithm string `json:"algorithm"`
+		Pairs     []struct {
+			HasDiff bool    `json:"has_diff"`
+			Quality float64 `json:"quality"`
+			Diffs   []struct {
+				Op   int    `json:"op"`
+				Text string `json:"text"`
+			} `json:"diffs"`
+			Hunks []struct {
+				Categories []string `json:"categories"`
+				OldValue   string   `json:"old_value"`
+				NewValue   string   `json:"new_value"`
+			} `json:"hunks"`
+		} `json:"pairs"`
+		Stats struct {
+			Added   int `json:"added"`
+			Removed int `json:"removed"`
+			Total   int `json:"total"`
+		} `json:"stats"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if len(response.Pairs) != 1 || !response.Pairs[0].HasDiff {
+		t.Fatalf("Expected one pair with a diff, got %+v", response.Pairs)
+	}
+	if response.Algorithm != "align" {
+		t.Errorf("Expected the align algorithm, got %q", response.Algorithm)
+	}
+	if q := response.Pairs[0].Quality; q <= 0 || q >= 1 {
+		t.Errorf("Expected a partial alignment quality, got %v", q)
+	}
+	if hunks := response.Pairs[0].Hunks; len(hunks) != 1 || len(hunks[0].Categories) != 1 || hunks[0].Categories[0] != "duration" ||
+		hunks[0].OldValue != "30 days" || hunks[0].NewValue != "60 days" {
+		t.Errorf("Expected a duration hunk from 30 to 60 days, got %+v", hunks)
+	}
+	if response.Stats.Added != 1 || response.Stats.Removed != 1 || response.Stats.Total != 2 {
+		t.Errorf("Unexpected stats: %+v", response.Stats)
+	}
+}
+
+func TestComparisonHandlerCompareRevisions(t *testing.T) {
+	store := setupTestStore()
+	store.Save(&model.Contract{
+		ID:       "compare-revisions",
+		Tenant:   "tenant1",
+		Status:   model.StatusCompleted,
+		Revision: 2,
+		JSONData: testContractJSON("第一条 付款期限为六十日。"),
+		Revisions: []model.Revision{
+			{Number: 1, JSONData: testContractJSON("第一条 付款期限为三十日。")},
+		},
+		CreatedAt: time.Now(),
+	})
+	defer store.Delete("compare-revisions")
+
+	handler := &ComparisonHandler{store: store, profiles: testProfiles(t)}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedTotal  int
+	}{
+		{"earlier against current", `{"left_id":"compare-revisions","left_revision":1,"right_id":"compare-revisions"}`, http.StatusOK, 2},
+		{"current by number", `{"left_id":"compare-revisions","left_revision":2,"right_id":"compare-revisions"}`, http.StatusOK, 0},
+		{"unknown revision", `{"left_id":"compare-revisions","left_revision":5,"right_id":"compare-revisions"}`, http.StatusNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/comparisons", func(c *gin.Context) {
+				c.Set("tenant", "tenant1")
+				handler.Compare(c)
+			})
+
+			req := httptest.NewRequest("POST", "/comparisons", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var response struct {
+				LeftRevision  int `json:"left_revision"`
+				RightRevision int `json:"right_revision"`
+				Stats         struct {
+					Total int `json:"total"`
+				} `json:"stats"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if response.RightRevision != 2 || response.Stats.Total != tt.expectedTotal {
+				t.Errorf("Expected %d changes against revision 2, got %+v", tt.expectedTotal, response)
+			}
+		})
+	}
+}
+
+func TestComparisonHandlerCompareProfiles(t *testing.T) {
+	store := setupTestStore()
+	store.Save(&model.Contract{
+		ID:        "compare-profile-left",
+		Tenant:    "tenant1",
+		Status:    model.StatusCompleted,
+		JSONData:  testContractJSON("第一条 付款期限为三十日。"),
+		CreatedAt: time.Now(),
+	})
+	store.Save(&model.Contract{
+		ID:        "compare-profile-right",
+		Tenant:    "tenant1",
+		Status:    model.StatusCompleted,
+		JSONData:  testContractJSON("第一条 付款期限为三十日."),
+		CreatedAt: time.Now(),
+	})
+	defer store.Delete("compare-profile-left")
+	defer store.Delete("compare-profile-right")
+
+	handler := &ComparisonHandler{store: store, profiles: testProfiles(t)}
+
+	tests := []struct {
+		name            string
+		profile         string
+		expectedStatus  int
+		expectedProfile string
+		expectedTotal   int
+	}{
+		{"default folds punctuation", "", http.StatusOK, "standard", 0},
+		{"strict keeps punctuation", "strict", http.StatusOK, "strict", 2},
+		{"unknown profile", "loose", http.StatusBadRequest, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/comparisons", func(c *gin.Context) {
+				c.Set("tenant", "tenant1")
+				handler.Compare(c)
+			})
+
+			body := `{"left_id":"compare-profile-left","right_id":"compare-profile-right","profile":"` + tt.profile + `"}`
+			req := httptest.NewRequest("POST", "/comparisons", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var response struct {
+				Profile string `json:"profile"`
+				Stats   struct {
+					Total int `json:"total"`
+				} `json:"stats"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if response.Profile != tt.expectedProfile {
+				t.Errorf("Expected profile %s, got %s", tt.expectedProfile, response.Profile)
+			}
+			if response.Stats.Total != tt.expectedTotal {
+				t.Errorf("Expected %d changes, got %d", tt.expectedTotal, response.Stats.Total)
+			}
+		})
+	}
+}
+
+func TestComparisonHandlerProfiles(t *testing.T) {
+	handler := &ComparisonHandler{profiles: testProfiles(t)}
+
+	router := gin.New()
+	router.GET("/comparisons/profiles", handler.Profiles)
+
+	req := httptest.NewRequest("GET", "/comparisons/profiles", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var response struct {
+		Default  string `json:"default"`
+		Profiles []struct {
+			Name  string   `json:"name"`
+			Rules []string `json:"rules"`
+		} `json:"profiles"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Default != "standard" || len(response.Profiles) != 2 {
+		t.Fatalf("Unexpected profiles: %+v", response)
+	}
+	if p := response.Profiles[1]; p.Name != "strict" || len(p.Rules) != 1 || p.Rules[0] != "whitespace" {
+		t.Errorf("Expected strict profile with the whitespace rule, got %+v", p)
+	}
+}
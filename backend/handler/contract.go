@@ -1,13 +1,19 @@
 package handler
 
 import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/AnTengye/contractdiff/backend/diff"
 	"github.com/AnTengye/contractdiff/backend/middleware"
 	"github.com/AnTengye/contractdiff/backend/model"
 	"github.com/AnTengye/contractdiff/backend/service"
@@ -16,16 +22,22 @@ import (
 )
 
 type ContractHandler struct {
-	minioService  *service.MinioService
-	mineruService *service.MineruService
-	store         *service.ContractStore
+	objects   service.ObjectStorage
+	parsers   *service.ParserRouter
+	queue     *service.ParseQueue
+	artifacts *service.ArtifactStore
+	cleaner   *service.ObjectCleaner
+	store     service.ContractStore
 }
 
-func NewContractHandler(minioSvc *service.MinioService, mineruSvc *service.MineruService) *ContractHandler {
+func NewContractHandler(objects service.ObjectStorage, parsers *service.ParserRouter, queue *service.ParseQueue, artifacts *service.ArtifactStore, cleaner *service.ObjectCleaner) *ContractHandler {
 	return &ContractHandler{
-		minioService:  minioSvc,
-		mineruService: mineruSvc,
-		store:         service.GetContractStore(),
+		objects:   objects,
+		parsers:   parsers,
+		queue:     queue,
+		artifacts: artifacts,
+		cleaner:   cleaner,
+		store:     service.GetContractStore(),
 	}
 }
 
@@ -81,6 +93,26 @@ func (h *ContractHandler) Upload(c *gin.Context) {
 		contentType = expectedContentType
 	}
 
+	requested, err := extractOptionsFromForm(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	forceReparse := false
+	if raw := strings.TrimSpace(c.PostForm("force_reparse")); raw != "" {
+		if forceReparse, err = strconv.ParseBool(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid force_reparse, expected true or false"})
+			return
+		}
+	}
+
+	parser := h.parsers.Route(tenant, header.Filename)
+	var options *model.ExtractOptions
+	if p, ok := parser.(service.OptionsParser); ok {
+		options = p.ResolveOptions(tenant, requested)
+	}
+
 	// Generate unique ID and object name
 	contractID := uuid.New().String()
 	objectName := tenant + "/" + contractID + "/" + header.Filename
@@ -93,10 +125,12 @@ func (h *ContractHandler) Upload(c *gin.Context) {
 		"size", header.Size,
 	)
 
-	// Upload to MINIO
-	err = h.minioService.UploadFile(c.Request.Context(), objectName, file, header.Size, contentType)
+	// Upload to object storage, hashing the content on the way so that the
+	// file is read once
+	hashed := service.NewHashingReader(file)
+	err = h.objects.UploadFile(c.Request.Context(), objectName, hashed, header.Size, contentType)
 	if err != nil {
-		slog.Error("failed to upload file to MINIO",
+		slog.Error("failed to upload file to object storage",
 			"request_id", requestID,
 			"contract_id", contractID,
 			"error", err,
@@ -104,9 +138,42 @@ func (h *ContractHandler) Upload(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file: " + err.Error()})
 		return
 	}
+	contentHash := hashed.Sum()
 
-	// Get presigned URL for MinerU
-	pdfURL, err := h.minioService.GetPresignedURL(c.Request.Context(), objectName)
+	// An identical document parsed before is reused instead of the upload
+	if !forceReparse {
+		source, err := service.FindReusableResult(h.store, tenant, contentHash, parser.Name(), options)
+		if err != nil {
+			slog.Warn("failed to look up duplicate contracts",
+				"request_id", requestID,
+				"contract_id", contractID,
+				"error", err,
+			)
+		}
+		if source != nil {
+			// Left behind, the upload is deleted with the duplicate
+			if err := h.objects.DeleteFile(c.Request.Context(), objectName); err != nil {
+				slog.Warn("failed to delete duplicate upload",
+					"request_id", requestID,
+					"contract_id", contractID,
+					"object", objectName,
+					"error", err,
+				)
+			}
+			h.saveDuplicate(c, source, &model.Contract{
+				ID:          contractID,
+				Filename:    header.Filename,
+				Tenant:      tenant,
+				Parser:      parser.Name(),
+				ContentHash: contentHash,
+				Options:     options,
+			})
+			return
+		}
+	}
+
+	// Get presigned URL for remote parsers and the viewer
+	pdfURL, err := h.objects.GetPresignedURL(c.Request.Context(), objectName)
 	if err != nil {
 		slog.Error("failed to generate presigned URL",
 			"request_id", requestID,
@@ -117,162 +184,161 @@ func (h *ContractHandler) Upload(c *gin.Context) {
 		return
 	}
 
-	// Create contract record
+	// Create contract record, due now so that the queue's workers submit it
+	now := time.Now()
 	contract := &model.Contract{
-		ID:        contractID,
-		Filename:  header.Filename,
-		Tenant:    tenant,
-		PDFURL:    pdfURL,
-		Status:    model.StatusPending,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:          contractID,
+		Filename:    header.Filename,
+		Tenant:      tenant,
+		PDFURL:      pdfURL,
+		Status:      model.StatusPending,
+		Parser:      parser.Name(),
+		ContentHash: contentHash,
+		Options:     options,
+		NextRunAt:   &now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := h.store.Save(contract); err != nil {
+		slog.Error("failed to save contract",
+			"request_id", requestID,
+			"contract_id", contractID,
+			"error", err,
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save contract"})
+		return
 	}
-	h.store.Save(contract)
 
 	slog.Info("contract uploaded successfully",
 		"request_id", requestID,
 		"contract_id", contractID,
 		"tenant", tenant,
+		"parser", contract.Parser,
+		"options", contract.Options,
+		"content_hash", contentHash,
 	)
 
-	// Call MinerU API
-	go h.processMineruTask(contract, pdfURL)
-
 	c.JSON(http.StatusOK, gin.H{
 		"id":       contractID,
 		"filename": header.Filename,
 		"pdf_url":  pdfURL,
-		"status":   model.StatusPending,
+		"status":   contract.Status,
 	})
 }
 
-// processMineruTask handles the MinerU extraction task asynchronously
-func (h *ContractHandler) processMineruTask(contract *model.Contract, pdfURL string) {
-	slog.Info("starting MinerU task",
-		"contract_id", contract.ID,
-		"pdf_url", pdfURL,
-	)
-
-	// Update status to processing
-	h.store.UpdateStatus(contract.ID, model.StatusProcessing, "")
+// saveDuplicate completes contract with the stored file and parse result of
+// source instead of uploading and parsing the same document again
+func (h *ContractHandler) saveDuplicate(c *gin.Context, source, contract *model.Contract) {
+	requestID := middleware.GetRequestID(c)
 
-	// Create task
-	resp, err := h.mineruService.CreateTask(pdfURL, contract.ID)
-	if err != nil {
-		slog.Error("failed to create MinerU task",
+	contract.DuplicateOf = cmp.Or(source.DuplicateOf, source.ID)
+	// Keep the name of the shared file, which outlives the contract that
+	// uploaded it while duplicates use it
+	contract.SourceObject = source.SourceObject
+	if source.DuplicateOf == "" {
+		contract.SourceObject = uploadedObject(source)
+	}
+	contract.PDFURL = source.PDFURL
+	contract.Status = model.StatusCompleted
+	contract.JSONData = source.JSONData
+	contract.Artifacts = source.Artifacts
+	contract.ResultPrefix = service.ResultPrefix(source)
+	contract.CreatedAt = time.Now()
+	contract.UpdatedAt = time.Now()
+
+	if err := h.store.Save(contract); err != nil {
+		slog.Error("failed to save contract",
+			"request_id", requestID,
 			"contract_id", contract.ID,
 			"error", err,
 		)
-		h.store.UpdateStatus(contract.ID, model.StatusFailed, err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save contract"})
 		return
 	}
 
-	slog.Info("MinerU task created",
+	slog.Info("contract deduplicated",
+		"request_id", requestID,
 		"contract_id", contract.ID,
-		"task_id", resp.Data.TaskID,
+		"tenant", contract.Tenant,
+		"duplicate_of", contract.DuplicateOf,
+		"content_hash", contract.ContentHash,
 	)
 
-	// Update task ID
-	contract.MineruTaskID = resp.Data.TaskID
-	h.store.Save(contract)
-
-	// Poll for result (if no callback configured)
-	h.pollTaskResult(contract)
+	c.JSON(http.StatusOK, gin.H{
+		"id":           contract.ID,
+		"filename":     contract.Filename,
+		"pdf_url":      contract.PDFURL,
+		"status":       contract.Status,
+		"duplicate_of": contract.DuplicateOf,
+	})
 }
 
-// pollTaskResult polls for task completion
-func (h *ContractHandler) pollTaskResult(contract *model.Contract) {
-	slog.Info("starting task polling",
-		"contract_id", contract.ID,
-		"task_id", contract.MineruTaskID,
-	)
-
-	maxAttempts := 60 // 5 minutes with 5 second intervals
-	for i := 0; i < maxAttempts; i++ {
-		time.Sleep(5 * time.Second)
-
-		status, err := h.mineruService.GetTaskStatus(contract.MineruTaskID)
-		if err != nil {
-			slog.Warn("poll attempt failed",
-				"contract_id", contract.ID,
-				"attempt", i+1,
-				"error", err,
-			)
+// extractOptionsFromForm reads the optional extraction option fields of an
+// upload: model_version, is_ocr, enable_formula, enable_table, language and
+// page_ranges
+func extractOptionsFromForm(c *gin.Context) (*model.ExtractOptions, error) {
+	opts := &model.ExtractOptions{
+		ModelVersion: strings.TrimSpace(c.PostForm("model_version")),
+		Language:     strings.TrimSpace(c.PostForm("language")),
+		PageRanges:   strings.ReplaceAll(c.PostForm("page_ranges"), " ", ""),
+	}
+	flags := []struct {
+		name  string
+		value **bool
+	}{
+		{"is_ocr", &opts.IsOCR},
+		{"enable_formula", &opts.EnableFormula},
+		{"enable_table", &opts.EnableTable},
+	}
+	for _, f := range flags {
+		raw := strings.TrimSpace(c.PostForm(f.name))
+		if raw == "" {
 			continue
 		}
-
-		slog.Debug("poll status",
-			"contract_id", contract.ID,
-			"attempt", i+1,
-			"state", status.Data.State,
-			"zip_url", status.Data.FullZipURL,
-		)
-
-		switch status.Data.State {
-		case "done":
-			// Fetch JSON from ZIP
-			if status.Data.FullZipURL != "" {
-				slog.Info("downloading and extracting ZIP",
-					"contract_id", contract.ID,
-					"zip_url", status.Data.FullZipURL,
-				)
-				jsonData, err := h.mineruService.FetchZipAndExtractJSON(status.Data.FullZipURL)
-				if err != nil {
-					slog.Error("failed to fetch/extract JSON",
-						"contract_id", contract.ID,
-						"error", err,
-					)
-					h.store.UpdateStatus(contract.ID, model.StatusFailed, "Failed to fetch JSON: "+err.Error())
-					return
-				}
-				slog.Info("JSON extracted successfully",
-					"contract_id", contract.ID,
-					"keys", getMapKeys(jsonData),
-				)
-				h.store.UpdateJSONData(contract.ID, jsonData)
-			} else {
-				slog.Info("task completed without ZIP URL",
-					"contract_id", contract.ID,
-				)
-				h.store.UpdateStatus(contract.ID, model.StatusCompleted, "")
-			}
-			return
-		case "failed":
-			slog.Error("MinerU task failed",
-				"contract_id", contract.ID,
-				"error_msg", status.Data.ErrorMsg,
-			)
-			h.store.UpdateStatus(contract.ID, model.StatusFailed, status.Data.ErrorMsg)
-			return
-		case "running":
-			if status.Data.ExtractProgress.TotalPages > 0 {
-				slog.Debug("extraction progress",
-					"contract_id", contract.ID,
-					"extracted_pages", status.Data.ExtractProgress.ExtractedPages,
-					"total_pages", status.Data.ExtractProgress.TotalPages,
-				)
-			}
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q, expected true or false", f.name, raw)
 		}
+		*f.value = &v
 	}
-
-	slog.Error("task polling timeout",
-		"contract_id", contract.ID,
-	)
-	h.store.UpdateStatus(contract.ID, model.StatusFailed, "Task polling timeout")
-}
-
-func getMapKeys(m map[string]interface{}) []string {
-	keys := make([]string, 0, len(m))
-	for k := range m {
-		keys = append(keys, k)
+	if err := service.ValidateExtractOptions(opts); err != nil {
+		return nil, err
 	}
-	return keys
+	return opts, nil
 }
 
-// List returns all contracts for the current tenant
+// List returns the current tenant's contracts, newest first. Supports
+// ?status= filtering and ?limit=&offset= pagination; the number of matching
+// contracts is returned in the X-Total-Count header.
 func (h *ContractHandler) List(c *gin.Context) {
 	tenant := middleware.GetTenant(c)
-	contracts := h.store.GetByTenant(tenant)
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+
+	contracts, total, err := h.store.ListByTenant(tenant, service.ListOptions{
+		Status: c.Query("status"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		slog.Error("failed to list contracts",
+			"request_id", middleware.GetRequestID(c),
+			"tenant", tenant,
+			"error", err,
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list contracts"})
+		return
+	}
 
 	// Return without JSON data for list view
 	result := make([]gin.H, len(contracts))
@@ -287,6 +353,7 @@ func (h *ContractHandler) List(c *gin.Context) {
 		}
 	}
 
+	c.Header("X-Total-Count", strconv.Itoa(total))
 	c.JSON(http.StatusOK, gin.H{"contracts": result})
 }
 
@@ -295,52 +362,372 @@ func (h *ContractHandler) Get(c *gin.Context) {
 	tenant := middleware.GetTenant(c)
 	id := c.Param("id")
 
-	contract := h.store.Get(id)
-	if contract == nil || contract.Tenant != tenant {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Contract not found"})
+	contract, ok := loadContract(c, h.store, id, tenant)
+	if !ok {
 		return
 	}
+	contract.Revisions = revisionSummaries(contract.Revisions)
 
 	c.JSON(http.StatusOK, contract)
 }
 
-// GetStatus returns the processing status of a contract
+// revisionSummaries returns revisions without their parse results, which
+// are compared through the comparisons endpoint instead
+func revisionSummaries(revisions []model.Revision) []model.Revision {
+	if revisions == nil {
+		return nil
+	}
+	summaries := make([]model.Revision, len(revisions))
+	for i, r := range revisions {
+		r.JSONData = nil
+		r.ResultPrefix = ""
+		summaries[i] = r
+	}
+	return summaries
+}
+
+// GetStatus returns the processing status of a contract with the parser's
+// progress and, while pages are being extracted, an estimated time left
 func (h *ContractHandler) GetStatus(c *gin.Context) {
 	tenant := middleware.GetTenant(c)
 	id := c.Param("id")
 
-	contract := h.store.Get(id)
-	if contract == nil || contract.Tenant != tenant {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Contract not found"})
+	contract, ok := loadContract(c, h.store, id, tenant)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"id":        contract.ID,
 		"status":    contract.Status,
 		"error_msg": contract.ErrorMsg,
+	}
+	if contract.Progress != nil {
+		response["progress"] = contract.Progress
+	}
+	if contract.Status == model.StatusProcessing {
+		if eta, ok := service.EstimateRemaining(contract.Progress, time.Now()); ok {
+			response["eta_seconds"] = int(eta.Round(time.Second).Seconds())
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Artifacts returns the artifact manifest of a contract, or streams the
+// artifact at the given path, e.g. full.md or images/0.jpg. ?revision=
+// selects the artifacts of an earlier run.
+func (h *ContractHandler) Artifacts(c *gin.Context) {
+	tenant := middleware.GetTenant(c)
+	id := c.Param("id")
+
+	contract, ok := loadContract(c, h.store, id, tenant)
+	if !ok {
+		return
+	}
+
+	if raw := c.Query("revision"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision"})
+			return
+		}
+		if n != service.CurrentRevision(contract) {
+			revision := service.FindRevision(contract, n)
+			if revision == nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+				return
+			}
+			contract.Artifacts = revision.Artifacts
+			contract.ResultPrefix = revision.ResultPrefix
+		}
+	}
+
+	artifactPath := strings.TrimPrefix(c.Param("path"), "/")
+	if artifactPath == "" {
+		artifacts := contract.Artifacts
+		if artifacts == nil {
+			artifacts = []model.Artifact{}
+		}
+		c.JSON(http.StatusOK, gin.H{"artifacts": artifacts})
+		return
+	}
+
+	if h.artifacts == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Artifact not found"})
+		return
+	}
+	reader, artifact, err := h.artifacts.Open(c.Request.Context(), contract, artifactPath)
+	if errors.Is(err, service.ErrArtifactNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Artifact not found"})
+		return
+	}
+	if err != nil {
+		slog.Error("failed to open artifact",
+			"request_id", middleware.GetRequestID(c),
+			"contract_id", id,
+			"path", artifactPath,
+			"error", err,
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load artifact"})
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, artifact.Size, artifact.ContentType, reader, nil)
+}
+
+// Outline returns the clause tree of a completed contract, or of an
+// earlier revision with ?revision=
+func (h *ContractHandler) Outline(c *gin.Context) {
+	tenant := middleware.GetTenant(c)
+	id := c.Param("id")
+
+	contract, ok := loadContract(c, h.store, id, tenant)
+	if !ok {
+		return
+	}
+
+	n := 0
+	if raw := c.Query("revision"); raw != "" {
+		var err error
+		if n, err = strconv.Atoi(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision"})
+			return
+		}
+	}
+	data, revision, ok := revisionData(c, contract, n)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":       contract.ID,
+		"revision": revision,
+		"clauses":  diff.ParseOutline(diff.ParseParagraphs(data)),
+	})
+}
+
+// Reprocess parses a finished contract again from its stored file, e.g.
+// with another model_version. Takes the same option fields as Upload; the
+// previous result is kept as a revision.
+func (h *ContractHandler) Reprocess(c *gin.Context) {
+	tenant := middleware.GetTenant(c)
+	id := c.Param("id")
+	requestID := middleware.GetRequestID(c)
+
+	contract, ok := loadContract(c, h.store, id, tenant)
+	if !ok {
+		return
+	}
+	if contract.Status == model.StatusPending || contract.Status == model.StatusProcessing {
+		c.JSON(http.StatusConflict, gin.H{"error": "Contract is being processed"})
+		return
+	}
+
+	requested, err := extractOptionsFromForm(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	parser := h.parsers.Route(tenant, contract.Filename)
+	var options *model.ExtractOptions
+	if p, ok := parser.(service.OptionsParser); ok {
+		options = p.ResolveOptions(tenant, requested)
+	}
+
+	objectName, ok := h.sourceObject(c, contract)
+	if !ok {
+		return
+	}
+	pdfURL, err := h.objects.GetPresignedURL(c.Request.Context(), objectName)
+	if err != nil {
+		slog.Error("failed to generate presigned URL",
+			"request_id", requestID,
+			"contract_id", id,
+			"error", err,
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate URL: " + err.Error()})
+		return
+	}
+
+	err = h.queue.Reprocess(id, parser, pdfURL, options)
+	if errors.Is(err, service.ErrContractBusy) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Contract is being processed"})
+		return
+	}
+	if err != nil {
+		slog.Error("failed to reprocess contract",
+			"request_id", requestID,
+			"contract_id", id,
+			"error", err,
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reprocess contract"})
+		return
+	}
+
+	slog.Info("contract reprocessing",
+		"request_id", requestID,
+		"contract_id", id,
+		"tenant", tenant,
+		"parser", parser.Name(),
+		"options", options,
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":       id,
+		"status":   model.StatusPending,
+		"revision": service.CurrentRevision(contract) + 1,
 	})
 }
 
-// Delete deletes a contract
+// uploadedObject returns the object name a contract's file is uploaded to
+func uploadedObject(contract *model.Contract) string {
+	return contract.Tenant + "/" + contract.ID + "/" + contract.Filename
+}
+
+// sourceObject returns the object name of a contract's uploaded file,
+// which duplicates share with the contract they duplicate. Duplicates saved
+// before their source object was recorded look it up from that contract.
+// It writes a 409 or 500 response and returns false if the file is gone.
+func (h *ContractHandler) sourceObject(c *gin.Context, contract *model.Contract) (string, bool) {
+	if contract.SourceObject != "" {
+		return contract.SourceObject, true
+	}
+	owner := contract
+	if contract.DuplicateOf != "" {
+		var err error
+		owner, err = h.store.Get(contract.DuplicateOf)
+		if err != nil {
+			slog.Error("failed to load duplicated contract",
+				"request_id", middleware.GetRequestID(c),
+				"contract_id", contract.ID,
+				"duplicate_of", contract.DuplicateOf,
+				"error", err,
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load contract"})
+			return "", false
+		}
+		if owner == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Source file is no longer available"})
+			return "", false
+		}
+	}
+	return uploadedObject(owner), true
+}
+
+// Cancel stops the processing of a contract
+func (h *ContractHandler) Cancel(c *gin.Context) {
+	tenant := middleware.GetTenant(c)
+	id := c.Param("id")
+	requestID := middleware.GetRequestID(c)
+
+	if _, ok := loadContract(c, h.store, id, tenant); !ok {
+		return
+	}
+
+	cancelled, err := h.queue.Cancel(id)
+	if err != nil {
+		slog.Error("failed to cancel contract",
+			"request_id", requestID,
+			"contract_id", id,
+			"error", err,
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel contract"})
+		return
+	}
+	if !cancelled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Contract is not being processed"})
+		return
+	}
+
+	slog.Info("contract cancelled",
+		"request_id", requestID,
+		"contract_id", id,
+		"tenant", tenant,
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":     id,
+		"status": model.StatusCancelled,
+	})
+}
+
+// Delete deletes a contract, cancelling its processing first
 func (h *ContractHandler) Delete(c *gin.Context) {
 	tenant := middleware.GetTenant(c)
 	id := c.Param("id")
 	requestID := middleware.GetRequestID(c)
 
-	contract := h.store.Get(id)
-	if contract == nil || contract.Tenant != tenant {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Contract not found"})
+	contract, ok := loadContract(c, h.store, id, tenant)
+	if !ok {
+		return
+	}
+
+	if h.queue != nil {
+		if _, err := h.queue.Cancel(id); err != nil {
+			slog.Error("failed to cancel contract",
+				"request_id", requestID,
+				"contract_id", id,
+				"error", err,
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete contract"})
+			return
+		}
+	}
+
+	if err := h.store.Delete(id); err != nil {
+		slog.Error("failed to delete contract",
+			"request_id", requestID,
+			"contract_id", id,
+			"error", err,
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete contract"})
 		return
 	}
 
-	h.store.Delete(id)
+	// Remove the uploaded file and results; objects left behind by failures
+	// are picked up by the orphan reconciler
+	report := &service.CleanupReport{Deleted: []string{}}
+	if h.cleaner != nil {
+		report = h.cleaner.DeleteContract(context.WithoutCancel(c.Request.Context()), contract)
+	}
 
 	slog.Info("contract deleted",
 		"request_id", requestID,
 		"contract_id", id,
 		"tenant", tenant,
+		"objects_deleted", len(report.Deleted),
+		"objects_failed", len(report.Failed),
 	)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Contract deleted"})
+	if len(report.Failed) > 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"message":         "Contract deleted, some files could not be removed",
+			"objects_deleted": len(report.Deleted),
+			"failed_objects":  report.Failed,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Contract deleted", "objects_deleted": len(report.Deleted)})
+}
+
+// loadContract loads a contract owned by tenant. It writes a 404 or 500
+// response and returns false if the contract cannot be used.
+func loadContract(c *gin.Context, store service.ContractStore, id, tenant string) (*model.Contract, bool) {
+	contract, err := store.Get(id)
+	if err != nil {
+		slog.Error("failed to load contract",
+			"request_id", middleware.GetRequestID(c),
+			"contract_id", id,
+			"error", err,
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load contract"})
+		return nil, false
+	}
+	if contract == nil || contract.Tenant != tenant {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Contract not found"})
+		return nil, false
+	}
+	return contract, true
 }
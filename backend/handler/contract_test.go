@@ -2,12 +2,21 @@ package handler
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/AnTengye/contractdiff/backend/config"
 	"github.com/AnTengye/contractdiff/backend/model"
 	"github.com/AnTengye/contractdiff/backend/service"
 	"github.com/gin-gonic/gin"
@@ -18,7 +27,7 @@ func init() {
 }
 
 // Mock store for testing
-func setupTestStore() *service.ContractStore {
+func setupTestStore() service.ContractStore {
 	return service.GetContractStore()
 }
 
@@ -181,6 +190,35 @@ func TestContractHandlerGetStatus(t *testing.T) {
 	if response["status"] != model.StatusProcessing {
 		t.Errorf("Expected status '%s', got '%v'", model.StatusProcessing, response["status"])
 	}
+	if _, ok := response["eta_seconds"]; ok {
+		t.Errorf("Expected no ETA without progress, got %v", response["eta_seconds"])
+	}
+
+	// Extracting 2 of 6 pages in 20 seconds leaves about 40 seconds
+	startedAt := time.Now().Add(-20 * time.Second)
+	store.UpdateProgress("status-test", &model.Progress{
+		State:          "running",
+		ExtractedPages: 2,
+		TotalPages:     6,
+		StartedAt:      &startedAt,
+		UpdatedAt:      time.Now(),
+	})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/contracts/status-test/status", nil))
+
+	var withProgress struct {
+		Progress   *model.Progress `json:"progress"`
+		ETASeconds *int            `json:"eta_seconds"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &withProgress); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if withProgress.Progress == nil || withProgress.Progress.State != "running" || withProgress.Progress.ExtractedPages != 2 {
+		t.Errorf("Expected progress in response, got %s", w.Body.String())
+	}
+	if withProgress.ETASeconds == nil || *withProgress.ETASeconds < 38 || *withProgress.ETASeconds > 40 {
+		t.Errorf("Expected ETA of about 40 seconds, got %s", w.Body.String())
+	}
 
 	store.Delete("status-test")
 }
@@ -238,31 +276,6 @@ func TestContractHandlerDelete(t *testing.T) {
 	}
 }
 
-func TestGetMapKeys(t *testing.T) {
-	m := map[string]interface{}{
-		"key1": "value1",
-		"key2": "value2",
-		"key3": "value3",
-	}
-
-	keys := getMapKeys(m)
-	if len(keys) != 3 {
-		t.Errorf("Expected 3 keys, got %d", len(keys))
-	}
-
-	// Check all keys are present
-	keySet := make(map[string]bool)
-	for _, k := range keys {
-		keySet[k] = true
-	}
-
-	for k := range m {
-		if !keySet[k] {
-			t.Errorf("Expected key '%s' in result", k)
-		}
-	}
-}
-
 func TestContractHandlerUploadNoFile(t *testing.T) {
 	handler := &ContractHandler{store: setupTestStore()}
 
@@ -316,6 +329,47 @@ func TestContractHandlerUploadInvalidType(t *testing.T) {
 	}
 }
 
+func TestExtractOptionsFromForm(t *testing.T) {
+	tests := []struct {
+		name    string
+		form    string
+		wantErr bool
+	}{
+		{"none", "", false},
+		{"all", "model_version=pipeline&is_ocr=true&enable_formula=0&enable_table=false&language=en&page_ranges=2,+4-6", false},
+		{"bad flag", "is_ocr=maybe", true},
+		{"bad model", "model_version=gpt", true},
+		{"bad page ranges", "page_ranges=1-", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/upload", strings.NewReader(tt.form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = req
+
+			opts, err := extractOptionsFromForm(c)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %+v", opts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tt.name == "all" {
+				if opts.ModelVersion != "pipeline" || !*opts.IsOCR || *opts.EnableFormula || *opts.EnableTable || opts.Language != "en" || opts.PageRanges != "2,4-6" {
+					t.Errorf("Unexpected options: %+v", opts)
+				}
+			} else if opts.IsOCR != nil || opts.ModelVersion != "" {
+				t.Errorf("Expected empty options, got %+v", opts)
+			}
+		})
+	}
+}
+
 func TestContractHandlerGetStatusNotFound(t *testing.T) {
 	store := setupTestStore()
 	handler := &ContractHandler{store: store}
@@ -426,7 +480,7 @@ func TestContractHandlerListEmpty(t *testing.T) {
 }
 
 func TestNewContractHandler(t *testing.T) {
-	handler := NewContractHandler(nil, nil)
+	handler := NewContractHandler(nil, nil, nil, nil, nil)
 	if handler == nil {
 		t.Fatal("Expected non-nil handler")
 	}
@@ -435,10 +489,644 @@ func TestNewContractHandler(t *testing.T) {
 	}
 }
 
-func TestGetMapKeysEmpty(t *testing.T) {
-	m := map[string]interface{}{}
-	keys := getMapKeys(m)
-	if len(keys) != 0 {
-		t.Errorf("Expected 0 keys, got %d", len(keys))
+func TestContractHandlerListFilterAndPagination(t *testing.T) {
+	store := setupTestStore()
+
+	base := time.Now()
+	for i, status := range []string{model.StatusCompleted, model.StatusFailed, model.StatusCompleted, model.StatusCompleted} {
+		id := "page-test-" + string(rune('a'+i))
+		store.Save(&model.Contract{
+			ID:        id,
+			Tenant:    "page-tenant",
+			Status:    status,
+			CreatedAt: base.Add(time.Duration(i) * time.Second),
+		})
+		defer store.Delete(id)
+	}
+
+	handler := &ContractHandler{store: store}
+
+	router := gin.New()
+	router.GET("/contracts", func(c *gin.Context) {
+		c.Set("tenant", "page-tenant")
+		handler.List(c)
+	})
+
+	req := httptest.NewRequest("GET", "/contracts?status=completed&limit=2&offset=1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	if total := w.Header().Get("X-Total-Count"); total != "3" {
+		t.Errorf("Expected X-Total-Count 3, got %q", total)
+	}
+
+	var response map[string][]map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	contracts := response["contracts"]
+	if len(contracts) != 2 {
+		t.Fatalf("Expected 2 contracts, got %d", len(contracts))
+	}
+	if contracts[0]["id"] != "page-test-c" || contracts[1]["id"] != "page-test-a" {
+		t.Errorf("Unexpected page: %v, %v", contracts[0]["id"], contracts[1]["id"])
+	}
+
+	req = httptest.NewRequest("GET", "/contracts?limit=-1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid limit, got %d", w.Code)
+	}
+}
+
+// mapObjects is an in-memory service.ObjectStorage
+type mapObjects map[string]string
+
+func (m mapObjects) UploadFile(ctx context.Context, objectName string, reader io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(reader)
+	m[objectName] = string(data)
+	return err
+}
+
+func (m mapObjects) GetObject(ctx context.Context, objectName string) (io.ReadCloser, error) {
+	data, ok := m[objectName]
+	if !ok {
+		return nil, errors.New("object not found")
+	}
+	return io.NopCloser(strings.NewReader(data)), nil
+}
+
+func (m mapObjects) DeleteFile(ctx context.Context, objectName string) error {
+	delete(m, objectName)
+	return nil
+}
+
+func (m mapObjects) ListObjects(ctx context.Context, prefix string) ([]service.ObjectInfo, error) {
+	var objects []service.ObjectInfo
+	for name, data := range m {
+		if strings.HasPrefix(name, prefix) {
+			objects = append(objects, service.ObjectInfo{Name: name, Size: int64(len(data))})
+		}
+	}
+	return objects, nil
+}
+
+func (m mapObjects) GetPresignedURL(ctx context.Context, objectName string) (string, error) {
+	return "https://objects.example.com/" + objectName, nil
+}
+
+func TestContractHandlerArtifacts(t *testing.T) {
+	store := setupTestStore()
+	artifacts := service.NewArtifactStore(mapObjects{})
+
+	contract := &model.Contract{
+		ID:        "artifacts-test",
+		Filename:  "test.pdf",
+		Tenant:    "tenant1",
+		Status:    model.StatusCompleted,
+		CreatedAt: time.Now(),
+	}
+	file := func(path, data string) service.ResultFile {
+		return service.ResultFile{
+			Path: path,
+			Size: int64(len(data)),
+			Open: func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(data)), nil },
+		}
+	}
+	manifest, err := artifacts.Save(context.Background(), contract, []service.ResultFile{
+		file("full.md", "# 合同"),
+		file("images/0.jpg", "\xff\xd8\xff"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to save artifacts: %v", err)
+	}
+	contract.Artifacts = manifest
+	store.Save(contract)
+	defer store.Delete("artifacts-test")
+
+	handler := &ContractHandler{store: store, artifacts: artifacts}
+
+	tests := []struct {
+		name           string
+		path           string
+		tenant         string
+		expectedStatus int
+		expectedType   string
+		expectedBody   string
+	}{
+		{"manifest", "/", "tenant1", http.StatusOK, "application/json; charset=utf-8", ""},
+		{"markdown", "/full.md", "tenant1", http.StatusOK, "text/markdown; charset=utf-8", "# 合同"},
+		{"image", "/images/0.jpg", "tenant1", http.StatusOK, "image/jpeg", "\xff\xd8\xff"},
+		{"unknown path", "/middle.json", "tenant1", http.StatusNotFound, "", ""},
+		{"wrong tenant", "/full.md", "tenant2", http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/contracts/:id/artifacts/*path", func(c *gin.Context) {
+				c.Set("tenant", tt.tenant)
+				handler.Artifacts(c)
+			})
+
+			req := httptest.NewRequest("GET", "/contracts/artifacts-test/artifacts"+tt.path, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedType != "" && w.Header().Get("Content-Type") != tt.expectedType {
+				t.Errorf("Expected content type %s, got %s", tt.expectedType, w.Header().Get("Content-Type"))
+			}
+			if tt.expectedBody != "" && w.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+
+	// The manifest lists every artifact
+	router := gin.New()
+	router.GET("/contracts/:id/artifacts/*path", func(c *gin.Context) {
+		c.Set("tenant", "tenant1")
+		handler.Artifacts(c)
+	})
+	req := httptest.NewRequest("GET", "/contracts/artifacts-test/artifacts/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response struct {
+		Artifacts []model.Artifact `json:"artifacts"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if len(response.Artifacts) != 2 || response.Artifacts[0].Path != "full.md" {
+		t.Errorf("Unexpected manifest: %s", w.Body.String())
+	}
+}
+
+// multipartUpload builds an upload request for a file with extra form fields
+func multipartUpload(t *testing.T, filename, content string, fields map[string]string) *http.Request {
+	t.Helper()
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for name, value := range fields {
+		mw.WriteField(name, value)
+	}
+	fw, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	io.WriteString(fw, content)
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestContractHandlerUploadDeduplicates(t *testing.T) {
+	store := setupTestStore()
+	mineru := service.NewMineruParser(service.NewMineruService(&config.MineruConfig{ModelVersion: "vlm"}))
+	parsers, _ := service.NewParserRouter(&config.ParserConfig{}, mineru)
+	objects := mapObjects{}
+	handler := &ContractHandler{store: store, parsers: parsers, objects: objects}
+
+	content := "%PDF-1.4 signed version"
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+	store.Save(&model.Contract{
+		ID:          "dedupe-source",
+		Filename:    "signed.pdf",
+		Tenant:      "tenant1",
+		PDFURL:      "http://example.com/signed.pdf",
+		Status:      model.StatusCompleted,
+		Parser:      service.ParserMineru,
+		ContentHash: hash,
+		Options:     mineru.ResolveOptions("tenant1", &model.ExtractOptions{}),
+		JSONData:    map[string]interface{}{"pdf_info": []interface{}{}},
+		Artifacts:   []model.Artifact{{Path: "full.md", Size: 8, ContentType: "text/markdown; charset=utf-8"}},
+		CreatedAt:   time.Now(),
+	})
+	defer store.Delete("dedupe-source")
+
+	router := gin.New()
+	router.POST("/upload", func(c *gin.Context) {
+		c.Set("tenant", "tenant1")
+		handler.Upload(c)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, multipartUpload(t, "draft.pdf", content, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response map[string]string
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response["status"] != model.StatusCompleted || response["duplicate_of"] != "dedupe-source" {
+		t.Errorf("Expected a completed duplicate of dedupe-source, got %v", response)
+	}
+	contract, _ := store.Get(response["id"])
+	if contract == nil {
+		t.Fatal("Expected the duplicate to be stored")
+	}
+	defer store.Delete(contract.ID)
+	if contract.Filename != "draft.pdf" || contract.ContentHash != hash || contract.JSONData == nil || len(contract.Artifacts) != 1 {
+		t.Errorf("Expected the source's result under the new filename, got %+v", contract)
+	}
+	if contract.SourceObject != "tenant1/dedupe-source/signed.pdf" {
+		t.Errorf("Expected the source's file to be recorded, got %q", contract.SourceObject)
+	}
+	if len(objects) != 0 {
+		t.Errorf("Expected the duplicate upload to be deleted, got %v", objects)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, multipartUpload(t, "draft.pdf", content, map[string]string{"force_reparse": "sometimes"}))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid force_reparse, got %d", w.Code)
+	}
+}
+
+func TestContractHandlerUploadQueuesContract(t *testing.T) {
+	store := setupTestStore()
+	mineru := service.NewMineruParser(service.NewMineruService(&config.MineruConfig{ModelVersion: "vlm"}))
+	parsers, _ := service.NewParserRouter(&config.ParserConfig{}, mineru)
+	objects := mapObjects{}
+	handler := &ContractHandler{store: store, parsers: parsers, objects: objects}
+
+	router := gin.New()
+	router.POST("/upload", func(c *gin.Context) {
+		c.Set("tenant", "tenant1")
+		handler.Upload(c)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, multipartUpload(t, "queued.pdf", "%PDF-1.4 queued", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The parser is not called; the queue's workers submit the contract
+	var response map[string]string
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response["status"] != model.StatusPending {
+		t.Errorf("Expected status '%s', got '%s'", model.StatusPending, response["status"])
+	}
+	contract, _ := store.Get(response["id"])
+	if contract == nil {
+		t.Fatal("Expected the contract to be stored")
+	}
+	defer store.Delete(contract.ID)
+	if contract.Status != model.StatusPending || contract.Parser != service.ParserMineru || contract.MineruTaskID != "" || contract.NextRunAt == nil {
+		t.Errorf("Expected a pending contract due for submission, got %+v", contract)
+	}
+	if objects["tenant1/"+contract.ID+"/queued.pdf"] != "%PDF-1.4 queued" {
+		t.Errorf("Expected the file to be stored, got %v", objects)
+	}
+	if hash := fmt.Sprintf("%x", sha256.Sum256([]byte("%PDF-1.4 queued"))); contract.ContentHash != hash {
+		t.Errorf("Expected content hash %s, got %s", hash, contract.ContentHash)
+	}
+}
+
+func TestContractHandlerCancel(t *testing.T) {
+	store := setupTestStore()
+	store.Save(&model.Contract{
+		ID:           "cancel-test",
+		Tenant:       "tenant1",
+		Status:       model.StatusProcessing,
+		MineruTaskID: "task-1",
+		CreatedAt:    time.Now(),
+	})
+	defer store.Delete("cancel-test")
+
+	queue := service.NewParseQueue(store, nil, nil, &config.QueueConfig{})
+	handler := &ContractHandler{store: store, queue: queue}
+
+	tests := []struct {
+		name           string
+		tenant         string
+		expectedStatus int
+	}{
+		{"wrong tenant", "tenant2", http.StatusNotFound},
+		{"processing", "tenant1", http.StatusOK},
+		{"already cancelled", "tenant1", http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/contracts/:id/cancel", func(c *gin.Context) {
+				c.Set("tenant", tt.tenant)
+				handler.Cancel(c)
+			})
+
+			req := httptest.NewRequest("POST", "/contracts/cancel-test/cancel", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	contract, _ := store.Get("cancel-test")
+	if contract.Status != model.StatusCancelled {
+		t.Errorf("Expected status '%s', got '%s'", model.StatusCancelled, contract.Status)
+	}
+}
+
+func TestContractHandlerDeleteCancelsProcessing(t *testing.T) {
+	store := setupTestStore()
+	store.Save(&model.Contract{
+		ID:        "delete-processing-test",
+		Tenant:    "tenant1",
+		Status:    model.StatusPending,
+		CreatedAt: time.Now(),
+	})
+
+	handler := &ContractHandler{store: store, queue: service.NewParseQueue(store, nil, nil, &config.QueueConfig{})}
+
+	router := gin.New()
+	router.DELETE("/contracts/:id", func(c *gin.Context) {
+		c.Set("tenant", "tenant1")
+		handler.Delete(c)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/contracts/delete-processing-test", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if contract, _ := store.Get("delete-processing-test"); contract != nil {
+		t.Errorf("Expected contract to be deleted, got %+v", contract)
+	}
+}
+
+func TestContractHandlerGetSummarizesRevisions(t *testing.T) {
+	store := setupTestStore()
+	store.Save(&model.Contract{
+		ID:       "revisions-get-test",
+		Tenant:   "tenant1",
+		Status:   model.StatusCompleted,
+		Revision: 2,
+		Revisions: []model.Revision{
+			{Number: 1, Parser: service.ParserMineru, JSONData: map[string]interface{}{"pdf_info": []interface{}{}}, ResultPrefix: "tenant1/revisions-get-test/result/"},
+		},
+		CreatedAt: time.Now(),
+	})
+	defer store.Delete("revisions-get-test")
+
+	handler := &ContractHandler{store: store}
+	router := gin.New()
+	router.GET("/contracts/:id", func(c *gin.Context) {
+		c.Set("tenant", "tenant1")
+		handler.Get(c)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/contracts/revisions-get-test", nil))
+
+	var response struct {
+		Revision  int                      `json:"revision"`
+		Revisions []map[string]interface{} `json:"revisions"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.Revision != 2 || len(response.Revisions) != 1 {
+		t.Fatalf("Expected revision 2 with one earlier run, got %+v", response)
+	}
+	if _, ok := response.Revisions[0]["json_data"]; ok {
+		t.Error("Expected revision summaries without JSON data")
+	}
+	if _, ok := response.Revisions[0]["result_prefix"]; ok {
+		t.Error("Expected revision summaries without the result prefix")
+	}
+
+	// The stored contract keeps the full revision
+	contract, _ := store.Get("revisions-get-test")
+	if contract.Revisions[0].JSONData == nil {
+		t.Error("Expected the stored revision to keep its JSON data")
+	}
+}
+
+func TestContractHandlerReprocessRejected(t *testing.T) {
+	store := setupTestStore()
+	now := time.Now()
+	store.Save(&model.Contract{ID: "reprocess-busy", Tenant: "tenant1", Filename: "a.pdf", Status: model.StatusProcessing, CreatedAt: now})
+	store.Save(&model.Contract{ID: "reprocess-orphan", Tenant: "tenant1", Filename: "a.pdf", Status: model.StatusCompleted, DuplicateOf: "deleted-source", CreatedAt: now})
+	defer store.Delete("reprocess-busy")
+	defer store.Delete("reprocess-orphan")
+
+	parsers, _ := service.NewParserRouter(&config.ParserConfig{}, service.NewMineruParser(service.NewMineruService(&config.MineruConfig{})))
+	handler := &ContractHandler{store: store, parsers: parsers}
+
+	tests := []struct {
+		name           string
+		id             string
+		form           string
+		expectedStatus int
+	}{
+		{"processing", "reprocess-busy", "", http.StatusConflict},
+		{"invalid options", "reprocess-orphan", "model_version=gpt", http.StatusBadRequest},
+		{"source file gone", "reprocess-orphan", "model_version=pipeline", http.StatusConflict},
+		{"not found", "non-existent", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/contracts/:id/reprocess", func(c *gin.Context) {
+				c.Set("tenant", "tenant1")
+				handler.Reprocess(c)
+			})
+
+			req := httptest.NewRequest("POST", "/contracts/"+tt.id+"/reprocess", strings.NewReader(tt.form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// failingDeleteObjects fails every delete
+func TestContractHandlerReprocessDuplicateOfDeletedContract(t *testing.T) {
+	store := setupTestStore()
+	store.Save(&model.Contract{
+		ID:           "reprocess-duplicate",
+		Tenant:       "tenant1",
+		Filename:     "draft.pdf",
+		Status:       model.StatusCompleted,
+		DuplicateOf:  "deleted-source",
+		SourceObject: "tenant1/deleted-source/signed.pdf",
+		CreatedAt:    time.Now(),
+	})
+	defer store.Delete("reprocess-duplicate")
+
+	parsers, _ := service.NewParserRouter(&config.ParserConfig{}, service.NewMineruParser(service.NewMineruService(&config.MineruConfig{})))
+	queue := service.NewParseQueue(store, nil, nil, &config.QueueConfig{})
+	handler := &ContractHandler{store: store, parsers: parsers, objects: mapObjects{}, queue: queue}
+
+	router := gin.New()
+	router.POST("/contracts/:id/reprocess", func(c *gin.Context) {
+		c.Set("tenant", "tenant1")
+		handler.Reprocess(c)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/contracts/reprocess-duplicate/reprocess", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The shared file outlives the contract that uploaded it
+	contract, _ := store.Get("reprocess-duplicate")
+	if expected := "https://objects.example.com/tenant1/deleted-source/signed.pdf"; contract.PDFURL != expected {
+		t.Errorf("Expected PDF URL %s, got %s", expected, contract.PDFURL)
+	}
+}
+
+type failingDeleteObjects struct{ mapObjects }
+
+func (failingDeleteObjects) DeleteFile(ctx context.Context, objectName string) error {
+	return errors.New("access denied")
+}
+
+func TestContractHandlerDeleteRemovesObjects(t *testing.T) {
+	store := setupTestStore()
+	objects := mapObjects{
+		"cascade/delete-objects/a.pdf":          "%PDF",
+		"cascade/delete-objects/result/full.md": "# 合同",
+		"cascade/delete-kept/b.pdf":             "%PDF",
+	}
+	now := time.Now()
+	store.Save(&model.Contract{ID: "delete-objects", Tenant: "cascade", CreatedAt: now})
+	store.Save(&model.Contract{ID: "delete-kept", Tenant: "cascade", CreatedAt: now})
+	store.Save(&model.Contract{ID: "delete-failing", Tenant: "cascade", CreatedAt: now})
+	defer store.Delete("delete-kept")
+
+	deleteContract := func(handler *ContractHandler, id string) map[string]interface{} {
+		router := gin.New()
+		router.DELETE("/contracts/:id", func(c *gin.Context) {
+			c.Set("tenant", "cascade")
+			handler.Delete(c)
+		})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("DELETE", "/contracts/"+id, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response
+	}
+
+	handler := &ContractHandler{store: store, cleaner: service.NewObjectCleaner(objects, store, time.Hour)}
+	response := deleteContract(handler, "delete-objects")
+	if response["objects_deleted"] != float64(2) {
+		t.Errorf("Expected 2 objects deleted, got %v", response["objects_deleted"])
+	}
+	if len(objects) != 1 || objects["cascade/delete-kept/b.pdf"] == "" {
+		t.Errorf("Expected only the other contract's file to remain, got %v", objects)
+	}
+
+	// Failures are reported while the contract is still deleted
+	objects["cascade/delete-failing/c.pdf"] = "%PDF"
+	failing := failingDeleteObjects{objects}
+	handler = &ContractHandler{store: store, cleaner: service.NewObjectCleaner(failing, store, time.Hour)}
+	response = deleteContract(handler, "delete-failing")
+	failed, _ := response["failed_objects"].([]interface{})
+	if len(failed) != 1 {
+		t.Fatalf("Expected one failed object, got %v", response)
+	}
+	if name := failed[0].(map[string]interface{})["name"]; name != "cascade/delete-failing/c.pdf" {
+		t.Errorf("Expected the file to be reported, got %v", name)
+	}
+	if contract, _ := store.Get("delete-failing"); contract != nil {
+		t.Error("Expected the contract to be deleted")
+	}
+}
+
+func TestContractHandlerOutline(t *testing.T) {
+	store := setupTestStore()
+	now := time.Now()
+	store.Save(&model.Contract{
+		ID:       "outline-test",
+		Tenant:   "tenant1",
+		Status:   model.StatusCompleted,
+		Revision: 2,
+		JSONData: testContractJSON("第一章 总则", "第一条 定义", "（一）货物", "第二章 付款"),
+		Revisions: []model.Revision{
+			{Number: 1, JSONData: testContractJSON("第一条 定义")},
+		},
+		CreatedAt: now,
+	})
+	store.Save(&model.Contract{ID: "outline-pending", Tenant: "tenant1", Status: model.StatusPending, CreatedAt: now})
+	defer store.Delete("outline-test")
+	defer store.Delete("outline-pending")
+
+	handler := &ContractHandler{store: store}
+
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		expectedRoots  []string
+	}{
+		{"current", "/contracts/outline-test/outline", http.StatusOK, []string{"第一章", "第二章"}},
+		{"earlier revision", "/contracts/outline-test/outline?revision=1", http.StatusOK, []string{"第一条"}},
+		{"unknown revision", "/contracts/outline-test/outline?revision=7", http.StatusNotFound, nil},
+		{"invalid revision", "/contracts/outline-test/outline?revision=x", http.StatusBadRequest, nil},
+		{"not completed", "/contracts/outline-pending/outline", http.StatusConflict, nil},
+		{"not found", "/contracts/non-existent/outline", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/contracts/:id/outline", func(c *gin.Context) {
+				c.Set("tenant", "tenant1")
+				handler.Outline(c)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.target, nil))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedRoots == nil {
+				return
+			}
+
+			var response struct {
+				Clauses []struct {
+					Reference string `json:"reference"`
+					Children  []struct {
+						Reference string `json:"reference"`
+					} `json:"children"`
+				} `json:"clauses"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			var roots []string
+			for _, c := range response.Clauses {
+				roots = append(roots, c.Reference)
+			}
+			if !reflect.DeepEqual(roots, tt.expectedRoots) {
+				t.Errorf("Expected top-level clauses %v, got %v", tt.expectedRoots, roots)
+			}
+		})
 	}
 }
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+type DiagnosticsHandler struct {
+	mineruService *service.MineruService
+	callbacks     *CallbackHandler
+	queue         *service.ParseQueue
+}
+
+func NewDiagnosticsHandler(mineruSvc *service.MineruService, callbacks *CallbackHandler, queue *service.ParseQueue) *DiagnosticsHandler {
+	return &DiagnosticsHandler{
+		mineruService: mineruSvc,
+		callbacks:     callbacks,
+		queue:         queue,
+	}
+}
+
+// DiagnosticsResponse reports the state of the MinerU integration
+type DiagnosticsResponse struct {
+	Timestamp     string               `json:"timestamp"`
+	MineruBreaker service.BreakerStats `json:"mineru_breaker"`
+	Callbacks     CallbackStats        `json:"callbacks"`
+	Queue         service.QueueStats   `json:"queue"`
+}
+
+// Get returns the circuit breaker, callback and queue state
+func (h *DiagnosticsHandler) Get(c *gin.Context) {
+	c.JSON(http.StatusOK, DiagnosticsResponse{
+		Timestamp:     time.Now().Format(time.RFC3339),
+		MineruBreaker: h.mineruService.BreakerStats(),
+		Callbacks:     h.callbacks.Stats(),
+		Queue:         h.queue.Stats(),
+	})
+}
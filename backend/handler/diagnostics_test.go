@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AnTengye/contractdiff/backend/config"
+	"github.com/AnTengye/contractdiff/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+func TestDiagnosticsHandlerGet(t *testing.T) {
+	mineruSvc := service.NewMineruService(&config.MineruConfig{
+		Breaker: config.BreakerConfig{FailureThreshold: 5, CooldownSeconds: 30},
+	})
+	queue := service.NewParseQueue(service.GetContractStore(), nil, nil, &config.QueueConfig{Workers: 3})
+	handler := NewDiagnosticsHandler(mineruSvc, NewCallbackHandler(mineruSvc, queue), queue)
+
+	router := gin.New()
+	router.GET("/diagnostics", handler.Get)
+
+	req := httptest.NewRequest("GET", "/diagnostics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response DiagnosticsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.MineruBreaker.State != service.BreakerClosed || response.MineruBreaker.Threshold != 5 {
+		t.Errorf("Unexpected breaker stats: %+v", response.MineruBreaker)
+	}
+	if response.Queue.Workers != 3 {
+		t.Errorf("Expected 3 workers, got %d", response.Queue.Workers)
+	}
+}
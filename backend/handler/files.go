@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/AnTengye/contractdiff/backend/middleware"
+	"github.com/AnTengye/contractdiff/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// FileHandler serves the files of local object storage through the signed
+// URLs handed to parsers
+type FileHandler struct {
+	storage *service.LocalStorage
+}
+
+func NewFileHandler(storage *service.LocalStorage) *FileHandler {
+	return &FileHandler{storage: storage}
+}
+
+// Serve streams the object named by the path if the URL's signature is
+// valid and not expired
+func (h *FileHandler) Serve(c *gin.Context) {
+	objectName := strings.TrimPrefix(c.Param("path"), "/")
+
+	err := h.storage.Verify(objectName, c.Query("expires"), c.Query("signature"))
+	if errors.Is(err, service.ErrURLExpired) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "File URL expired"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid file URL signature"})
+		return
+	}
+
+	f, err := h.storage.Open(objectName)
+	if err != nil {
+		slog.Warn("failed to open stored file",
+			"request_id", middleware.GetRequestID(c),
+			"object", objectName,
+			"error", err,
+		)
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	http.ServeContent(c.Writer, c.Request, path.Base(objectName), info.ModTime(), f)
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/AnTengye/contractdiff/backend/config"
+	"github.com/AnTengye/contractdiff/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+func TestFileHandlerServe(t *testing.T) {
+	storage, err := service.NewLocalStorage(&config.StorageConfig{
+		Path:        t.TempDir(),
+		BaseURL:     "http://localhost:8080",
+		SigningKey:  "test-key",
+		ExpireHours: 1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	ctx := context.Background()
+	storage.UploadFile(ctx, "tenant1/abc/test.pdf", strings.NewReader("%PDF-1.4"), 8, "application/pdf")
+
+	rawURL, _ := storage.GetPresignedURL(ctx, "tenant1/abc/test.pdf")
+	signed, _ := url.Parse(rawURL)
+	missing, _ := storage.GetPresignedURL(ctx, "tenant1/abc/missing.pdf")
+	missingURL, _ := url.Parse(missing)
+
+	router := gin.New()
+	router.GET(service.LocalFilesRoute+"*path", NewFileHandler(storage).Serve)
+
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+	}{
+		{"signed", signed.RequestURI(), http.StatusOK},
+		{"unsigned", signed.Path, http.StatusForbidden},
+		{"other object", service.LocalFilesRoute + "tenant2/abc/test.pdf?" + signed.RawQuery, http.StatusForbidden},
+		{"missing object", missingURL.RequestURI(), http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.target, nil))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusOK && w.Body.String() != "%PDF-1.4" {
+				t.Errorf("Expected file content, got %q", w.Body.String())
+			}
+		})
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/AnTengye/contractdiff/backend/config"
+	"github.com/AnTengye/contractdiff/backend/diff"
 	"github.com/AnTengye/contractdiff/backend/handler"
 	"github.com/AnTengye/contractdiff/backend/middleware"
 	"github.com/AnTengye/contractdiff/backend/pkg/logger"
@@ -36,27 +37,59 @@ func main() {
 	slog.Info("configuration loaded successfully")
 
 	// Initialize services
-	minioSvc, err := service.NewMinioService(&cfg.Minio)
+	objects, err := service.NewObjectStorage(context.Background(), cfg)
 	if err != nil {
-		slog.Error("failed to initialize MINIO service", "error", err)
+		slog.Error("failed to initialize object storage", "driver", cfg.Storage.Driver, "error", err)
 		os.Exit(1)
 	}
+	slog.Info("object storage initialized", "driver", cfg.Storage.Driver)
 
-	// Ensure bucket exists
-	if err := minioSvc.EnsureBucket(context.Background()); err != nil {
-		slog.Error("failed to ensure MINIO bucket", "error", err)
+	mineruSvc := service.NewMineruService(&cfg.Mineru)
+
+	parsers, err := service.NewParserRouter(&cfg.Parser,
+		service.NewMineruParser(mineruSvc),
+		service.NewDocxParser(cfg.Mineru.ResultLimits),
+	)
+	if err != nil {
+		slog.Error("failed to initialize document parsers", "error", err)
 		os.Exit(1)
 	}
-
-	mineruSvc := service.NewMineruService(&cfg.Mineru)
+	slog.Info("document parsers registered", "parsers", parsers.Names(), "default", cfg.Parser.Default)
 
 	// Initialize contract store with config
-	service.InitContractStore(&cfg.Store)
+	if err := service.InitContractStore(&cfg.Store); err != nil {
+		slog.Error("failed to initialize contract store", "error", err)
+		os.Exit(1)
+	}
+	defer service.GetContractStore().Close()
+
+	// Start the parse queue, resuming jobs interrupted by the last shutdown
+	artifactStore := service.NewArtifactStore(objects)
+	parseQueue := service.NewParseQueue(service.GetContractStore(), parsers, artifactStore, &cfg.Queue)
+	if err := parseQueue.Start(context.Background()); err != nil {
+		slog.Error("failed to start parse queue", "error", err)
+		os.Exit(1)
+	}
+
+	// Delete objects left behind by deleted contracts
+	cleaner := service.NewObjectCleaner(objects, service.GetContractStore(), time.Duration(cfg.Storage.OrphanMinAgeMinutes)*time.Minute)
+	reconcileCtx, stopReconcile := context.WithCancel(context.Background())
+	defer stopReconcile()
+	go cleaner.Run(reconcileCtx, time.Duration(cfg.Storage.ReconcileIntervalMinutes)*time.Minute)
+
+	// Normalization profiles selectable per comparison
+	profiles, err := diff.NewProfiles(cfg.Normalization.Profiles, cfg.Normalization.Default)
+	if err != nil {
+		slog.Error("invalid normalization profiles", "error", err)
+		os.Exit(1)
+	}
 
 	// Initialize handlers
 	authHandler := handler.NewAuthHandler(cfg)
-	contractHandler := handler.NewContractHandler(minioSvc, mineruSvc)
-	callbackHandler := handler.NewCallbackHandler(mineruSvc)
+	contractHandler := handler.NewContractHandler(objects, parsers, parseQueue, artifactStore, cleaner)
+	callbackHandler := handler.NewCallbackHandler(mineruSvc, parseQueue)
+	comparisonHandler := handler.NewComparisonHandler(profiles)
+	diagnosticsHandler := handler.NewDiagnosticsHandler(mineruSvc, callbackHandler, parseQueue)
 
 	// Setup Gin router
 	gin.SetMode(gin.ReleaseMode)
@@ -100,6 +133,12 @@ func main() {
 		api.POST("/mineru/callback", callbackHandler.HandleCallback)
 	}
 
+	// Files of local storage, authenticated by their signed URL
+	if localStorage, ok := objects.(*service.LocalStorage); ok {
+		fileHandler := handler.NewFileHandler(localStorage)
+		router.GET(service.LocalFilesRoute+"*path", fileHandler.Serve)
+	}
+
 	// Protected routes
 	protected := api.Group("/")
 	protected.Use(middleware.AuthMiddleware(&cfg.Auth))
@@ -109,7 +148,14 @@ func main() {
 		protected.GET("/contracts", contractHandler.List)
 		protected.GET("/contracts/:id", contractHandler.Get)
 		protected.GET("/contracts/:id/status", contractHandler.GetStatus)
+		protected.GET("/contracts/:id/artifacts/*path", contractHandler.Artifacts)
+		protected.GET("/contracts/:id/outline", contractHandler.Outline)
+		protected.POST("/contracts/:id/reprocess", contractHandler.Reprocess)
+		protected.POST("/contracts/:id/cancel", contractHandler.Cancel)
 		protected.DELETE("/contracts/:id", contractHandler.Delete)
+		protected.POST("/comparisons", comparisonHandler.Compare)
+		protected.GET("/comparisons/profiles", comparisonHandler.Profiles)
+		protected.GET("/diagnostics", diagnosticsHandler.Get)
 	}
 
 	// Create server
@@ -143,6 +189,7 @@ func main() {
 		slog.Error("server forced to shutdown", "error", err)
 		os.Exit(1)
 	}
+	parseQueue.Stop()
 
 	slog.Info("server exited gracefully")
 }
@@ -154,7 +201,7 @@ func corsMiddleware() gin.HandlerFunc {
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Request-ID")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, X-Total-Count")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
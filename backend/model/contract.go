@@ -6,16 +6,68 @@ import (
 
 // Contract represents a contract document
 type Contract struct {
-	ID           string    `json:"id"`
-	Filename     string    `json:"filename"`
-	Tenant       string    `json:"tenant"`
-	PDFURL       string    `json:"pdf_url"`
-	Status       string    `json:"status"` // pending, processing, completed, failed
-	MineruTaskID string    `json:"mineru_task_id,omitempty"`
-	JSONData     any       `json:"json_data,omitempty"`
-	ErrorMsg     string    `json:"error_msg,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           string          `json:"id"`
+	Filename     string          `json:"filename"`
+	Tenant       string          `json:"tenant"`
+	PDFURL       string          `json:"pdf_url"`
+	Status       string          `json:"status"`                   // pending, processing, completed, failed, cancelled
+	Parser       string          `json:"parser,omitempty"`         // Document parser, e.g. mineru or docx
+	MineruTaskID string          `json:"mineru_task_id,omitempty"` // Task ID at the parser
+	ContentHash  string          `json:"content_hash,omitempty"`   // SHA-256 of the uploaded file, hex encoded
+	DuplicateOf  string          `json:"duplicate_of,omitempty"`   // Contract whose stored file and parse result are reused
+	SourceObject string          `json:"-"`                        // Object name of the uploaded file when stored under another contract
+	JSONData     any             `json:"json_data,omitempty"`
+	Artifacts    []Artifact      `json:"artifacts,omitempty"` // Files of the parse result kept in object storage
+	Options      *ExtractOptions `json:"options,omitempty"`   // Extraction options the document was parsed with
+	Progress     *Progress       `json:"progress,omitempty"`  // Last progress reported by the parser
+	Revision     int             `json:"revision,omitempty"`  // Run number of the current parse, 0 or 1 for the first
+	Revisions    []Revision      `json:"revisions,omitempty"` // Results of earlier runs, oldest first
+	ResultPrefix string          `json:"-"`                   // Object prefix of the artifacts when not the default
+	ErrorMsg     string          `json:"error_msg,omitempty"`
+	Attempts     int             `json:"attempts,omitempty"`    // Parse job runs so far
+	NextRunAt    *time.Time      `json:"next_run_at,omitempty"` // When the parse job runs next, nil = not scheduled
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+// Artifact is a file of a contract's parse result, e.g. full.md or an
+// extracted image
+type Artifact struct {
+	Path        string `json:"path"` // Relative to the contract's result prefix
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+}
+
+// Revision is the result of an earlier parse run, kept when the contract
+// is reprocessed
+type Revision struct {
+	Number       int             `json:"number"`
+	Parser       string          `json:"parser,omitempty"`
+	Options      *ExtractOptions `json:"options,omitempty"`
+	JSONData     any             `json:"json_data,omitempty"`
+	Artifacts    []Artifact      `json:"artifacts,omitempty"`
+	ResultPrefix string          `json:"result_prefix,omitempty"` // Object prefix of the artifacts
+	CompletedAt  time.Time       `json:"completed_at"`
+}
+
+// ExtractOptions are MinerU extraction options. Unset fields leave the
+// choice to MinerU.
+type ExtractOptions struct {
+	ModelVersion  string `json:"model_version,omitempty"` // pipeline or vlm
+	IsOCR         *bool  `json:"is_ocr,omitempty"`
+	EnableFormula *bool  `json:"enable_formula,omitempty"`
+	EnableTable   *bool  `json:"enable_table,omitempty"`
+	Language      string `json:"language,omitempty"`    // Document language, e.g. ch or en
+	PageRanges    string `json:"page_ranges,omitempty"` // e.g. "2,4-6"
+}
+
+// Progress is a parse task's progress as last reported by its parser
+type Progress struct {
+	State          string     `json:"state"` // Parser's own state, e.g. waiting-file, running or converting
+	ExtractedPages int        `json:"extracted_pages"`
+	TotalPages     int        `json:"total_pages"`
+	StartedAt      *time.Time `json:"started_at,omitempty"` // When extraction started
+	UpdatedAt      time.Time  `json:"updated_at"`           // When this progress was reported
 }
 
 // ContractStatus constants
@@ -24,4 +76,5 @@ const (
 	StatusProcessing = "processing"
 	StatusCompleted  = "completed"
 	StatusFailed     = "failed"
+	StatusCancelled  = "cancelled"
 )
@@ -0,0 +1,193 @@
+package minerufake
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/AnTengye/contractdiff/backend/config"
+	"github.com/AnTengye/contractdiff/backend/document"
+	"github.com/AnTengye/contractdiff/backend/service"
+)
+
+// Layout of pages built from plain text, in points on A4
+const (
+	pageWidth   = 595.0
+	pageHeight  = 842.0
+	pageMargin  = 72.0
+	lineHeight  = 16.0
+	charsPerRow = 45.0 // Full-width characters per line
+)
+
+// BuildResult builds a middle.json document from a DOCX or UTF-8 text
+// file. DOCX files are parsed like the built-in DOCX parser does; text is
+// split into paragraphs at blank lines and into pages at form feeds.
+func BuildResult(data []byte) (map[string]interface{}, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return service.ExtractDocx(bytes.NewReader(data), int64(len(data)), config.DefaultResultLimits)
+	}
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("document is neither DOCX nor UTF-8 text")
+	}
+	return TextDocument(string(data)), nil
+}
+
+// TextDocument lays out plain text as middle.json pages
+func TextDocument(text string) map[string]interface{} {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	pages := []interface{}{}
+	for _, pageText := range strings.Split(text, "\f") {
+		blocks := []interface{}{}
+		y := pageMargin
+		flush := func() {
+			pages = append(pages, textPage(len(pages), blocks))
+			blocks = []interface{}{}
+			y = pageMargin
+		}
+
+		for _, para := range strings.Split(pageText, "\n\n") {
+			para = strings.TrimSpace(para)
+			if para == "" {
+				continue
+			}
+			height := float64(textRows(para)) * lineHeight
+			if y+height > pageHeight-pageMargin && len(blocks) > 0 {
+				flush()
+			}
+			bbox := []float64{pageMargin, y, pageWidth - pageMargin, y + height}
+			blocks = append(blocks, map[string]interface{}{
+				"type":  "text",
+				"bbox":  bbox,
+				"index": len(blocks),
+				"lines": []interface{}{
+					map[string]interface{}{
+						"bbox": bbox,
+						"spans": []interface{}{
+							map[string]interface{}{"type": "text", "content": para, "bbox": bbox},
+						},
+					},
+				},
+			})
+			y += height + lineHeight/2
+		}
+		flush()
+	}
+
+	return map[string]interface{}{"pdf_info": pages}
+}
+
+func textPage(idx int, blocks []interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"page_idx":         idx,
+		"page_size":        []float64{pageWidth, pageHeight},
+		"para_blocks":      blocks,
+		"discarded_blocks": []interface{}{},
+	}
+}
+
+// textRows estimates the lines text wraps to, counting ASCII as half width
+func textRows(text string) int {
+	rows := 0
+	for _, line := range strings.Split(text, "\n") {
+		width := 0.0
+		for _, r := range line {
+			if r < utf8.RuneSelf {
+				width += 0.5
+			} else {
+				width++
+			}
+		}
+		rows += max(1, int((width+charsPerRow-1)/charsPerRow))
+	}
+	return rows
+}
+
+// contentItem is an entry of content_list.json
+type contentItem struct {
+	Type      string `json:"type"`
+	Text      string `json:"text,omitempty"`
+	TableBody string `json:"table_body,omitempty"`
+	TextLevel int    `json:"text_level,omitempty"`
+	PageIdx   int    `json:"page_idx"`
+}
+
+// contentList flattens a middle.json document into content_list.json items
+func contentList(middle map[string]interface{}) []contentItem {
+	items := []contentItem{}
+	doc, err := document.Parse(middle)
+	if err != nil {
+		return items
+	}
+	for _, page := range doc.Pages {
+		for _, block := range page.Blocks {
+			switch block.Type {
+			case document.BlockTable:
+				var rows []string
+				for _, row := range block.Children {
+					rows = append(rows, row.Text())
+				}
+				items = append(items, contentItem{Type: "table", TableBody: strings.Join(rows, "\n"), PageIdx: page.Index})
+			case document.BlockTitle:
+				items = append(items, contentItem{Type: "text", Text: block.Text(), TextLevel: 1, PageIdx: page.Index})
+			default:
+				items = append(items, contentItem{Type: "text", Text: block.Text(), PageIdx: page.Index})
+			}
+		}
+	}
+	return items
+}
+
+// resultZip packages a result like MinerU's full_zip_url download
+func resultZip(middle map[string]interface{}) ([]byte, error) {
+	items := contentList(middle)
+
+	var md strings.Builder
+	for _, item := range items {
+		switch {
+		case item.TextLevel > 0:
+			md.WriteString("# " + item.Text)
+		case item.Type == "table":
+			md.WriteString(item.TableBody)
+		default:
+			md.WriteString(item.Text)
+		}
+		md.WriteString("\n\n")
+	}
+
+	middleJSON, err := json.Marshal(middle)
+	if err != nil {
+		return nil, err
+	}
+	contentJSON, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"full.md", []byte(md.String())},
+		{"content_list.json", contentJSON},
+		{"middle.json", middleJSON},
+	}
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
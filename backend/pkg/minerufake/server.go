@@ -0,0 +1,349 @@
+// Package minerufake implements enough of the MinerU API to develop and
+// test without a MinerU account: task creation and status, result ZIPs and
+// signed callbacks. Results are built from a local DOCX or text file, or
+// from the document the task points at.
+package minerufake
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/service"
+	"github.com/google/uuid"
+)
+
+// APIPrefix is the path the fake API is served under, matching
+// https://mineru.net/api/v4
+const APIPrefix = "/api/v4"
+
+// mineruZone is the zone MinerU reports times in, without an offset
+var mineruZone = time.FixedZone("UTC+8", 8*60*60)
+
+// Options configures a fake server
+type Options struct {
+	Token          string        // Required bearer token, empty = accept any
+	UID            string        // Account UID used to sign callbacks
+	Document       string        // Local DOCX or text file every task returns, empty = download the task URL
+	ProcessingTime time.Duration // Time a task stays running
+}
+
+// Server is a fake MinerU API
+type Server struct {
+	opts   Options
+	mux    *http.ServeMux
+	client *http.Client
+
+	mu    sync.Mutex
+	tasks map[string]*task
+	wg    sync.WaitGroup
+}
+
+type task struct {
+	id         string
+	dataID     string
+	url        string
+	callback   string
+	seed       string
+	backend    string
+	baseURL    string // Address results are downloaded from
+	state      string // pending, running, done, failed
+	errMsg     string
+	startedAt  time.Time
+	totalPages int
+	zip        []byte
+}
+
+func NewServer(opts Options) *Server {
+	s := &Server{
+		opts:   opts,
+		mux:    http.NewServeMux(),
+		client: &http.Client{Timeout: 60 * time.Second},
+		tasks:  make(map[string]*task),
+	}
+	s.mux.HandleFunc("POST "+APIPrefix+"/extract/task", s.createTask)
+	s.mux.HandleFunc("GET "+APIPrefix+"/extract/task/{id}", s.getTask)
+	s.mux.HandleFunc("GET /results/{id}", s.getResult)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Wait waits for tasks being processed, including their callbacks
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+// TestServer is a fake MinerU API listening on a local port
+type TestServer struct {
+	*Server
+	HTTP *httptest.Server
+}
+
+// NewTestServer starts a fake server for tests. Point MineruConfig.APIURL at
+// APIURL and call Close when done.
+func NewTestServer(opts Options) *TestServer {
+	s := NewServer(opts)
+	return &TestServer{Server: s, HTTP: httptest.NewServer(s)}
+}
+
+// APIURL is the api_url of the fake server
+func (ts *TestServer) APIURL() string {
+	return ts.HTTP.URL + APIPrefix
+}
+
+// Close waits for running tasks and stops the server
+func (ts *TestServer) Close() {
+	ts.Wait()
+	ts.HTTP.Close()
+}
+
+type response struct {
+	Code    interface{} `json:"code"`
+	Message string      `json:"msg"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if s.opts.Token == "" || r.Header.Get("Authorization") == "Bearer "+s.opts.Token {
+		return true
+	}
+	writeJSON(w, http.StatusUnauthorized, response{Code: "A0202", Message: "token error"})
+	return false
+}
+
+func (s *Server) createTask(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+
+	var req service.MineruTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == "" {
+		writeJSON(w, http.StatusOK, response{Code: -10002, Message: "invalid request parameters"})
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	t := &task{
+		id:       uuid.New().String(),
+		dataID:   req.DataID,
+		url:      req.URL,
+		callback: req.Callback,
+		seed:     req.Seed,
+		backend:  req.ModelVersion,
+		baseURL:  scheme + "://" + r.Host,
+		state:    "pending",
+	}
+
+	s.mu.Lock()
+	s.tasks[t.id] = t
+	s.mu.Unlock()
+
+	slog.Info("fake MinerU task created",
+		"task_id", t.id,
+		"data_id", t.dataID,
+		"url", t.url,
+	)
+
+	s.wg.Add(1)
+	go s.process(t)
+
+	writeJSON(w, http.StatusOK, response{Code: 0, Message: "ok", Data: map[string]string{"task_id": t.id}})
+}
+
+func (s *Server) getTask(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[r.PathValue("id")]
+	if !ok {
+		writeJSON(w, http.StatusOK, response{Code: -60012, Message: "task not found"})
+		return
+	}
+
+	var status service.MineruTaskStatusResponse
+	status.Data.TaskID = t.id
+	status.Data.DataID = t.dataID
+	status.Data.State = t.state
+	status.Data.ErrorMsg = t.errMsg
+	status.Data.ModelVersion = t.backend
+	switch t.state {
+	case "running":
+		extracted := t.totalPages
+		if s.opts.ProcessingTime > 0 {
+			elapsed := time.Since(t.startedAt)
+			extracted = min(t.totalPages, int(float64(t.totalPages)*float64(elapsed)/float64(s.opts.ProcessingTime)))
+		}
+		status.Data.ExtractProgress.ExtractedPages = extracted
+		status.Data.ExtractProgress.TotalPages = t.totalPages
+		status.Data.ExtractProgress.StartTime = t.startedAt.In(mineruZone).Format(time.DateTime)
+	case "done":
+		status.Data.FullZipURL = t.baseURL + "/results/" + t.id + ".zip"
+	}
+
+	writeJSON(w, http.StatusOK, response{Code: 0, Message: "ok", Data: status.Data})
+}
+
+func (s *Server) getResult(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSuffix(r.PathValue("id"), ".zip")
+
+	s.mu.Lock()
+	t, ok := s.tasks[id]
+	var data []byte
+	if ok {
+		data = t.zip
+	}
+	s.mu.Unlock()
+
+	if data == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Write(data)
+}
+
+// process builds a task's result, keeps it running for the processing time
+// and finishes it
+func (s *Server) process(t *task) {
+	defer s.wg.Done()
+
+	middle, err := s.document(t.url)
+	var zipData []byte
+	if err == nil {
+		if t.backend != "" {
+			middle["_backend"] = t.backend
+		}
+		zipData, err = resultZip(middle)
+	}
+	if err != nil {
+		s.finish(t, "failed", nil, err.Error())
+		return
+	}
+
+	pages, _ := middle["pdf_info"].([]interface{})
+	s.mu.Lock()
+	t.state = "running"
+	t.startedAt = time.Now()
+	t.totalPages = len(pages)
+	s.mu.Unlock()
+
+	time.Sleep(s.opts.ProcessingTime)
+	s.finish(t, "done", zipData, "")
+}
+
+// document builds the result for a task's document
+func (s *Server) document(url string) (map[string]interface{}, error) {
+	if s.opts.Document != "" {
+		data, err := os.ReadFile(s.opts.Document)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read document: %w", err)
+		}
+		return BuildResult(data)
+	}
+
+	resp, err := s.client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download file: HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	return BuildResult(data)
+}
+
+func (s *Server) finish(t *task, state string, zipData []byte, errMsg string) {
+	s.mu.Lock()
+	t.state = state
+	t.zip = zipData
+	t.errMsg = errMsg
+	s.mu.Unlock()
+
+	slog.Info("fake MinerU task finished",
+		"task_id", t.id,
+		"state", state,
+		"error_msg", errMsg,
+	)
+
+	if t.callback != "" {
+		s.sendCallback(t)
+	}
+}
+
+// CallbackContent is the content of a callback, signed in its checksum
+type CallbackContent struct {
+	TaskID     string `json:"task_id"`
+	DataID     string `json:"data_id"`
+	State      string `json:"state"`
+	FullZipURL string `json:"full_zip_url,omitempty"`
+	ErrorMsg   string `json:"err_msg,omitempty"`
+}
+
+// sendCallback posts the task result to its callback URL, signed with
+// SHA256(uid + seed + content)
+func (s *Server) sendCallback(t *task) {
+	s.mu.Lock()
+	content := CallbackContent{
+		TaskID:   t.id,
+		DataID:   t.dataID,
+		State:    t.state,
+		ErrorMsg: t.errMsg,
+	}
+	if t.state == "done" {
+		content.FullZipURL = t.baseURL + "/results/" + t.id + ".zip"
+	}
+	s.mu.Unlock()
+
+	data, _ := json.Marshal(content)
+	hash := sha256.Sum256([]byte(s.opts.UID + t.seed + string(data)))
+	body, _ := json.Marshal(service.MineruCallbackPayload{
+		Checksum: hex.EncodeToString(hash[:]),
+		Content:  string(data),
+	})
+
+	resp, err := s.client.Post(t.callback, "application/json", bytes.NewReader(body))
+	if err != nil {
+		slog.Warn("fake MinerU callback failed",
+			"task_id", t.id,
+			"callback", t.callback,
+			"error", err,
+		)
+		return
+	}
+	resp.Body.Close()
+	slog.Info("fake MinerU callback sent",
+		"task_id", t.id,
+		"callback", t.callback,
+		"status", resp.StatusCode,
+	)
+}
@@ -0,0 +1,201 @@
+package minerufake_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/config"
+	"github.com/AnTengye/contractdiff/backend/model"
+	"github.com/AnTengye/contractdiff/backend/pkg/minerufake"
+	"github.com/AnTengye/contractdiff/backend/service"
+)
+
+const testContract = "第一条 合同标的\n\n甲方向乙方采购设备。\n\f第二条 付款\n\n货到付款。"
+
+// serveDocument serves content as the uploaded document
+func serveDocument(t *testing.T, content string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, content)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/contract.txt"
+}
+
+func TestFakeServerUploadToCompleted(t *testing.T) {
+	fake := minerufake.NewTestServer(minerufake.Options{Token: "test-token"})
+	defer fake.Close()
+
+	mineruSvc := service.NewMineruService(&config.MineruConfig{APIURL: fake.APIURL(), APIToken: "test-token", ModelVersion: "vlm"})
+	parsers, err := service.NewParserRouter(&config.ParserConfig{}, service.NewMineruParser(mineruSvc))
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+
+	store := service.NewMemoryStore(0)
+	store.Save(&model.Contract{
+		ID:        "fake-e2e",
+		Filename:  "contract.pdf",
+		Tenant:    "tenant1",
+		PDFURL:    serveDocument(t, testContract),
+		Status:    model.StatusPending,
+		CreatedAt: time.Now(),
+	})
+
+	queue := service.NewParseQueue(store, parsers, nil, &config.QueueConfig{Workers: 1, PollIntervalSeconds: 1, MaxAttempts: 10})
+	if err := queue.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start queue: %v", err)
+	}
+	defer queue.Stop()
+
+	var contract *model.Contract
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		contract, _ = store.Get("fake-e2e")
+		if contract.Status == model.StatusCompleted || contract.Status == model.StatusFailed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for completion, got %+v", contract)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if contract.Status != model.StatusCompleted {
+		t.Fatalf("Expected completed contract, got %s '%s'", contract.Status, contract.ErrorMsg)
+	}
+	data, _ := json.Marshal(contract.JSONData)
+	var result struct {
+		Backend string `json:"_backend"`
+		PDFInfo []struct {
+			ParaBlocks []json.RawMessage `json:"para_blocks"`
+		} `json:"pdf_info"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Failed to parse result: %v", err)
+	}
+	if len(result.PDFInfo) != 2 || len(result.PDFInfo[0].ParaBlocks) != 2 || result.Backend != "vlm" {
+		t.Errorf("Expected 2 pages of 2 blocks from vlm, got %s", data)
+	}
+	if !strings.Contains(string(data), "甲方向乙方采购设备。") {
+		t.Errorf("Expected document text in result, got %s", data)
+	}
+}
+
+func TestFakeServerCallback(t *testing.T) {
+	verifier := service.NewMineruService(&config.MineruConfig{UID: "test-uid", Seed: "test-seed"})
+
+	received := make(chan minerufake.CallbackContent, 1)
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload service.MineruCallbackPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		if !verifier.VerifyCallbackPayload(&payload) {
+			t.Error("Expected callback checksum to verify")
+		}
+		var content minerufake.CallbackContent
+		json.Unmarshal([]byte(payload.Content), &content)
+		received <- content
+	}))
+	defer callback.Close()
+
+	fake := minerufake.NewTestServer(minerufake.Options{UID: "test-uid"})
+	defer fake.Close()
+
+	mineruSvc := service.NewMineruService(&config.MineruConfig{
+		APIURL:      fake.APIURL(),
+		CallbackURL: callback.URL,
+		Seed:        "test-seed",
+	})
+	task, err := mineruSvc.CreateTask(context.Background(), serveDocument(t, testContract), "contract-1", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var content minerufake.CallbackContent
+	select {
+	case content = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for callback")
+	}
+	if content.TaskID != task.Data.TaskID || content.DataID != "contract-1" || content.State != "done" {
+		t.Errorf("Unexpected callback content: %+v", content)
+	}
+
+	z, err := mineruSvc.FetchResultZip(context.Background(), content.FullZipURL)
+	if err != nil {
+		t.Fatalf("Failed to fetch result: %v", err)
+	}
+	defer z.Close()
+	result, err := z.ExtractJSON()
+	if err != nil {
+		t.Fatalf("Failed to extract JSON: %v", err)
+	}
+	if _, ok := result["pdf_info"]; !ok {
+		t.Errorf("Expected middle.json in result ZIP, got keys %v", result)
+	}
+}
+
+func TestFakeServerErrors(t *testing.T) {
+	fake := minerufake.NewTestServer(minerufake.Options{Token: "test-token"})
+	defer fake.Close()
+
+	var apiErr *service.MineruError
+
+	badToken := service.NewMineruService(&config.MineruConfig{APIURL: fake.APIURL(), APIToken: "wrong"})
+	if _, err := badToken.CreateTask(context.Background(), "http://example.com/a.pdf", "a", nil); !errors.As(err, &apiErr) || apiErr.Code != "A0202" {
+		t.Errorf("Expected token error, got %v", err)
+	}
+
+	mineruSvc := service.NewMineruService(&config.MineruConfig{APIURL: fake.APIURL(), APIToken: "test-token"})
+	if _, err := mineruSvc.GetTaskStatus(context.Background(), "missing"); !errors.As(err, &apiErr) || apiErr.Code != "-60012" {
+		t.Errorf("Expected task not found, got %v", err)
+	}
+
+	// A document that cannot be downloaded fails the task
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	task, err := mineruSvc.CreateTask(context.Background(), missing.URL+"/gone.pdf", "b", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	fake.Wait()
+	status, err := mineruSvc.GetTaskStatus(context.Background(), task.Data.TaskID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if status.Data.State != "failed" || status.Data.ErrorMsg == "" {
+		t.Errorf("Expected failed task with error, got %+v", status.Data)
+	}
+}
+
+func TestTextDocument(t *testing.T) {
+	pages := minerufake.TextDocument(testContract)["pdf_info"].([]interface{})
+	if len(pages) != 2 {
+		t.Fatalf("Expected 2 pages, got %d", len(pages))
+	}
+
+	// Long text flows onto further pages
+	long := strings.Repeat(strings.Repeat("条款内容", 40)+"\n\n", 30)
+	pages = minerufake.TextDocument(long)["pdf_info"].([]interface{})
+	if len(pages) < 3 {
+		t.Errorf("Expected long text to span several pages, got %d", len(pages))
+	}
+	blocks := 0
+	for _, p := range pages {
+		blocks += len(p.(map[string]interface{})["para_blocks"].([]interface{}))
+	}
+	if blocks != 30 {
+		t.Errorf("Expected 30 paragraphs, got %d", blocks)
+	}
+
+	if _, err := minerufake.BuildResult([]byte{0xff, 0xfe, 0x00}); err == nil {
+		t.Error("Expected error for binary document")
+	}
+}
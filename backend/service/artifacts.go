@@ -0,0 +1,136 @@
+package service
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/AnTengye/contractdiff/backend/model"
+)
+
+// ErrArtifactNotFound is returned for paths missing from a contract's
+// artifact manifest
+var ErrArtifactNotFound = errors.New("artifact not found")
+
+// ResultFile is a file produced by a parser besides its JSON result. Its
+// content is streamed from Open, so that large results are never held in
+// memory.
+type ResultFile struct {
+	Path string // Slash-separated path relative to the result root
+	Size int64
+	Open func() (io.ReadCloser, error)
+}
+
+// ArtifactStore keeps the files of parse results under the contract's
+// object prefix, tenant/id/result/ for the first run and
+// tenant/id/revisions/N/result/ for later ones
+type ArtifactStore struct {
+	objects ObjectStorage
+}
+
+func NewArtifactStore(objects ObjectStorage) *ArtifactStore {
+	return &ArtifactStore{objects: objects}
+}
+
+// ArtifactPrefix returns the object prefix of a contract's artifacts
+func ArtifactPrefix(tenant, contractID string) string {
+	return tenant + "/" + contractID + "/result/"
+}
+
+// ResultPrefix returns the object prefix of the artifacts of a contract's
+// current run. Duplicates use the result of the contract they duplicate.
+func ResultPrefix(contract *model.Contract) string {
+	if contract.ResultPrefix != "" {
+		return contract.ResultPrefix
+	}
+	if contract.Revision > 1 {
+		return fmt.Sprintf("%s/%s/revisions/%d/result/", contract.Tenant, contract.ID, contract.Revision)
+	}
+	return ArtifactPrefix(contract.Tenant, cmp.Or(contract.DuplicateOf, contract.ID))
+}
+
+// Save uploads files and returns their manifest
+func (s *ArtifactStore) Save(ctx context.Context, contract *model.Contract, files []ResultFile) ([]model.Artifact, error) {
+	prefix := ResultPrefix(contract)
+	artifacts := make([]model.Artifact, 0, len(files))
+	for _, f := range files {
+		contentType, err := s.upload(ctx, prefix, f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store artifact %s: %w", f.Path, err)
+		}
+		artifacts = append(artifacts, model.Artifact{
+			Path:        f.Path,
+			Size:        f.Size,
+			ContentType: contentType,
+		})
+	}
+	return artifacts, nil
+}
+
+// upload streams a file to the object storage and returns its content type
+func (s *ArtifactStore) upload(ctx context.Context, prefix string, f ResultFile) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	// Types not known by extension are sniffed from the first bytes
+	r := bufio.NewReaderSize(rc, 512)
+	head, err := r.Peek(512)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	contentType := artifactContentType(f.Path, head)
+	return contentType, s.objects.UploadFile(ctx, prefix+f.Path, r, f.Size, contentType)
+}
+
+// Open opens an artifact listed in the contract's manifest
+func (s *ArtifactStore) Open(ctx context.Context, contract *model.Contract, artifactPath string) (io.ReadCloser, *model.Artifact, error) {
+	for i := range contract.Artifacts {
+		a := &contract.Artifacts[i]
+		if a.Path != artifactPath {
+			continue
+		}
+		rc, err := s.objects.GetObject(ctx, ResultPrefix(contract)+a.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return rc, a, nil
+	}
+	return nil, nil, ErrArtifactNotFound
+}
+
+// cleanArtifactPath normalizes a ZIP entry name, rejecting absolute paths
+// and paths leaving the result root
+func cleanArtifactPath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") {
+		return "", false
+	}
+	name = path.Clean(name)
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	return name, true
+}
+
+func artifactContentType(name string, data []byte) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md":
+		return "text/markdown; charset=utf-8"
+	case ".json":
+		return "application/json"
+	}
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	return http.DetectContentType(data)
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/AnTengye/contractdiff/backend/config"
+	"github.com/AnTengye/contractdiff/backend/model"
+)
+
+// memoryObjects is an ObjectStorage keeping objects in memory
+type memoryObjects struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	err     error // Returned by UploadFile when set
+}
+
+func newMemoryObjects() *memoryObjects {
+	return &memoryObjects{objects: make(map[string][]byte)}
+}
+
+func (m *memoryObjects) UploadFile(ctx context.Context, objectName string, reader io.Reader, size int64, contentType string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return fmt.Errorf("expected %d bytes for %s, got %d", size, objectName, len(data))
+	}
+	m.objects[objectName] = data
+	return nil
+}
+
+func (m *memoryObjects) GetObject(ctx context.Context, objectName string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[objectName]
+	if !ok {
+		return nil, errors.New("object not found")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memoryObjects) DeleteFile(ctx context.Context, objectName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, objectName)
+	return nil
+}
+
+func (m *memoryObjects) ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var objects []ObjectInfo
+	for name, data := range m.objects {
+		if strings.HasPrefix(name, prefix) {
+			objects = append(objects, ObjectInfo{Name: name, Size: int64(len(data))})
+		}
+	}
+	return objects, nil
+}
+
+func (m *memoryObjects) GetPresignedURL(ctx context.Context, objectName string) (string, error) {
+	return "https://objects.example.com/" + objectName, nil
+}
+
+func (m *memoryObjects) names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.objects))
+	for name := range m.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// memoryFile returns a result file read from data
+func memoryFile(path, data string) ResultFile {
+	return ResultFile{
+		Path: path,
+		Size: int64(len(data)),
+		Open: func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(data)), nil },
+	}
+}
+
+// buildZip returns a ZIP of the named files
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create ZIP entry: %v", err)
+		}
+		w.Write([]byte(files[name]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close ZIP: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestResultZipFiles(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"full.md":             "# 合同",
+		"middle.json":         `{"pdf_info": []}`,
+		"images/":             "",
+		"images/0.jpg":        "\xff\xd8\xff",
+		"../escape.txt":       "x",
+		"/etc/passwd":         "x",
+		"nested/../layout.js": "x",
+	})
+
+	z, err := OpenResultZip(bytes.NewReader(data), int64(len(data)), config.ResultLimits{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var paths []string
+	for _, f := range z.Files() {
+		paths = append(paths, f.Path)
+		if f.Path != "full.md" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Path, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		if string(data) != "# 合同" || f.Size != int64(len(data)) {
+			t.Errorf("Expected full.md of %d bytes, got %q", f.Size, data)
+		}
+	}
+	sort.Strings(paths)
+	want := []string{"full.md", "images/0.jpg", "layout.js", "middle.json"}
+	if len(paths) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, paths)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, paths)
+			break
+		}
+	}
+
+	if _, err := OpenResultZip(bytes.NewReader([]byte("not a zip")), 9, config.ResultLimits{}); err == nil {
+		t.Error("Expected error for invalid ZIP")
+	}
+}
+
+func TestArtifactStore(t *testing.T) {
+	objects := newMemoryObjects()
+	store := NewArtifactStore(objects)
+	contract := &model.Contract{ID: "c1", Tenant: "tenant1"}
+
+	artifacts, err := store.Save(context.Background(), contract, []ResultFile{
+		memoryFile("full.md", "# 合同"),
+		memoryFile("middle.json", `{}`),
+		memoryFile("images/0.png", "\x89PNG\r\n\x1a\n"),
+		memoryFile("layout", "<html><body></body></html>"),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	wantTypes := map[string]string{
+		"full.md":      "text/markdown; charset=utf-8",
+		"middle.json":  "application/json",
+		"images/0.png": "image/png",
+		"layout":       "text/html; charset=utf-8",
+	}
+	for _, a := range artifacts {
+		if a.ContentType != wantTypes[a.Path] {
+			t.Errorf("Expected content type %s for %s, got %s", wantTypes[a.Path], a.Path, a.ContentType)
+		}
+	}
+	if names := objects.names(); len(names) != 4 || names[0] != "tenant1/c1/result/full.md" {
+		t.Errorf("Expected objects under the contract prefix, got %v", names)
+	}
+
+	contract.Artifacts = artifacts
+	rc, artifact, err := store.Open(context.Background(), contract, "full.md")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "# 合同" || artifact.Size != int64(len(data)) {
+		t.Errorf("Unexpected artifact %+v: %q", artifact, data)
+	}
+
+	// Only paths in the manifest can be opened
+	if _, _, err := store.Open(context.Background(), contract, "../c2/result/full.md"); !errors.Is(err, ErrArtifactNotFound) {
+		t.Errorf("Expected ErrArtifactNotFound, got %v", err)
+	}
+
+	// A duplicate reads the artifacts of the contract it duplicates
+	duplicate := &model.Contract{ID: "c3", Tenant: "tenant1", DuplicateOf: "c1", Artifacts: artifacts}
+	rc, _, err = store.Open(context.Background(), duplicate, "full.md")
+	if err != nil {
+		t.Fatalf("Unexpected error opening duplicate: %v", err)
+	}
+	rc.Close()
+
+	objects.err = errors.New("storage unavailable")
+	if _, err := store.Save(context.Background(), contract, []ResultFile{memoryFile("a.md", "")}); err == nil {
+		t.Error("Expected error when storage fails")
+	}
+}
+
+func TestContractStoreUpdateArtifacts(t *testing.T) {
+	store := newTestStore(100)
+	store.Save(&model.Contract{ID: "artifacts-test", Status: model.StatusProcessing})
+
+	artifacts := []model.Artifact{{Path: "full.md", Size: 8, ContentType: "text/markdown; charset=utf-8"}}
+	if err := store.UpdateArtifacts("artifacts-test", artifacts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	artifacts[0].Path = "changed.md"
+
+	contract := mustGet(t, store, "artifacts-test")
+	if len(contract.Artifacts) != 1 || contract.Artifacts[0].Path != "full.md" {
+		t.Errorf("Expected stored copy of the manifest, got %+v", contract.Artifacts)
+	}
+	if contract.Status != model.StatusProcessing {
+		t.Errorf("Expected status to be unchanged, got %s", contract.Status)
+	}
+
+	if err := store.UpdateArtifacts("non-existent", artifacts); err != nil {
+		t.Errorf("Unexpected error for missing contract: %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// ErrCircuitOpen is returned instead of calling a service whose breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreaker stops calls to a failing service. It opens after threshold
+// consecutive failures, rejects calls for the cooldown, then lets a single
+// trial call through: success closes it, failure opens it again.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	trial    bool // Trial call in flight while half open
+	trips    int64
+	rejected int64
+}
+
+// BreakerStats describes a circuit breaker
+type BreakerStats struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Threshold           int        `json:"threshold"`
+	Trips               int64      `json:"trips"`    // Times the breaker opened
+	Rejected            int64      `json:"rejected"` // Calls refused while open
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+}
+
+// NewCircuitBreaker creates a closed breaker. A threshold of 0 or less
+// never opens.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		state:     BreakerClosed,
+	}
+}
+
+// Allow returns ErrCircuitOpen when the call must not be made. Every
+// allowed call must be followed by Success, Failure or Abandon.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			b.rejected++
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.trial = true
+		return nil
+	case BreakerHalfOpen:
+		if b.trial {
+			b.rejected++
+			return ErrCircuitOpen
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// Success records a call that reached the service
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = BreakerClosed
+	b.failures = 0
+	b.trial = false
+}
+
+// Abandon records a call given up by its caller without an outcome,
+// letting another trial call through while half open
+func (b *CircuitBreaker) Abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// Failure records a call that failed because of the service
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+	if b.threshold <= 0 {
+		return
+	}
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		if b.state != BreakerOpen {
+			b.trips++
+		}
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Stats returns the breaker state
+func (b *CircuitBreaker) Stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := BreakerStats{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		Threshold:           b.threshold,
+		Trips:               b.trips,
+		Rejected:            b.rejected,
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		stats.OpenedAt = &openedAt
+	}
+	return stats
+}
+
+// Backoff computes exponential retry delays with jitter
+type Backoff struct {
+	Base time.Duration // Delay before the first retry
+	Max  time.Duration // Upper bound, 0 = unbounded
+}
+
+// Delay returns the wait before retry n, counting from 1. The delay doubles
+// with each retry and is randomized between half and all of it, so clients
+// failing together do not retry together.
+func (b Backoff) Delay(n int) time.Duration {
+	if b.Base <= 0 {
+		return 0
+	}
+	d := b.Base
+	for i := 1; i < n && i < 32 && (b.Max <= 0 || d < b.Max); i++ {
+		d *= 2
+	}
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
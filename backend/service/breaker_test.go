@@ -0,0 +1,98 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	b := NewCircuitBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Expected closed breaker to allow call %d, got %v", i, err)
+		}
+		b.Failure()
+	}
+	if stats := b.Stats(); stats.State != BreakerOpen || stats.Trips != 1 || stats.OpenedAt == nil {
+		t.Fatalf("Expected open breaker after 2 failures, got %+v", stats)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+
+	// After the cooldown a single trial call goes through
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected trial call after cooldown, got %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected second call to be rejected while half open, got %v", err)
+	}
+	if state := b.Stats().State; state != BreakerHalfOpen {
+		t.Errorf("Expected half open breaker, got %s", state)
+	}
+
+	// A failed trial opens the breaker again
+	b.Failure()
+	if stats := b.Stats(); stats.State != BreakerOpen || stats.Trips != 2 || stats.Rejected != 2 {
+		t.Errorf("Expected reopened breaker, got %+v", stats)
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected trial call after cooldown, got %v", err)
+	}
+	// An abandoned trial lets the next call try instead
+	b.Abandon()
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected trial call after an abandoned trial, got %v", err)
+	}
+	b.Success()
+	if stats := b.Stats(); stats.State != BreakerClosed || stats.ConsecutiveFailures != 0 || stats.OpenedAt != nil {
+		t.Errorf("Expected closed breaker after successful trial, got %+v", stats)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := NewCircuitBreaker(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Expected disabled breaker to allow calls, got %v", err)
+		}
+		b.Failure()
+	}
+	if stats := b.Stats(); stats.State != BreakerClosed || stats.ConsecutiveFailures != 10 {
+		t.Errorf("Expected closed breaker counting failures, got %+v", stats)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Base: 100 * time.Millisecond, Max: time.Second}
+
+	tests := []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{4, 400 * time.Millisecond, 800 * time.Millisecond},
+		{5, 500 * time.Millisecond, time.Second},
+		{100, 500 * time.Millisecond, time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if d := b.Delay(tt.retry); d < tt.min || d > tt.max {
+				t.Errorf("Retry %d: expected delay in [%v, %v], got %v", tt.retry, tt.min, tt.max, d)
+			}
+		}
+	}
+
+	if d := (Backoff{}).Delay(3); d != 0 {
+		t.Errorf("Expected no delay without a base, got %v", d)
+	}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/model"
+)
+
+// ObjectError is an object that could not be deleted
+type ObjectError struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// CleanupReport lists the objects removed by a cleanup and those that could
+// not be removed
+type CleanupReport struct {
+	Deleted []string      `json:"deleted"`
+	Failed  []ObjectError `json:"failed,omitempty"`
+}
+
+func (r *CleanupReport) fail(name string, err error) {
+	r.Failed = append(r.Failed, ObjectError{Name: name, Error: err.Error()})
+}
+
+// ContractPrefix returns the object prefix of everything stored for a
+// contract: its uploaded file and the results of every run
+func ContractPrefix(tenant, contractID string) string {
+	return tenant + "/" + contractID + "/"
+}
+
+// contractPrefixOf returns the contract prefix of an object name, or ""
+// for names outside the tenant/id/ layout
+func contractPrefixOf(name string) string {
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) < 3 || parts[0] == "" || parts[1] == "" {
+		return ""
+	}
+	return parts[0] + "/" + parts[1] + "/"
+}
+
+// usedPrefixes returns the contract prefixes whose objects a contract
+// uses: its own and those of the contracts its file or results were
+// reused from
+func usedPrefixes(contract *model.Contract) []string {
+	prefixes := []string{ContractPrefix(contract.Tenant, contract.ID)}
+	if contract.DuplicateOf != "" {
+		prefixes = append(prefixes, ContractPrefix(contract.Tenant, contract.DuplicateOf))
+	}
+	resultPrefixes := []string{ResultPrefix(contract)}
+	for _, rev := range contract.Revisions {
+		resultPrefixes = append(resultPrefixes, rev.ResultPrefix)
+	}
+	for _, p := range resultPrefixes {
+		if p := contractPrefixOf(p); p != "" {
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes
+}
+
+// ObjectCleaner deletes the objects of deleted contracts. Objects shared
+// with duplicates are kept until the last contract using them is deleted.
+type ObjectCleaner struct {
+	objects ObjectStorage
+	store   ContractStore
+	minAge  time.Duration // Age before an orphaned object is deleted
+	now     func() time.Time
+}
+
+func NewObjectCleaner(objects ObjectStorage, store ContractStore, minAge time.Duration) *ObjectCleaner {
+	return &ObjectCleaner{
+		objects: objects,
+		store:   store,
+		minAge:  minAge,
+		now:     time.Now,
+	}
+}
+
+// tenantPrefixes returns the contract prefixes used by a tenant's contracts
+func (cl *ObjectCleaner) tenantPrefixes(tenant string) (map[string]bool, error) {
+	contracts, err := cl.store.GetByTenant(tenant)
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool)
+	for _, c := range contracts {
+		for _, p := range usedPrefixes(c) {
+			used[p] = true
+		}
+	}
+	return used, nil
+}
+
+// DeleteContract deletes the objects of a contract removed from the store
+// that no remaining contract of its tenant uses
+func (cl *ObjectCleaner) DeleteContract(ctx context.Context, contract *model.Contract) *CleanupReport {
+	report := &CleanupReport{Deleted: []string{}}
+	used, err := cl.tenantPrefixes(contract.Tenant)
+	if err != nil {
+		report.fail(ContractPrefix(contract.Tenant, contract.ID), err)
+		return report
+	}
+
+	for _, prefix := range usedPrefixes(contract) {
+		if used[prefix] {
+			continue
+		}
+		// Mark the prefix so one listed twice is deleted once
+		used[prefix] = true
+
+		objects, err := cl.objects.ListObjects(ctx, prefix)
+		if err != nil {
+			report.fail(prefix, err)
+			continue
+		}
+		for _, obj := range objects {
+			cl.delete(ctx, obj.Name, report)
+		}
+	}
+	return report
+}
+
+// Reconcile deletes orphaned objects, whose contract prefix no contract
+// uses. Recent objects are kept, as uploads store the file before saving
+// the contract.
+func (cl *ObjectCleaner) Reconcile(ctx context.Context) (*CleanupReport, error) {
+	objects, err := cl.objects.ListObjects(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CleanupReport{Deleted: []string{}}
+	cutoff := cl.now().Add(-cl.minAge)
+	used := make(map[string]map[string]bool) // tenant → used prefixes
+	for _, obj := range objects {
+		prefix := contractPrefixOf(obj.Name)
+		if prefix == "" || obj.LastModified.After(cutoff) {
+			continue
+		}
+		tenant := prefix[:strings.Index(prefix, "/")]
+		if used[tenant] == nil {
+			prefixes, err := cl.tenantPrefixes(tenant)
+			if err != nil {
+				return report, err
+			}
+			used[tenant] = prefixes
+		}
+		if used[tenant][prefix] {
+			continue
+		}
+		cl.delete(ctx, obj.Name, report)
+	}
+	return report, nil
+}
+
+// Run reconciles every interval until ctx is done
+func (cl *ObjectCleaner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := cl.Reconcile(ctx)
+		if err != nil {
+			slog.Error("failed to reconcile orphaned objects", "error", err)
+		}
+		if report != nil && (len(report.Deleted) > 0 || len(report.Failed) > 0) {
+			slog.Info("orphaned objects reconciled",
+				"deleted", len(report.Deleted),
+				"failed", len(report.Failed),
+			)
+		}
+	}
+}
+
+func (cl *ObjectCleaner) delete(ctx context.Context, name string, report *CleanupReport) {
+	if err := cl.objects.DeleteFile(ctx, name); err != nil {
+		slog.Warn("failed to delete object", "object", name, "error", err)
+		report.fail(name, err)
+		return
+	}
+	report.Deleted = append(report.Deleted, name)
+}
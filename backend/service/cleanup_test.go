@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/model"
+)
+
+// failingDeletes fails to delete the objects in fail
+type failingDeletes struct {
+	*memoryObjects
+	fail map[string]bool
+}
+
+func (f failingDeletes) DeleteFile(ctx context.Context, objectName string) error {
+	if f.fail[objectName] {
+		return errors.New("access denied")
+	}
+	return f.memoryObjects.DeleteFile(ctx, objectName)
+}
+
+func putObjects(t *testing.T, objects *memoryObjects, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := objects.UploadFile(context.Background(), name, strings.NewReader(name), int64(len(name)), ""); err != nil {
+			t.Fatalf("Failed to upload %s: %v", name, err)
+		}
+	}
+}
+
+func TestContractPrefixOf(t *testing.T) {
+	tests := map[string]string{
+		"t1/c1/file.pdf":                   "t1/c1/",
+		"t1/c1/revisions/2/result/full.md": "t1/c1/",
+		"t1/c1/result/":                    "t1/c1/",
+		"t1/file.pdf":                      "",
+		"/c1/file.pdf":                     "",
+	}
+	for name, want := range tests {
+		if got := contractPrefixOf(name); got != want {
+			t.Errorf("contractPrefixOf(%q) = %q, expected %q", name, got, want)
+		}
+	}
+}
+
+func TestObjectCleanerDeleteContract(t *testing.T) {
+	store := NewMemoryStore(0)
+	objects := newMemoryObjects()
+	putObjects(t, objects,
+		"t1/src/a.pdf", "t1/src/result/full.md",
+		"t1/dup/revisions/2/result/full.md",
+		"t1/other/b.pdf",
+	)
+	source := &model.Contract{ID: "src", Tenant: "t1", Filename: "a.pdf"}
+	duplicate := &model.Contract{
+		ID: "dup", Tenant: "t1", Filename: "a.pdf", DuplicateOf: "src", Revision: 2,
+		Revisions: []model.Revision{{Number: 1, ResultPrefix: "t1/src/result/"}},
+	}
+	store.Save(duplicate)
+	store.Save(&model.Contract{ID: "other", Tenant: "t1"})
+	cleaner := NewObjectCleaner(objects, store, time.Hour)
+
+	// The duplicate still uses the source's file and result
+	report := cleaner.DeleteContract(context.Background(), source)
+	if len(report.Deleted) != 0 || len(report.Failed) != 0 {
+		t.Errorf("Expected the source's objects to be kept, got %+v", report)
+	}
+
+	// Deleting the last user removes both prefixes
+	store.Delete("dup")
+	report = cleaner.DeleteContract(context.Background(), duplicate)
+	sort.Strings(report.Deleted)
+	expected := []string{"t1/dup/revisions/2/result/full.md", "t1/src/a.pdf", "t1/src/result/full.md"}
+	if !reflect.DeepEqual(report.Deleted, expected) {
+		t.Errorf("Expected %v deleted, got %v", expected, report.Deleted)
+	}
+	if names := objects.names(); !reflect.DeepEqual(names, []string{"t1/other/b.pdf"}) {
+		t.Errorf("Expected only the other contract's file to remain, got %v", names)
+	}
+}
+
+func TestObjectCleanerDeleteContractPartialFailure(t *testing.T) {
+	objects := newMemoryObjects()
+	putObjects(t, objects, "t1/c1/a.pdf", "t1/c1/result/full.md")
+	cleaner := NewObjectCleaner(failingDeletes{objects, map[string]bool{"t1/c1/a.pdf": true}}, NewMemoryStore(0), time.Hour)
+
+	report := cleaner.DeleteContract(context.Background(), &model.Contract{ID: "c1", Tenant: "t1"})
+	if !reflect.DeepEqual(report.Deleted, []string{"t1/c1/result/full.md"}) {
+		t.Errorf("Expected the result to be deleted, got %v", report.Deleted)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].Name != "t1/c1/a.pdf" || report.Failed[0].Error != "access denied" {
+		t.Errorf("Expected the file to be reported as failed, got %+v", report.Failed)
+	}
+}
+
+func TestObjectCleanerReconcile(t *testing.T) {
+	store := NewMemoryStore(0)
+	store.Save(&model.Contract{ID: "live", Tenant: "t1"})
+	store.Save(&model.Contract{ID: "dup", Tenant: "t2", DuplicateOf: "gone-source"})
+
+	objects := newTestLocalStorage(t)
+	ctx := context.Background()
+	for _, name := range []string{
+		"t1/live/a.pdf", "t1/deleted/a.pdf", "t1/deleted/result/full.md",
+		"t2/gone-source/a.pdf", "t2/orphan/b.pdf", "stray.txt",
+	} {
+		objects.UploadFile(ctx, name, strings.NewReader("x"), 1, "")
+	}
+
+	// Nothing is old enough yet
+	cleaner := NewObjectCleaner(objects, store, time.Hour)
+	report, err := cleaner.Reconcile(ctx)
+	if err != nil || len(report.Deleted) != 0 {
+		t.Fatalf("Expected recent objects to be kept, got %+v, %v", report, err)
+	}
+
+	cleaner.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	report, err = cleaner.Reconcile(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sort.Strings(report.Deleted)
+	expected := []string{"t1/deleted/a.pdf", "t1/deleted/result/full.md", "t2/orphan/b.pdf"}
+	if !reflect.DeepEqual(report.Deleted, expected) {
+		t.Errorf("Expected %v deleted, got %v", expected, report.Deleted)
+	}
+
+	remaining, _ := objects.ListObjects(ctx, "")
+	var names []string
+	for _, obj := range remaining {
+		names = append(names, obj.Name)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"stray.txt", "t1/live/a.pdf", "t2/gone-source/a.pdf"}) {
+		t.Errorf("Unexpected remaining objects %v", names)
+	}
+}
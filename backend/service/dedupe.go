@@ -0,0 +1,52 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"reflect"
+
+	"github.com/AnTengye/contractdiff/backend/model"
+)
+
+// HashingReader hashes everything read through it, so that content can be
+// hashed while it is stored
+type HashingReader struct {
+	r io.Reader
+	h hash.Hash
+}
+
+func NewHashingReader(r io.Reader) *HashingReader {
+	return &HashingReader{r: r, h: sha256.New()}
+}
+
+func (r *HashingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	return n, err
+}
+
+// Sum returns the hex encoded SHA-256 of the content read so far
+func (r *HashingReader) Sum() string {
+	return hex.EncodeToString(r.h.Sum(nil))
+}
+
+// FindReusableResult returns the tenant's newest completed contract with the
+// given content hash that has a parse result from the same parser and
+// extraction options, or nil if there is none
+func FindReusableResult(store ContractStore, tenant, hash, parser string, opts *model.ExtractOptions) (*model.Contract, error) {
+	if hash == "" {
+		return nil, nil
+	}
+	candidates, err := store.FindByHash(tenant, hash)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range candidates {
+		if c.JSONData != nil && c.Parser == parser && reflect.DeepEqual(c.Options, opts) {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/model"
+)
+
+func TestHashingReader(t *testing.T) {
+	r := NewHashingReader(strings.NewReader("abc"))
+	data, err := io.ReadAll(r)
+	if err != nil || string(data) != "abc" {
+		t.Fatalf("Expected content to pass through, got %q, %v", data, err)
+	}
+	if want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"; r.Sum() != want {
+		t.Errorf("Expected %s, got %s", want, r.Sum())
+	}
+}
+
+// testStoreFindByHash exercises FindByHash of any ContractStore; tenant
+// must not have any contracts yet
+func testStoreFindByHash(t *testing.T, store ContractStore, tenant string) {
+	t.Helper()
+
+	base := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	contracts := []*model.Contract{
+		{ID: tenant + "-old", Status: model.StatusCompleted, ContentHash: "h1", CreatedAt: base},
+		{ID: tenant + "-new", Status: model.StatusCompleted, ContentHash: "h1", DuplicateOf: tenant + "-old", SourceObject: tenant + "/" + tenant + "-old/a.pdf", CreatedAt: base.Add(time.Hour)},
+		{ID: tenant + "-failed", Status: model.StatusFailed, ContentHash: "h1", CreatedAt: base.Add(2 * time.Hour)},
+		{ID: tenant + "-other", Status: model.StatusCompleted, ContentHash: "h2", CreatedAt: base},
+	}
+	for _, c := range contracts {
+		c.Tenant = tenant
+		if err := store.Save(c); err != nil {
+			t.Fatalf("Failed to save %s: %v", c.ID, err)
+		}
+	}
+	store.Save(&model.Contract{ID: tenant + "-foreign", Tenant: tenant + "-2", Status: model.StatusCompleted, ContentHash: "h1", CreatedAt: base})
+	defer store.Delete(tenant + "-foreign")
+
+	found, err := store.FindByHash(tenant, "h1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(found) != 2 || found[0].ID != tenant+"-new" || found[1].ID != tenant+"-old" {
+		t.Fatalf("Expected completed contracts newest first, got %d", len(found))
+	}
+	if found[0].ContentHash != "h1" || found[0].DuplicateOf != tenant+"-old" || found[0].SourceObject != tenant+"/"+tenant+"-old/a.pdf" {
+		t.Errorf("Expected hash and duplicate to round-trip, got %q %q %q", found[0].ContentHash, found[0].DuplicateOf, found[0].SourceObject)
+	}
+
+	if found, _ := store.FindByHash(tenant, "h3"); len(found) != 0 {
+		t.Errorf("Expected no contracts for unknown hash, got %d", len(found))
+	}
+}
+
+func TestContractStoreFindByHash(t *testing.T) {
+	testStoreFindByHash(t, newTestStore(0), "hash-tenant")
+}
+
+func TestFindReusableResult(t *testing.T) {
+	store := newTestStore(0)
+	isOCR := true
+	pipeline := &model.ExtractOptions{ModelVersion: "pipeline", IsOCR: &isOCR}
+	store.Save(&model.Contract{
+		ID:          "no-result",
+		Tenant:      "tenant1",
+		Status:      model.StatusCompleted,
+		Parser:      ParserMineru,
+		ContentHash: "h1",
+		Options:     pipeline,
+		CreatedAt:   time.Now(),
+	})
+	store.Save(&model.Contract{
+		ID:          "parsed",
+		Tenant:      "tenant1",
+		Status:      model.StatusCompleted,
+		Parser:      ParserMineru,
+		ContentHash: "h1",
+		Options:     pipeline,
+		JSONData:    map[string]interface{}{"pdf_info": []interface{}{}},
+		CreatedAt:   time.Now().Add(-time.Hour),
+	})
+
+	sameOCR := true
+	source, err := FindReusableResult(store, "tenant1", "h1", ParserMineru, &model.ExtractOptions{ModelVersion: "pipeline", IsOCR: &sameOCR})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if source == nil || source.ID != "parsed" {
+		t.Fatalf("Expected the parsed contract, got %+v", source)
+	}
+
+	noOCR := false
+	misses := []struct {
+		name   string
+		tenant string
+		hash   string
+		parser string
+		opts   *model.ExtractOptions
+	}{
+		{"other tenant", "tenant2", "h1", ParserMineru, pipeline},
+		{"other hash", "tenant1", "h2", ParserMineru, pipeline},
+		{"no hash", "tenant1", "", ParserMineru, pipeline},
+		{"other parser", "tenant1", "h1", ParserDocx, pipeline},
+		{"other options", "tenant1", "h1", ParserMineru, &model.ExtractOptions{ModelVersion: "pipeline", IsOCR: &noOCR}},
+		{"no options", "tenant1", "h1", ParserMineru, nil},
+	}
+	for _, tt := range misses {
+		if source, _ := FindReusableResult(store, tt.tenant, tt.hash, tt.parser, tt.opts); source != nil {
+			t.Errorf("%s: expected no reusable result, got %s", tt.name, source.ID)
+		}
+	}
+}
@@ -0,0 +1,734 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/AnTengye/contractdiff/backend/config"
+)
+
+// DocxBackend is the _backend value of results produced by ExtractDocx
+const DocxBackend = "docx"
+
+const (
+	wordNS    = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	relNS     = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	mcNS      = "http://schemas.openxmlformats.org/markup-compatibility/2006"
+	twipsPerP = 20.0 // twentieths of a point
+
+	docxFontSize   = 10.5 // 五号, the default body size of Chinese templates
+	docxLineHeight = docxFontSize * 1.5
+)
+
+// ExtractDocx parses a DOCX file and returns a result shaped like MinerU's
+// middle.json (pdf_info → para_blocks → lines → spans), so it can be stored
+// and compared exactly like a MinerU result. DOCX has no fixed layout; pages
+// follow explicit page and section breaks, and otherwise an estimate of how
+// much text fits on a page. Bounding boxes are estimates on the same basis.
+// The file is bounded by limits like a result ZIP, as DOCX is a ZIP too.
+func ExtractDocx(r io.ReaderAt, size int64, limits config.ResultLimits) (map[string]interface{}, error) {
+	z, err := OpenResultZip(r, size, limits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DOCX: %w", err)
+	}
+
+	files := make(map[string]*zip.File, len(z.reader.File))
+	for _, f := range z.reader.File {
+		files[f.Name] = f
+	}
+
+	// The parts read count against the uncompressed size limit together
+	remaining := z.limits.MaxUncompressedBytes
+	withZipFile := func(f *zip.File, fn func(io.Reader) error) error {
+		rc, err := z.open(f, &remaining)
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		return fn(rc)
+	}
+
+	docFile := files["word/document.xml"]
+	if docFile == nil {
+		return nil, fmt.Errorf("invalid DOCX: word/document.xml not found")
+	}
+
+	p := &docxParser{
+		styles:    map[string]*docxStyle{},
+		numbering: newDocxNumbering(),
+	}
+	if f := files["word/styles.xml"]; f != nil {
+		if err := withZipFile(f, p.parseStyles); err != nil {
+			return nil, fmt.Errorf("failed to parse styles.xml: %w", err)
+		}
+	}
+	if f := files["word/numbering.xml"]; f != nil {
+		if err := withZipFile(f, p.numbering.parse); err != nil {
+			return nil, fmt.Errorf("failed to parse numbering.xml: %w", err)
+		}
+	}
+
+	var body docxPart
+	if err := withZipFile(docFile, func(r io.Reader) error {
+		var err error
+		body, err = p.parsePart(r)
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to parse document.xml: %w", err)
+	}
+
+	// Headers and footers are referenced from the final section properties
+	rels := map[string]string{}
+	if f := files["word/_rels/document.xml.rels"]; f != nil {
+		if err := withZipFile(f, func(r io.Reader) error {
+			var err error
+			rels, err = parseRelationships(r)
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("failed to parse document relationships: %w", err)
+		}
+	}
+	decorations := map[string][]docxBlock{}
+	for key, relID := range body.section.references {
+		target, ok := rels[relID]
+		if !ok {
+			continue
+		}
+		f := files[path.Join("word", target)]
+		if f == nil {
+			continue
+		}
+		var part docxPart
+		if err := withZipFile(f, func(r io.Reader) error {
+			var err error
+			part, err = p.parsePart(r)
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", f.Name, err)
+		}
+		decorations[key] = part.blocks
+	}
+
+	return layoutDocx(body, decorations), nil
+}
+
+// docxBlock is a paragraph or table in document order
+type docxBlock struct {
+	kind        string // text, title, table
+	text        string
+	rows        []string // table rows, cells separated by tabs
+	breakBefore bool
+	breakAfter  bool
+}
+
+// docxSection holds the page setup of the final section
+type docxSection struct {
+	width, height                                    float64 // points
+	marginTop, marginBottom, marginLeft, marginRight float64
+	titlePage                                        bool
+	references                                       map[string]string // header:default → relationship ID
+}
+
+type docxPart struct {
+	blocks  []docxBlock
+	section docxSection
+}
+
+type docxStyle struct {
+	name       string
+	basedOn    string
+	outlineLvl int // -1 if none
+	numID      string
+	ilvl       int
+}
+
+type docxParser struct {
+	styles    map[string]*docxStyle
+	numbering *docxNumbering
+}
+
+func attr(se xml.StartElement, space, local string) (string, bool) {
+	for _, a := range se.Attr {
+		if a.Name.Local == local && (space == "" || a.Name.Space == space) {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func wordAttr(se xml.StartElement, local string) string {
+	v, _ := attr(se, wordNS, local)
+	return v
+}
+
+// onOff reads a boolean property such as <w:pageBreakBefore/> or <w:titlePg w:val="0"/>
+func onOff(se xml.StartElement) bool {
+	v, ok := attr(se, wordNS, "val")
+	return !ok || (v != "0" && v != "false" && v != "off")
+}
+
+func twipsAttr(se xml.StartElement, local string) (float64, bool) {
+	v, ok := attr(se, wordNS, local)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n / twipsPerP, true
+}
+
+func (p *docxParser) parseStyles(r io.Reader) error {
+	dec := xml.NewDecoder(r)
+	var cur *docxStyle
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != wordNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "style":
+				cur = &docxStyle{outlineLvl: -1}
+				p.styles[wordAttr(t, "styleId")] = cur
+			case "name":
+				if cur != nil {
+					cur.name = strings.ToLower(wordAttr(t, "val"))
+				}
+			case "basedOn":
+				if cur != nil {
+					cur.basedOn = wordAttr(t, "val")
+				}
+			case "outlineLvl":
+				if cur != nil {
+					cur.outlineLvl, _ = strconv.Atoi(wordAttr(t, "val"))
+				}
+			case "numId":
+				if cur != nil {
+					cur.numID = wordAttr(t, "val")
+				}
+			case "ilvl":
+				if cur != nil {
+					cur.ilvl, _ = strconv.Atoi(wordAttr(t, "val"))
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Space == wordNS && t.Name.Local == "style" {
+				cur = nil
+			}
+		}
+	}
+}
+
+// resolveStyle follows the basedOn chain and reports whether the style is a
+// heading and which numbering it inherits
+func (p *docxParser) resolveStyle(styleID string) (heading bool, numID string, ilvl int) {
+	for depth := 0; styleID != "" && depth < 10; depth++ {
+		s, ok := p.styles[styleID]
+		if !ok {
+			break
+		}
+		if strings.HasPrefix(s.name, "heading") || s.name == "title" || (s.outlineLvl >= 0 && s.outlineLvl < 9) {
+			heading = true
+		}
+		if numID == "" && s.numID != "" {
+			numID, ilvl = s.numID, s.ilvl
+		}
+		styleID = s.basedOn
+	}
+	return heading, numID, ilvl
+}
+
+// docxParagraph accumulates a paragraph while its XML is being read
+type docxParagraph struct {
+	text        strings.Builder
+	style       string
+	numID       string
+	ilvl        int
+	hasNumPr    bool
+	outlineLvl  int
+	breakBefore bool
+	breakAfter  bool
+}
+
+// docxTable accumulates a table; nested tables are flattened into their cell
+type docxTable struct {
+	rows []string
+	row  []string
+	cell strings.Builder
+}
+
+// parsePart reads the paragraphs and tables of document.xml or a header/footer
+func (p *docxParser) parsePart(r io.Reader) (docxPart, error) {
+	part := docxPart{
+		section: docxSection{
+			// A4 with the default margins of Word's Chinese template
+			width: 595.3, height: 841.9,
+			marginTop: 72, marginBottom: 72, marginLeft: 90, marginRight: 90,
+			references: map[string]string{},
+		},
+	}
+
+	dec := xml.NewDecoder(r)
+	var (
+		para      *docxParagraph
+		nested    int // depth of paragraphs nested in para (text boxes)
+		tables    []*docxTable
+		skip      int // depth inside an ignored subtree
+		inPPr     bool
+		inText    bool
+		inSectPr  bool
+		bodyLevel = true
+	)
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return part, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			if t.Name.Space == mcNS && t.Name.Local == "Fallback" {
+				// The fallback duplicates the preferred mc:Choice content
+				skip = 1
+				continue
+			}
+			if t.Name.Space != wordNS {
+				continue
+			}
+
+			switch t.Name.Local {
+			case "del", "delText", "instrText", "rPr", "footnoteReference", "endnoteReference":
+				skip = 1
+			case "p":
+				if para != nil {
+					nested++
+				} else {
+					para = &docxParagraph{outlineLvl: -1}
+				}
+			case "pPr":
+				inPPr = para != nil && nested == 0
+			case "pStyle":
+				if inPPr {
+					para.style = wordAttr(t, "val")
+				}
+			case "ilvl":
+				if inPPr {
+					para.ilvl, _ = strconv.Atoi(wordAttr(t, "val"))
+					para.hasNumPr = true
+				}
+			case "numId":
+				if inPPr {
+					para.numID = wordAttr(t, "val")
+					para.hasNumPr = true
+				}
+			case "outlineLvl":
+				if inPPr {
+					para.outlineLvl, _ = strconv.Atoi(wordAttr(t, "val"))
+				}
+			case "pageBreakBefore":
+				if inPPr && onOff(t) {
+					para.breakBefore = true
+				}
+			case "sectPr":
+				if inPPr {
+					// A section break inside a paragraph starts a new page
+					para.breakAfter = true
+					bodyLevel = false
+				}
+				inSectPr = true
+			case "pgSz":
+				if inSectPr && bodyLevel {
+					if w, ok := twipsAttr(t, "w"); ok {
+						part.section.width = w
+					}
+					if h, ok := twipsAttr(t, "h"); ok {
+						part.section.height = h
+					}
+				}
+			case "pgMar":
+				if inSectPr && bodyLevel {
+					if v, ok := twipsAttr(t, "top"); ok {
+						part.section.marginTop = math.Abs(v)
+					}
+					if v, ok := twipsAttr(t, "bottom"); ok {
+						part.section.marginBottom = math.Abs(v)
+					}
+					if v, ok := twipsAttr(t, "left"); ok {
+						part.section.marginLeft = v
+					}
+					if v, ok := twipsAttr(t, "right"); ok {
+						part.section.marginRight = v
+					}
+				}
+			case "titlePg":
+				if inSectPr && bodyLevel {
+					part.section.titlePage = onOff(t)
+				}
+			case "headerReference", "footerReference":
+				if inSectPr && bodyLevel {
+					kind := strings.TrimSuffix(t.Name.Local, "Reference")
+					refType := wordAttr(t, "type")
+					if refType == "" {
+						refType = "default"
+					}
+					if id, ok := attr(t, relNS, "id"); ok {
+						part.section.references[kind+":"+refType] = id
+					}
+				}
+			case "t":
+				inText = para != nil
+			case "tab":
+				if para != nil && !inPPr {
+					para.text.WriteByte('\t')
+				}
+			case "br":
+				if para != nil {
+					if wordAttr(t, "type") == "page" {
+						para.breakAfter = true
+					} else {
+						para.text.WriteByte('\n')
+					}
+				}
+			case "cr":
+				if para != nil {
+					para.text.WriteByte('\n')
+				}
+			case "noBreakHyphen":
+				if para != nil {
+					para.text.WriteByte('-')
+				}
+			case "tbl":
+				tables = append(tables, &docxTable{})
+			case "tr":
+				if len(tables) > 0 {
+					tables[len(tables)-1].row = nil
+				}
+			case "tc":
+				if len(tables) > 0 {
+					tables[len(tables)-1].cell.Reset()
+				}
+			}
+
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if t.Name.Space != wordNS {
+				continue
+			}
+
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "pPr":
+				inPPr = false
+			case "sectPr":
+				inSectPr = false
+				bodyLevel = true
+			case "p":
+				if nested > 0 {
+					nested--
+					para.text.WriteByte('\n')
+					continue
+				}
+				if para == nil {
+					continue
+				}
+				block := p.finishParagraph(para)
+				para = nil
+				if len(tables) > 0 {
+					tbl := tables[len(tables)-1]
+					if block.text != "" {
+						if tbl.cell.Len() > 0 {
+							tbl.cell.WriteByte('\n')
+						}
+						tbl.cell.WriteString(block.text)
+					}
+					continue
+				}
+				part.blocks = append(part.blocks, block)
+			case "tc":
+				if len(tables) > 0 {
+					tbl := tables[len(tables)-1]
+					tbl.row = append(tbl.row, strings.TrimSpace(tbl.cell.String()))
+				}
+			case "tr":
+				if len(tables) > 0 {
+					tbl := tables[len(tables)-1]
+					if rowText := strings.Join(tbl.row, "\t"); strings.TrimSpace(rowText) != "" {
+						tbl.rows = append(tbl.rows, rowText)
+					}
+				}
+			case "tbl":
+				if len(tables) == 0 {
+					continue
+				}
+				tbl := tables[len(tables)-1]
+				tables = tables[:len(tables)-1]
+				if len(tables) > 0 {
+					parent := tables[len(tables)-1]
+					for _, row := range tbl.rows {
+						if parent.cell.Len() > 0 {
+							parent.cell.WriteByte('\n')
+						}
+						parent.cell.WriteString(row)
+					}
+					continue
+				}
+				if len(tbl.rows) > 0 {
+					part.blocks = append(part.blocks, docxBlock{kind: "table", rows: tbl.rows})
+				}
+			}
+
+		case xml.CharData:
+			if inText && skip == 0 && para != nil {
+				para.text.Write(t)
+			}
+		}
+	}
+
+	return part, nil
+}
+
+// finishParagraph resolves style and numbering and returns the paragraph block
+func (p *docxParser) finishParagraph(para *docxParagraph) docxBlock {
+	heading, numID, ilvl := p.resolveStyle(para.style)
+	if para.outlineLvl >= 0 && para.outlineLvl < 9 {
+		heading = true
+	}
+	if para.hasNumPr {
+		numID, ilvl = para.numID, para.ilvl
+	}
+
+	text := strings.TrimSpace(para.text.String())
+	if text != "" && numID != "" {
+		if label := p.numbering.next(numID, ilvl); label != "" {
+			text = label + " " + text
+		}
+	}
+
+	kind := "text"
+	if heading {
+		kind = "title"
+	}
+	return docxBlock{
+		kind:        kind,
+		text:        text,
+		breakBefore: para.breakBefore,
+		breakAfter:  para.breakAfter,
+	}
+}
+
+func parseRelationships(r io.Reader) (map[string]string, error) {
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := xml.NewDecoder(r).Decode(&rels); err != nil {
+		return nil, err
+	}
+	result := make(map[string]string, len(rels.Relationships))
+	for _, rel := range rels.Relationships {
+		result[rel.ID] = rel.Target
+	}
+	return result, nil
+}
+
+// layoutDocx flows blocks onto pages and builds the middle.json structure
+func layoutDocx(body docxPart, decorations map[string][]docxBlock) map[string]interface{} {
+	sec := body.section
+	contentWidth := sec.width - sec.marginLeft - sec.marginRight
+	bottom := sec.height - sec.marginBottom
+	charsPerLine := math.Max(1, math.Floor(contentWidth/docxFontSize))
+
+	var pages []interface{}
+	var blocks []interface{}
+	y := sec.marginTop
+	index := 0
+
+	newPage := func() {
+		pages = append(pages, docxPage(len(pages), sec, blocks, decorations, charsPerLine))
+		blocks = nil
+		y = sec.marginTop
+	}
+
+	place := func(texts []string) []float64 {
+		lines := 0
+		for _, t := range texts {
+			lines += estimateLines(t, charsPerLine)
+		}
+		height := float64(lines) * docxLineHeight
+		if y+height > bottom && len(blocks) > 0 {
+			newPage()
+		}
+		bbox := []float64{sec.marginLeft, y, sec.marginLeft + contentWidth, y + height}
+		y += height
+		return roundBBox(bbox)
+	}
+
+	for _, b := range body.blocks {
+		if b.breakBefore && len(blocks) > 0 {
+			newPage()
+		}
+
+		switch {
+		case b.kind == "table":
+			var rows []interface{}
+			for _, row := range b.rows {
+				bbox := place([]string{row})
+				rows = append(rows, map[string]interface{}{
+					"type":  "table_body",
+					"bbox":  bbox,
+					"lines": []interface{}{docxLine(row, bbox)},
+				})
+			}
+			blocks = append(blocks, map[string]interface{}{
+				"type":   "table",
+				"bbox":   spanBBox(rows),
+				"blocks": rows,
+				"index":  index,
+			})
+			index++
+		case b.text != "":
+			bbox := place([]string{b.text})
+			blocks = append(blocks, map[string]interface{}{
+				"type":  b.kind,
+				"bbox":  bbox,
+				"lines": []interface{}{docxLine(b.text, bbox)},
+				"index": index,
+			})
+			index++
+		}
+
+		if b.breakAfter {
+			newPage()
+		}
+	}
+	if len(blocks) > 0 || len(pages) == 0 {
+		newPage()
+	}
+
+	return map[string]interface{}{
+		"pdf_info": pages,
+		"_backend": DocxBackend,
+	}
+}
+
+func docxPage(idx int, sec docxSection, blocks []interface{}, decorations map[string][]docxBlock, charsPerLine float64) map[string]interface{} {
+	if blocks == nil {
+		blocks = []interface{}{}
+	}
+
+	// Headers and footers are reported like MinerU does, as discarded blocks
+	discarded := []interface{}{}
+	for _, kind := range []string{"header", "footer"} {
+		parts, ok := decorations[kind+":first"]
+		if !ok || idx != 0 || !sec.titlePage {
+			parts = decorations[kind+":default"]
+		}
+		y := sec.marginTop / 2
+		if kind == "footer" {
+			y = sec.height - sec.marginBottom/2 - docxLineHeight
+		}
+		for _, b := range parts {
+			if b.text == "" {
+				continue
+			}
+			height := float64(estimateLines(b.text, charsPerLine)) * docxLineHeight
+			bbox := roundBBox([]float64{sec.marginLeft, y, sec.width - sec.marginRight, y + height})
+			discarded = append(discarded, map[string]interface{}{
+				"type":  kind,
+				"bbox":  bbox,
+				"lines": []interface{}{docxLine(b.text, bbox)},
+			})
+		}
+	}
+
+	return map[string]interface{}{
+		"page_idx":         idx,
+		"page_size":        []float64{math.Round(sec.width), math.Round(sec.height)},
+		"para_blocks":      blocks,
+		"discarded_blocks": discarded,
+	}
+}
+
+func docxLine(text string, bbox []float64) map[string]interface{} {
+	return map[string]interface{}{
+		"bbox": bbox,
+		"spans": []interface{}{
+			map[string]interface{}{
+				"type":    "text",
+				"content": text,
+				"bbox":    bbox,
+			},
+		},
+	}
+}
+
+// estimateLines estimates how many lines text occupies, counting CJK and
+// other wide runes as one character width and ASCII as half
+func estimateLines(text string, charsPerLine float64) int {
+	lines := 0
+	for _, line := range strings.Split(text, "\n") {
+		width := 0.0
+		for _, r := range line {
+			if r < utf8.RuneSelf {
+				width += 0.5
+			} else {
+				width++
+			}
+		}
+		lines += max(1, int(math.Ceil(width/charsPerLine)))
+	}
+	return lines
+}
+
+func spanBBox(blocks []interface{}) []float64 {
+	var bbox []float64
+	for _, b := range blocks {
+		bb := b.(map[string]interface{})["bbox"].([]float64)
+		if bbox == nil {
+			bbox = append([]float64(nil), bb...)
+			continue
+		}
+		bbox[0] = math.Min(bbox[0], bb[0])
+		bbox[1] = math.Min(bbox[1], bb[1])
+		bbox[2] = math.Max(bbox[2], bb[2])
+		bbox[3] = math.Max(bbox[3], bb[3])
+	}
+	return bbox
+}
+
+func roundBBox(bbox []float64) []float64 {
+	for i, v := range bbox {
+		bbox[i] = math.Round(v*10) / 10
+	}
+	return bbox
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const docxMaxLevels = 9
+
+type docxLevel struct {
+	start   int
+	numFmt  string
+	lvlText string
+	isLgl   bool // render all placeholders as decimal, e.g. 1.1 under 第一条
+}
+
+type docxNum struct {
+	abstractID string
+	overrides  map[int]int // ilvl → startOverride
+}
+
+// docxListState holds the counters of one abstract list definition. Word
+// continues counting across w:num instances sharing an abstract definition
+// unless an instance restarts a level with w:startOverride.
+type docxListState struct {
+	values [docxMaxLevels]int
+	set    [docxMaxLevels]bool
+}
+
+// docxNumbering turns numbering.xml definitions into the labels Word renders
+// in front of list paragraphs, e.g. "第一条", "1.1" or "(a)"
+type docxNumbering struct {
+	abstracts map[string]map[int]*docxLevel
+	nums      map[string]*docxNum
+	states    map[string]*docxListState
+	seen      map[string]bool
+}
+
+func newDocxNumbering() *docxNumbering {
+	return &docxNumbering{
+		abstracts: map[string]map[int]*docxLevel{},
+		nums:      map[string]*docxNum{},
+		states:    map[string]*docxListState{},
+		seen:      map[string]bool{},
+	}
+}
+
+func (n *docxNumbering) parse(r io.Reader) error {
+	dec := xml.NewDecoder(r)
+	var (
+		levels   map[int]*docxLevel // current abstractNum
+		level    *docxLevel         // current lvl in abstractNum
+		num      *docxNum           // current num
+		override = -1               // ilvl of the current lvlOverride
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != wordNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "abstractNum":
+				levels = map[int]*docxLevel{}
+				n.abstracts[wordAttr(t, "abstractNumId")] = levels
+			case "num":
+				num = &docxNum{overrides: map[int]int{}}
+				n.nums[wordAttr(t, "numId")] = num
+			case "abstractNumId":
+				if num != nil {
+					num.abstractID = wordAttr(t, "val")
+				}
+			case "lvlOverride":
+				override, _ = strconv.Atoi(wordAttr(t, "ilvl"))
+			case "startOverride":
+				if num != nil && override >= 0 {
+					num.overrides[override], _ = strconv.Atoi(wordAttr(t, "val"))
+				}
+			case "lvl":
+				// Levels redefined inside lvlOverride are rare and ignored
+				if levels != nil && num == nil {
+					ilvl, _ := strconv.Atoi(wordAttr(t, "ilvl"))
+					level = &docxLevel{start: 1, numFmt: "decimal"}
+					levels[ilvl] = level
+				}
+			case "start":
+				if level != nil {
+					level.start, _ = strconv.Atoi(wordAttr(t, "val"))
+				}
+			case "numFmt":
+				if level != nil {
+					level.numFmt = wordAttr(t, "val")
+				}
+			case "lvlText":
+				if level != nil {
+					level.lvlText = wordAttr(t, "val")
+				}
+			case "isLgl":
+				if level != nil {
+					level.isLgl = onOff(t)
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Space != wordNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "abstractNum":
+				levels = nil
+			case "num":
+				num = nil
+			case "lvlOverride":
+				override = -1
+			case "lvl":
+				level = nil
+			}
+		}
+	}
+}
+
+// next advances the counter of numID at ilvl and returns the rendered label,
+// or "" for bullets and unknown lists
+func (n *docxNumbering) next(numID string, ilvl int) string {
+	num, ok := n.nums[numID]
+	if !ok || ilvl < 0 || ilvl >= docxMaxLevels {
+		return ""
+	}
+	levels := n.abstracts[num.abstractID]
+	lvl := levels[ilvl]
+	if lvl == nil || lvl.numFmt == "bullet" || lvl.numFmt == "none" {
+		return ""
+	}
+
+	state := n.states[num.abstractID]
+	if state == nil {
+		state = &docxListState{}
+		n.states[num.abstractID] = state
+	}
+	if !n.seen[numID] {
+		n.seen[numID] = true
+		for l, start := range num.overrides {
+			if l >= 0 && l < docxMaxLevels {
+				state.values[l] = start - 1
+				state.set[l] = true
+			}
+		}
+	}
+
+	if state.set[ilvl] {
+		state.values[ilvl]++
+	} else {
+		state.values[ilvl] = lvl.start
+		state.set[ilvl] = true
+	}
+	for l := ilvl + 1; l < docxMaxLevels; l++ {
+		state.set[l] = false
+	}
+
+	label := lvl.lvlText
+	for l := 0; l <= ilvl; l++ {
+		placeholder := "%" + strconv.Itoa(l+1)
+		if !strings.Contains(label, placeholder) {
+			continue
+		}
+		value, fmtName := 1, "decimal"
+		if ref := levels[l]; ref != nil {
+			value, fmtName = ref.start, ref.numFmt
+		}
+		if state.set[l] {
+			value = state.values[l]
+		}
+		if lvl.isLgl {
+			fmtName = "decimal"
+		}
+		label = strings.ReplaceAll(label, placeholder, formatDocxNumber(value, fmtName))
+	}
+	return strings.TrimSpace(label)
+}
+
+var (
+	chineseDigits      = []rune("零一二三四五六七八九")
+	chineseLegalDigits = []rune("零壹贰叁肆伍陆柒捌玖")
+	heavenlyStems      = []rune("甲乙丙丁戊己庚辛壬癸")
+)
+
+// formatDocxNumber renders n in a w:numFmt format
+func formatDocxNumber(n int, format string) string {
+	switch format {
+	case "lowerLetter":
+		return letterNumber(n, 'a')
+	case "upperLetter":
+		return letterNumber(n, 'A')
+	case "lowerRoman":
+		return strings.ToLower(romanNumber(n))
+	case "upperRoman":
+		return romanNumber(n)
+	case "chineseCounting", "chineseCountingThousand", "japaneseCounting", "taiwaneseCounting", "taiwaneseCountingThousand":
+		return chineseNumber(n, chineseDigits, []string{"十", "百", "千"})
+	case "chineseLegalSimplified":
+		return chineseNumber(n, chineseLegalDigits, []string{"拾", "佰", "仟"})
+	case "ideographTraditional":
+		if n >= 1 && n <= len(heavenlyStems) {
+			return string(heavenlyStems[n-1])
+		}
+	case "decimalEnclosedCircle", "decimalEnclosedCircleChinese":
+		if n >= 1 && n <= 20 {
+			return string(rune('①' + n - 1))
+		}
+	case "decimalFullWidth", "decimalFullWidth2":
+		var b strings.Builder
+		for _, r := range strconv.Itoa(n) {
+			b.WriteRune(r - '0' + '０')
+		}
+		return b.String()
+	case "decimalZero":
+		if n >= 0 && n < 10 {
+			return "0" + strconv.Itoa(n)
+		}
+	}
+	return strconv.Itoa(n)
+}
+
+// letterNumber renders 1 → a, 26 → z, 27 → aa as Word does
+func letterNumber(n int, base rune) string {
+	if n < 1 {
+		return strconv.Itoa(n)
+	}
+	letter := string(base + rune((n-1)%26))
+	return strings.Repeat(letter, (n-1)/26+1)
+}
+
+func romanNumber(n int) string {
+	if n < 1 || n >= 4000 {
+		return strconv.Itoa(n)
+	}
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"M", "CM", "D", "CD", "C", "XC", "L", "XL", "X", "IX", "V", "IV", "I"}
+	var b strings.Builder
+	for i, v := range values {
+		for n >= v {
+			b.WriteString(symbols[i])
+			n -= v
+		}
+	}
+	return b.String()
+}
+
+// chineseNumber renders 0 <= n < 100000 in Chinese numerals using digits
+// (零..九) and units (十, 百, 千), e.g. 12 → 十二, 105 → 一百零五
+func chineseNumber(n int, digits []rune, units []string) string {
+	if n < 0 || n >= 100000 {
+		return strconv.Itoa(n)
+	}
+	if n == 0 {
+		return string(digits[0])
+	}
+
+	var parts []string
+	if n >= 10000 {
+		parts = append(parts, chineseNumber(n/10000, digits, units)+"万")
+		n %= 10000
+		if n == 0 {
+			return strings.Join(parts, "")
+		}
+	}
+
+	scales := []int{1000, 100, 10, 1}
+	zero := false
+	started := len(parts) > 0
+	for i, scale := range scales {
+		d := n / scale
+		n %= scale
+		if d == 0 {
+			zero = started
+			continue
+		}
+		if zero && started {
+			parts = append(parts, string(digits[0]))
+		}
+		zero = false
+		// 10-19 reads 十, 十一 rather than 一十, 一十一
+		if !(scale == 10 && d == 1 && !started) {
+			parts = append(parts, string(digits[d]))
+		}
+		if i < 3 {
+			parts = append(parts, units[2-i])
+		}
+		started = true
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/config"
+)
+
+// DocxParser parses DOCX files in-process with ExtractDocx. It finishes
+// during Submit, so Poll and Fetch are never needed.
+type DocxParser struct {
+	httpClient *http.Client
+	limits     config.ResultLimits // Bound downloaded and decompressed sizes
+}
+
+func NewDocxParser(limits config.ResultLimits) *DocxParser {
+	return &DocxParser{
+		httpClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+		limits: resultLimits(limits),
+	}
+}
+
+func (p *DocxParser) Name() string {
+	return ParserDocx
+}
+
+// Submit downloads the document from job.FileURL and parses it
+func (p *DocxParser) Submit(ctx context.Context, job *ParseJob) (*ParseTask, error) {
+	file, size, err := p.download(ctx, job.FileURL)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		file.Close()
+		os.Remove(file.Name())
+	}()
+
+	result, err := ExtractDocx(file, size, p.limits)
+	if err != nil {
+		return nil, err
+	}
+	return &ParseTask{Result: result}, nil
+}
+
+func (p *DocxParser) Poll(ctx context.Context, taskID string) (*ParseStatus, error) {
+	return nil, ErrNoPolling
+}
+
+func (p *DocxParser) Fetch(ctx context.Context, status *ParseStatus) (*ParseResult, error) {
+	return nil, ErrNoPolling
+}
+
+// download streams a DOCX into a temporary file of at most the download
+// size limit. The caller removes the file.
+func (p *DocxParser) download(ctx context.Context, url string) (*os.File, int64, error) {
+	if url == "" {
+		return nil, 0, fmt.Errorf("no DOCX URL provided")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to download DOCX: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("failed to download DOCX: HTTP %d", resp.StatusCode)
+	}
+	f, n, err := downloadToTemp(resp.Body, p.limits.MaxDownloadBytes)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read DOCX: %w", err)
+	}
+	return f, n, nil
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/AnTengye/contractdiff/backend/config"
+)
+
+const testWordHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`
+
+// buildTestDocx zips files into an in-memory DOCX
+func buildTestDocx(t *testing.T, files map[string]string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func extractTestDocx(t *testing.T, files map[string]string) []map[string]interface{} {
+	t.Helper()
+	r := buildTestDocx(t, files)
+	result, err := ExtractDocx(r, r.Size(), config.ResultLimits{})
+	if err != nil {
+		t.Fatalf("ExtractDocx failed: %v", err)
+	}
+	if result["_backend"] != DocxBackend {
+		t.Errorf("Expected _backend %q, got %v", DocxBackend, result["_backend"])
+	}
+	var pages []map[string]interface{}
+	for _, p := range result["pdf_info"].([]interface{}) {
+		pages = append(pages, p.(map[string]interface{}))
+	}
+	return pages
+}
+
+// blockText returns the text of every line of a para block
+func blockText(block interface{}) string {
+	var text string
+	for _, line := range block.(map[string]interface{})["lines"].([]interface{}) {
+		for _, span := range line.(map[string]interface{})["spans"].([]interface{}) {
+			text += span.(map[string]interface{})["content"].(string)
+		}
+	}
+	return text
+}
+
+func TestExtractDocxParagraphsAndNumbering(t *testing.T) {
+	numbering := `<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+		<w:abstractNum w:abstractNumId="0">
+			<w:lvl w:ilvl="0"><w:start w:val="1"/><w:numFmt w:val="chineseCounting"/><w:lvlText w:val="第%1条"/></w:lvl>
+			<w:lvl w:ilvl="1"><w:start w:val="1"/><w:numFmt w:val="decimal"/><w:lvlText w:val="%1.%2"/><w:isLgl/></w:lvl>
+		</w:abstractNum>
+		<w:num w:numId="1"><w:abstractNumId w:val="0"/></w:num>
+	</w:numbering>`
+	styles := `<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+		<w:style w:type="paragraph" w:styleId="1"><w:name w:val="heading 1"/></w:style>
+	</w:styles>`
+	document := testWordHeader + `<w:body>
+		<w:p><w:pPr><w:pStyle w:val="1"/></w:pPr><w:r><w:t>采购合同</w:t></w:r></w:p>
+		<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>合同标的</w:t></w:r></w:p>
+		<w:p><w:pPr><w:numPr><w:ilvl w:val="1"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t xml:space="preserve">甲方</w:t></w:r><w:r><w:t>向乙方采购设备。</w:t></w:r></w:p>
+		<w:p><w:pPr><w:numPr><w:ilvl w:val="1"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>交付地点</w:t></w:r><w:del><w:r><w:delText>旧地址</w:delText></w:r></w:del></w:p>
+		<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>付款方式</w:t></w:r></w:p>
+		<w:p><w:pPr><w:numPr><w:ilvl w:val="1"/><w:numId w:val="1"/></w:numPr></w:pPr><w:hyperlink><w:r><w:t>见附件</w:t></w:r></w:hyperlink></w:p>
+		<w:p></w:p>
+		<w:sectPr><w:pgSz w:w="11906" w:h="16838"/></w:sectPr>
+	</w:body></w:document>`
+
+	pages := extractTestDocx(t, map[string]string{
+		"word/document.xml":  document,
+		"word/numbering.xml": numbering,
+		"word/styles.xml":    styles,
+	})
+	if len(pages) != 1 {
+		t.Fatalf("Expected 1 page, got %d", len(pages))
+	}
+
+	blocks := pages[0]["para_blocks"].([]interface{})
+	expected := []string{"采购合同", "第一条 合同标的", "1.1 甲方向乙方采购设备。", "1.2 交付地点", "第二条 付款方式", "2.1 见附件"}
+	if len(blocks) != len(expected) {
+		t.Fatalf("Expected %d blocks, got %d", len(expected), len(blocks))
+	}
+	for i, want := range expected {
+		if got := blockText(blocks[i]); got != want {
+			t.Errorf("Block %d: expected %q, got %q", i, want, got)
+		}
+	}
+	if typ := blocks[0].(map[string]interface{})["type"]; typ != "title" {
+		t.Errorf("Expected heading style to produce a title block, got %v", typ)
+	}
+
+	size := pages[0]["page_size"].([]float64)
+	if size[0] != 595 || size[1] != 842 {
+		t.Errorf("Expected A4 page size, got %v", size)
+	}
+}
+
+func TestExtractDocxTablesAndPageBreaks(t *testing.T) {
+	document := testWordHeader + `<w:body>
+		<w:p><w:r><w:t>第一页</w:t></w:r><w:r><w:br w:type="page"/></w:r></w:p>
+		<w:tbl>
+			<w:tr><w:tc><w:p><w:r><w:t>名称</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>数量</w:t></w:r></w:p></w:tc></w:tr>
+			<w:tr><w:tc><w:p><w:r><w:t>服务器</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>10</w:t></w:r></w:p></w:tc></w:tr>
+		</w:tbl>
+		<w:p><w:pPr><w:pageBreakBefore/></w:pPr><w:r><w:t>第三页</w:t></w:r></w:p>
+	</w:body></w:document>`
+
+	pages := extractTestDocx(t, map[string]string{"word/document.xml": document})
+	if len(pages) != 3 {
+		t.Fatalf("Expected 3 pages, got %d", len(pages))
+	}
+	for i, page := range pages {
+		if idx := page["page_idx"]; idx != i {
+			t.Errorf("Expected page_idx %d, got %v", i, idx)
+		}
+	}
+
+	table := pages[1]["para_blocks"].([]interface{})[0].(map[string]interface{})
+	if table["type"] != "table" {
+		t.Fatalf("Expected table block, got %v", table["type"])
+	}
+	rows := table["blocks"].([]interface{})
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 table rows, got %d", len(rows))
+	}
+	if got := blockText(rows[1]); got != "服务器\t10" {
+		t.Errorf("Expected row text %q, got %q", "服务器\t10", got)
+	}
+	if got := blockText(pages[2]["para_blocks"].([]interface{})[0]); got != "第三页" {
+		t.Errorf("Expected %q on the third page, got %q", "第三页", got)
+	}
+}
+
+func TestExtractDocxHeadersAndFooters(t *testing.T) {
+	document := testWordHeader + `<w:body>
+		<w:p><w:r><w:t>正文</w:t></w:r></w:p>
+		<w:sectPr>
+			<w:headerReference w:type="default" r:id="rId1"/>
+			<w:footerReference w:type="default" r:id="rId2"/>
+		</w:sectPr>
+	</w:body></w:document>`
+	rels := `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+		<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/header" Target="header1.xml"/>
+		<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/footer" Target="footer1.xml"/>
+	</Relationships>`
+	header := `<w:hdr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:p><w:r><w:t>保密文件</w:t></w:r></w:p></w:hdr>`
+	footer := `<w:ftr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:p><w:r><w:t>第 1 页</w:t></w:r></w:p></w:ftr>`
+
+	pages := extractTestDocx(t, map[string]string{
+		"word/document.xml":            document,
+		"word/_rels/document.xml.rels": rels,
+		"word/header1.xml":             header,
+		"word/footer1.xml":             footer,
+	})
+
+	blocks := pages[0]["para_blocks"].([]interface{})
+	if len(blocks) != 1 || blockText(blocks[0]) != "正文" {
+		t.Errorf("Expected headers and footers to stay out of para_blocks, got %v", blocks)
+	}
+
+	discarded := pages[0]["discarded_blocks"].([]interface{})
+	if len(discarded) != 2 {
+		t.Fatalf("Expected 2 discarded blocks, got %d", len(discarded))
+	}
+	if got := blockText(discarded[0]); got != "保密文件" {
+		t.Errorf("Expected header %q, got %q", "保密文件", got)
+	}
+	if typ := discarded[1].(map[string]interface{})["type"]; typ != "footer" {
+		t.Errorf("Expected footer block, got %v", typ)
+	}
+}
+
+func TestExtractDocxInvalid(t *testing.T) {
+	if _, err := ExtractDocx(bytes.NewReader([]byte("not a zip")), 9, config.ResultLimits{}); err == nil {
+		t.Error("Expected error for non-zip input")
+	}
+
+	r := buildTestDocx(t, map[string]string{"readme.txt": "hello"})
+	if _, err := ExtractDocx(r, r.Size(), config.ResultLimits{}); err == nil {
+		t.Error("Expected error when word/document.xml is missing")
+	}
+}
+
+func TestExtractDocxLimits(t *testing.T) {
+	r := buildTestDocx(t, map[string]string{
+		"word/document.xml": testWordHeader + `<w:body><w:p><w:r><w:t>` + strings.Repeat("合同正文", 100) + `</w:t></w:r></w:p></w:body></w:document>`,
+		"word/styles.xml":   `<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"/>`,
+	})
+	_, err := ExtractDocx(r, r.Size(), config.ResultLimits{MaxEntries: 1})
+	expectLimitError(t, err, LimitEntries)
+	_, err = ExtractDocx(r, r.Size(), config.ResultLimits{MaxUncompressedBytes: 512})
+	expectLimitError(t, err, LimitUncompressedSize)
+	_, err = ExtractDocx(r, r.Size(), config.ResultLimits{MaxDownloadBytes: 64})
+	expectLimitError(t, err, LimitDownloadSize)
+}
+
+func TestFormatDocxNumber(t *testing.T) {
+	tests := []struct {
+		n      int
+		format string
+		want   string
+	}{
+		{3, "decimal", "3"},
+		{3, "lowerLetter", "c"},
+		{28, "upperLetter", "BB"},
+		{14, "lowerRoman", "xiv"},
+		{10, "chineseCounting", "十"},
+		{15, "chineseCountingThousand", "十五"},
+		{21, "chineseCounting", "二十一"},
+		{105, "chineseCounting", "一百零五"},
+		{1010, "chineseCounting", "一千零一十"},
+		{10050, "chineseCounting", "一万零五十"},
+		{3, "chineseLegalSimplified", "叁"},
+		{2, "ideographTraditional", "乙"},
+		{3, "decimalEnclosedCircle", "③"},
+		{12, "decimalFullWidth", "１２"},
+		{7, "decimalZero", "07"},
+	}
+	for _, tt := range tests {
+		if got := formatDocxNumber(tt.n, tt.format); got != tt.want {
+			t.Errorf("formatDocxNumber(%d, %q): expected %q, got %q", tt.n, tt.format, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/config"
+)
+
+// LocalFilesRoute is the route serving the files of a LocalStorage
+const LocalFilesRoute = "/api/files/"
+
+var (
+	// ErrInvalidSignature is returned for file URLs not signed by the storage
+	ErrInvalidSignature = errors.New("invalid file URL signature")
+	// ErrURLExpired is returned for file URLs past their expiry
+	ErrURLExpired = errors.New("file URL expired")
+)
+
+// LocalStorage keeps objects as files below a root directory. Its presigned
+// URLs point at LocalFilesRoute on this server and carry an HMAC of the
+// object name and expiry.
+type LocalStorage struct {
+	root    string
+	baseURL string
+	key     []byte
+	expiry  time.Duration
+	now     func() time.Time
+}
+
+func NewLocalStorage(cfg *config.StorageConfig) (*LocalStorage, error) {
+	if cfg.SigningKey == "" {
+		return nil, fmt.Errorf("local storage requires a signing key")
+	}
+	if err := os.MkdirAll(cfg.Path, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &LocalStorage{
+		root:    cfg.Path,
+		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
+		key:     []byte(cfg.SigningKey),
+		expiry:  time.Duration(cfg.ExpireHours) * time.Hour,
+		now:     time.Now,
+	}, nil
+}
+
+// filePath maps an object name to its file, rejecting names leaving the root
+func (s *LocalStorage) filePath(objectName string) (string, error) {
+	name, ok := cleanArtifactPath(objectName)
+	if !ok || name != objectName {
+		return "", fmt.Errorf("invalid object name: %s", objectName)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(name)), nil
+}
+
+// UploadFile writes the object to a temporary file first so readers never
+// see a partial object
+func (s *LocalStorage) UploadFile(ctx context.Context, objectName string, reader io.Reader, size int64, contentType string) error {
+	target, err := s.filePath(objectName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	if _, err := io.Copy(f, reader); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	if err := os.Rename(f.Name(), target); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	return nil
+}
+
+// GetObject opens an object for reading
+func (s *LocalStorage) GetObject(ctx context.Context, objectName string) (io.ReadCloser, error) {
+	return s.Open(objectName)
+}
+
+// Open opens the file of an object
+func (s *LocalStorage) Open(objectName string) (*os.File, error) {
+	target, err := s.filePath(objectName)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	return f, nil
+}
+
+// DeleteFile deletes an object and the directories it leaves empty.
+// Deleting a missing object succeeds, as with MinIO.
+func (s *LocalStorage) DeleteFile(ctx context.Context, objectName string) error {
+	target, err := s.filePath(objectName)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	// Removing a directory fails once it is not empty
+	for dir := filepath.Dir(target); dir != filepath.Clean(s.root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// ListObjects returns every object whose name starts with prefix
+func (s *LocalStorage) ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	// Walk only the directory holding the prefix
+	dir := s.root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = filepath.Join(s.root, filepath.FromSlash(prefix[:i]))
+	}
+	var objects []ObjectInfo
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{Name: name, Size: info.Size(), LastModified: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+	return objects, nil
+}
+
+// GetPresignedURL returns a signed URL of the object, valid for the
+// configured expiry
+func (s *LocalStorage) GetPresignedURL(ctx context.Context, objectName string) (string, error) {
+	if _, err := s.filePath(objectName); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(s.now().Add(s.expiry).Unix(), 10)
+	query := url.Values{
+		"expires":   {expires},
+		"signature": {s.sign(objectName, expires)},
+	}
+	u := url.URL{Path: LocalFilesRoute + objectName}
+	return s.baseURL + u.EscapedPath() + "?" + query.Encode(), nil
+}
+
+// Verify checks the expiry and signature of a file URL
+func (s *LocalStorage) Verify(objectName, expires, signature string) error {
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	want, _ := hex.DecodeString(s.sign(objectName, expires))
+	if !hmac.Equal(got, want) {
+		return ErrInvalidSignature
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if s.now().Unix() > unix {
+		return ErrURLExpired
+	}
+	return nil
+}
+
+func (s *LocalStorage) sign(objectName, expires string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(objectName + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/config"
+)
+
+func newTestLocalStorage(t *testing.T) *LocalStorage {
+	t.Helper()
+	s, err := NewLocalStorage(&config.StorageConfig{
+		Path:        t.TempDir(),
+		BaseURL:     "http://localhost:8080/",
+		SigningKey:  "test-key",
+		ExpireHours: 1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	return s
+}
+
+func TestLocalStorageObjects(t *testing.T) {
+	s := newTestLocalStorage(t)
+	ctx := context.Background()
+
+	if err := s.UploadFile(ctx, "tenant1/abc/合同.pdf", strings.NewReader("%PDF"), 4, "application/pdf"); err != nil {
+		t.Fatalf("Failed to upload: %v", err)
+	}
+	rc, err := s.GetObject(ctx, "tenant1/abc/合同.pdf")
+	if err != nil {
+		t.Fatalf("Failed to get object: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "%PDF" {
+		t.Errorf("Expected %%PDF, got %q", data)
+	}
+
+	// No temporary files remain next to the object
+	entries, _ := os.ReadDir(filepath.Join(s.root, "tenant1", "abc"))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the uploaded file, got %d entries", len(entries))
+	}
+
+	s.UploadFile(ctx, "tenant1/abcd/b.pdf", strings.NewReader("b"), 1, "")
+	listed, err := s.ListObjects(ctx, "tenant1/abc/")
+	if err != nil || len(listed) != 1 || listed[0].Name != "tenant1/abc/合同.pdf" || listed[0].Size != 4 {
+		t.Errorf("Expected only the object under the prefix, got %+v, %v", listed, err)
+	}
+	if listed, err := s.ListObjects(ctx, "missing/"); err != nil || len(listed) != 0 {
+		t.Errorf("Expected no objects under a missing prefix, got %+v, %v", listed, err)
+	}
+
+	if err := s.DeleteFile(ctx, "tenant1/abc/合同.pdf"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	// Directories left empty are removed
+	if _, err := os.Stat(filepath.Join(s.root, "tenant1", "abc")); !os.IsNotExist(err) {
+		t.Errorf("Expected the empty directory to be removed, got %v", err)
+	}
+	if _, err := s.GetObject(ctx, "tenant1/abc/合同.pdf"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected deleted object to be missing, got %v", err)
+	}
+	if err := s.DeleteFile(ctx, "tenant1/abc/合同.pdf"); err != nil {
+		t.Errorf("Expected deleting a missing object to succeed, got %v", err)
+	}
+
+	for _, name := range []string{"../outside", "/etc/passwd", "a/../../b", "a//b"} {
+		if err := s.UploadFile(ctx, name, strings.NewReader("x"), 1, ""); err == nil {
+			t.Errorf("Expected invalid object name %q to be rejected", name)
+		}
+	}
+}
+
+func TestLocalStoragePresignedURL(t *testing.T) {
+	s := newTestLocalStorage(t)
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+
+	rawURL, err := s.GetPresignedURL(context.Background(), "tenant1/abc/合同 1.pdf")
+	if err != nil {
+		t.Fatalf("Failed to presign: %v", err)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("Invalid URL %s: %v", rawURL, err)
+	}
+	if u.Host != "localhost:8080" || u.Path != LocalFilesRoute+"tenant1/abc/合同 1.pdf" {
+		t.Errorf("Unexpected URL %s", rawURL)
+	}
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+
+	if err := s.Verify("tenant1/abc/合同 1.pdf", expires, signature); err != nil {
+		t.Errorf("Expected valid signature, got %v", err)
+	}
+	if err := s.Verify("tenant1/abc/other.pdf", expires, signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for another object, got %v", err)
+	}
+	if err := s.Verify("tenant1/abc/合同 1.pdf", "9999999999", signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for a changed expiry, got %v", err)
+	}
+	if err := s.Verify("tenant1/abc/合同 1.pdf", expires, "not-hex"); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for a malformed signature, got %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if err := s.Verify("tenant1/abc/合同 1.pdf", expires, signature); !errors.Is(err, ErrURLExpired) {
+		t.Errorf("Expected ErrURLExpired, got %v", err)
+	}
+}
+
+func TestNewObjectStorage(t *testing.T) {
+	cfg := &config.Config{Storage: config.StorageConfig{Driver: StorageDriverLocal, Path: t.TempDir(), SigningKey: "key"}}
+	objects, err := NewObjectStorage(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := objects.(*LocalStorage); !ok {
+		t.Errorf("Expected LocalStorage, got %T", objects)
+	}
+
+	cfg.Storage.SigningKey = ""
+	if _, err := NewObjectStorage(context.Background(), cfg); err == nil {
+		t.Error("Expected error without a signing key")
+	}
+
+	cfg.Storage.Driver = "s3"
+	if _, err := NewObjectStorage(context.Background(), cfg); err == nil {
+		t.Error("Expected error for unknown driver")
+	}
+}
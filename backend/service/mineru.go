@@ -1,33 +1,106 @@
 package service
 
 import (
-	"archive/zip"
 	"bytes"
+	"cmp"
+	"context"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/AnTengye/contractdiff/backend/config"
+	"github.com/AnTengye/contractdiff/backend/model"
 )
 
 type MineruService struct {
-	config     *config.MineruConfig
-	httpClient *http.Client
+	config      *config.MineruConfig
+	httpClient  *http.Client
+	breaker     *CircuitBreaker
+	backoff     Backoff
+	maxAttempts int
+	sleep       func(context.Context, time.Duration) error
+}
+
+// MineruError is an error response from the MinerU API. Code is MinerU's
+// own error code, empty when the HTTP request itself failed.
+type MineruError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Transient  bool // The same request may succeed later
+}
+
+func (e *MineruError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("MinerU API error %s: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("MinerU API error: HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// transientMineruCodes are MinerU error codes worth retrying: service
+// error, upload URL failure, model unavailable, file read timeout and full
+// task queue. Other codes reject the request itself.
+var transientMineruCodes = map[string]bool{
+	"-10001": true,
+	"-60001": true,
+	"-60007": true,
+	"-60008": true,
+	"-60009": true,
+}
+
+// mineruEnvelope is the part shared by all MinerU API responses. Code is
+// a number for most errors but a string such as "A0202" for token errors.
+type mineruEnvelope struct {
+	Code    json.RawMessage `json:"code"`
+	Message string          `json:"msg"`
 }
 
 // MineruTaskRequest represents the request to create an extraction task
 type MineruTaskRequest struct {
-	URL          string `json:"url"`
-	ModelVersion string `json:"model_version"`
-	Callback     string `json:"callback,omitempty"`
-	Seed         string `json:"seed,omitempty"`
-	DataID       string `json:"data_id,omitempty"`
+	URL           string `json:"url"`
+	ModelVersion  string `json:"model_version"`
+	IsOCR         *bool  `json:"is_ocr,omitempty"`
+	EnableFormula *bool  `json:"enable_formula,omitempty"`
+	EnableTable   *bool  `json:"enable_table,omitempty"`
+	Language      string `json:"language,omitempty"`
+	PageRanges    string `json:"page_ranges,omitempty"`
+	Callback      string `json:"callback,omitempty"`
+	Seed          string `json:"seed,omitempty"`
+	DataID        string `json:"data_id,omitempty"`
+}
+
+// MineruModelVersions are the model_version values MinerU accepts
+var MineruModelVersions = []string{"pipeline", "vlm"}
+
+var (
+	pageRangesPattern = regexp.MustCompile(`^\d+(-\d+)?(,\d+(-\d+)?)*$`)
+	languagePattern   = regexp.MustCompile(`^[A-Za-z][A-Za-z_-]{0,31}$`)
+)
+
+// ValidateExtractOptions checks options requested for an upload
+func ValidateExtractOptions(opts *model.ExtractOptions) error {
+	if opts.ModelVersion != "" && !slices.Contains(MineruModelVersions, opts.ModelVersion) {
+		return fmt.Errorf("model_version must be one of %s", strings.Join(MineruModelVersions, ", "))
+	}
+	if opts.Language != "" && !languagePattern.MatchString(opts.Language) {
+		return fmt.Errorf("invalid language %q", opts.Language)
+	}
+	if opts.PageRanges != "" && !pageRangesPattern.MatchString(opts.PageRanges) {
+		return fmt.Errorf("invalid page_ranges %q, expected e.g. 2,4-6", opts.PageRanges)
+	}
+	return nil
 }
 
 // MineruTaskResponse represents the response from task creation
@@ -71,16 +144,58 @@ func NewMineruService(cfg *config.MineruConfig) *MineruService {
 		httpClient: &http.Client{
 			Timeout: 60 * time.Second,
 		},
+		breaker: NewCircuitBreaker(cfg.Breaker.FailureThreshold, time.Duration(cfg.Breaker.CooldownSeconds)*time.Second),
+		backoff: Backoff{
+			Base: time.Duration(cfg.Retry.BaseDelayMs) * time.Millisecond,
+			Max:  time.Duration(cfg.Retry.MaxDelayMs) * time.Millisecond,
+		},
+		maxAttempts: max(cfg.Retry.MaxAttempts, 1),
+		sleep:       sleepContext,
+	}
+}
+
+// BreakerStats returns the state of the circuit breaker shared by API calls
+func (s *MineruService) BreakerStats() BreakerStats {
+	return s.breaker.Stats()
+}
+
+// ResolveOptions completes the options requested for a tenant's upload
+// with the tenant's and then the global defaults. The result is what
+// CreateTask sends, and is recorded on the contract.
+func (s *MineruService) ResolveOptions(tenant string, requested *model.ExtractOptions) *model.ExtractOptions {
+	opts := model.ExtractOptions{}
+	if requested != nil {
+		opts = *requested
+	}
+	defaults := []config.ExtractOptions{s.config.Tenants[tenant], s.config.Options}
+	for _, d := range defaults {
+		opts.ModelVersion = cmp.Or(opts.ModelVersion, d.ModelVersion)
+		opts.IsOCR = cmp.Or(opts.IsOCR, d.IsOCR)
+		opts.EnableFormula = cmp.Or(opts.EnableFormula, d.EnableFormula)
+		opts.EnableTable = cmp.Or(opts.EnableTable, d.EnableTable)
+		opts.Language = cmp.Or(opts.Language, d.Language)
+		opts.PageRanges = cmp.Or(opts.PageRanges, d.PageRanges)
 	}
+	opts.ModelVersion = cmp.Or(opts.ModelVersion, s.config.ModelVersion)
+	return &opts
 }
 
-// CreateTask creates a new extraction task
-func (s *MineruService) CreateTask(pdfURL, dataID string) (*MineruTaskResponse, error) {
+// CreateTask creates a new extraction task. Nil options use the configured
+// model version and MinerU's defaults.
+func (s *MineruService) CreateTask(ctx context.Context, pdfURL, dataID string, opts *model.ExtractOptions) (*MineruTaskResponse, error) {
 	reqBody := MineruTaskRequest{
 		URL:          pdfURL,
 		ModelVersion: s.config.ModelVersion,
 		DataID:       dataID,
 	}
+	if opts != nil {
+		reqBody.ModelVersion = cmp.Or(opts.ModelVersion, reqBody.ModelVersion)
+		reqBody.IsOCR = opts.IsOCR
+		reqBody.EnableFormula = opts.EnableFormula
+		reqBody.EnableTable = opts.EnableTable
+		reqBody.Language = opts.Language
+		reqBody.PageRanges = opts.PageRanges
+	}
 
 	if s.config.CallbackURL != "" {
 		reqBody.Callback = s.config.CallbackURL
@@ -92,41 +207,124 @@ func (s *MineruService) CreateTask(pdfURL, dataID string) (*MineruTaskResponse,
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", s.config.APIURL+"/extract/task", bytes.NewBuffer(jsonData))
+	// Each request that reaches MinerU may create a billed task, even when
+	// it times out or fails, so only requests never sent are retried
+	body, err := s.call(ctx, "create_task", notSent, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", s.config.APIURL+"/extract/task", bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+s.config.APIToken)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "*/*")
-
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+	var result MineruTaskResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w, body: %s", err, string(body))
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	return &result, nil
+}
+
+// GetTaskStatus queries the status of a task
+func (s *MineruService) GetTaskStatus(ctx context.Context, taskID string) (*MineruTaskStatusResponse, error) {
+	body, err := s.call(ctx, "get_task_status", IsTransient, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/extract/task/%s", s.config.APIURL, taskID), nil)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 
-	var result MineruTaskResponse
+	// Log raw response for debugging at debug level
+	slog.Debug("MinerU status response",
+		"task_id", taskID,
+		"body", string(body),
+	)
+
+	var result MineruTaskStatusResponse
 	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w, body: %s", err, string(body))
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// call sends an API request built by newRequest, retrying failures that
+// retryable accepts with backoff. It returns the body of a successful
+// response. Every attempt goes through the circuit breaker, so an outage
+// stops all callers once the breaker opens. Cancelling ctx aborts the call.
+func (s *MineruService) call(ctx context.Context, op string, retryable func(error) bool, newRequest func() (*http.Request, error)) ([]byte, error) {
+	var err error
+	for attempt := 1; ; attempt++ {
+		var body []byte
+		body, err = s.do(ctx, newRequest)
+		if err == nil {
+			return body, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if attempt >= s.maxAttempts || !retryable(err) || errors.Is(err, ErrCircuitOpen) {
+			return nil, err
+		}
+
+		delay := s.backoff.Delay(attempt)
+		slog.Warn("MinerU API call failed, retrying",
+			"op", op,
+			"attempt", attempt,
+			"delay", delay,
+			"error", err,
+		)
+		if err := s.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
 	}
+}
+
+// notSent reports whether a request failed before connecting to MinerU,
+// so that sending it again cannot repeat its effect
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
 
-	if result.Code != 0 {
-		return nil, fmt.Errorf("MinerU API error: %s", result.Message)
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
+}
 
-	return &result, nil
+// do sends a single API request and classifies its failure
+func (s *MineruService) do(ctx context.Context, newRequest func() (*http.Request, error)) ([]byte, error) {
+	if err := s.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	body, err := s.send(newRequest)
+	var apiErr *MineruError
+	if err != nil && ctx.Err() != nil {
+		// A cancelled call says nothing about the service
+		s.breaker.Abandon()
+	} else if err != nil && (!errors.As(err, &apiErr) || apiErr.Transient) {
+		s.breaker.Failure()
+	} else {
+		// Permanent errors are answers from a working service
+		s.breaker.Success()
+	}
+	return body, err
 }
 
-// GetTaskStatus queries the status of a task
-func (s *MineruService) GetTaskStatus(taskID string) (*MineruTaskStatusResponse, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/extract/task/%s", s.config.APIURL, taskID), nil)
+func (s *MineruService) send(newRequest func() (*http.Request, error)) ([]byte, error) {
+	req, err := newRequest()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -145,22 +343,39 @@ func (s *MineruService) GetTaskStatus(taskID string) (*MineruTaskStatusResponse,
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	// Log raw response for debugging at debug level
-	slog.Debug("MinerU status response",
-		"task_id", taskID,
-		"body", string(body),
-	)
+	var envelope mineruEnvelope
+	jsonErr := json.Unmarshal(body, &envelope)
+	code := strings.Trim(string(envelope.Code), `"`)
 
-	var result MineruTaskStatusResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if resp.StatusCode >= 300 {
+		msg := envelope.Message
+		if jsonErr != nil || msg == "" {
+			msg = http.StatusText(resp.StatusCode)
+		}
+		return nil, &MineruError{
+			StatusCode: resp.StatusCode,
+			Code:       code,
+			Message:    msg,
+			Transient:  transientStatus(resp.StatusCode) || transientMineruCodes[code],
+		}
 	}
-
-	if result.Code != 0 {
-		return nil, fmt.Errorf("MinerU API error: %s", result.Message)
+	if jsonErr != nil {
+		return nil, fmt.Errorf("failed to parse response: %w, body: %s", jsonErr, string(body))
 	}
+	if code != "" && code != "0" {
+		return nil, &MineruError{
+			StatusCode: resp.StatusCode,
+			Code:       code,
+			Message:    envelope.Message,
+			Transient:  transientMineruCodes[code],
+		}
+	}
+	return body, nil
+}
 
-	return &result, nil
+// transientStatus reports whether an HTTP status may succeed on retry
+func transientStatus(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
 }
 
 // VerifyCallback verifies the callback checksum
@@ -169,111 +384,69 @@ func (s *MineruService) VerifyCallback(checksum, content string, uid string) boo
 	data := uid + s.config.Seed + content
 	hash := sha256.Sum256([]byte(data))
 	expected := hex.EncodeToString(hash[:])
-	return checksum == expected
+	return subtle.ConstantTimeCompare([]byte(checksum), []byte(expected)) == 1
 }
 
-// FetchJSONResult fetches the JSON result from a direct URL (legacy)
-func (s *MineruService) FetchJSONResult(jsonURL string) (map[string]interface{}, error) {
-	resp, err := s.httpClient.Get(jsonURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch JSON: %w", err)
-	}
-	defer resp.Body.Close()
+// CallbacksConfigured reports whether callbacks can be verified. Without
+// the UID or seed the checksum holds no secret and anyone could forge it.
+func (s *MineruService) CallbacksConfigured() bool {
+	return s.config.UID != "" && s.config.Seed != ""
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read JSON: %w", err)
+// VerifyCallbackPayload verifies a callback payload with the configured UID.
+// It fails closed when callbacks are not configured.
+func (s *MineruService) VerifyCallbackPayload(payload *MineruCallbackPayload) bool {
+	if !s.CallbacksConfigured() {
+		return false
 	}
+	return s.VerifyCallback(payload.Checksum, payload.Content, s.config.UID)
+}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+// get downloads a result URL, which is signed and needs no API token
+func (s *MineruService) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
 	}
-
-	return result, nil
+	return s.httpClient.Do(req)
 }
 
-// FetchZipAndExtractJSON downloads the ZIP file and extracts the JSON content
-func (s *MineruService) FetchZipAndExtractJSON(zipURL string) (map[string]interface{}, error) {
+// FetchResultZip downloads a result ZIP into a temporary file, which is
+// removed when the returned ZIP is closed
+func (s *MineruService) FetchResultZip(ctx context.Context, zipURL string) (*ResultZip, error) {
 	slog.Debug("downloading ZIP", "url", zipURL)
 
-	resp, err := s.httpClient.Get(zipURL)
+	resp, err := s.get(ctx, zipURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download ZIP: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Read the entire ZIP into memory
-	zipData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read ZIP: %w", err)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download ZIP: HTTP %d", resp.StatusCode)
 	}
-
-	slog.Debug("ZIP downloaded", "size_bytes", len(zipData))
-
-	// Open the ZIP archive
-	zipReader, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
-	if err != nil {
-		return nil, fmt.Errorf("failed to open ZIP: %w", err)
+	limits := resultLimits(s.config.ResultLimits)
+	if resp.ContentLength > limits.MaxDownloadBytes {
+		return nil, &ResultLimitError{Limit: LimitDownloadSize, Max: limits.MaxDownloadBytes}
 	}
 
-	// Look for JSON files in the ZIP
-	var jsonData map[string]interface{}
-	jsonFiles := []string{"content_list.json", "middle.json", "model.json"}
-
-	for _, file := range zipReader.File {
-		slog.Debug("ZIP file entry", "name", file.Name)
-
-		// Check if this is one of the JSON files we're looking for
-		for _, targetFile := range jsonFiles {
-			if strings.HasSuffix(file.Name, targetFile) {
-				slog.Debug("found target JSON", "file", file.Name)
-
-				rc, err := file.Open()
-				if err != nil {
-					continue
-				}
-
-				content, err := io.ReadAll(rc)
-				rc.Close()
-				if err != nil {
-					continue
-				}
-
-				if err := json.Unmarshal(content, &jsonData); err != nil {
-					slog.Debug("failed to parse JSON file", "file", file.Name, "error", err)
-					continue
-				}
-
-				slog.Info("successfully parsed JSON", "file", file.Name)
-				return jsonData, nil
-			}
+	f, size, err := downloadToTemp(resp.Body, limits.MaxDownloadBytes)
+	if err != nil {
+		var limitErr *ResultLimitError
+		if errors.As(err, &limitErr) {
+			return nil, err
 		}
+		return nil, fmt.Errorf("failed to read ZIP: %w", err)
 	}
 
-	// If no specific JSON found, try any .json file
-	for _, file := range zipReader.File {
-		if strings.HasSuffix(file.Name, ".json") {
-			slog.Debug("trying fallback JSON", "file", file.Name)
-
-			rc, err := file.Open()
-			if err != nil {
-				continue
-			}
-
-			content, err := io.ReadAll(rc)
-			rc.Close()
-			if err != nil {
-				continue
-			}
-
-			if err := json.Unmarshal(content, &jsonData); err != nil {
-				continue
-			}
-
-			return jsonData, nil
-		}
+	z, err := OpenResultZip(f, size, limits)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
 	}
+	z.file = f
 
-	return nil, fmt.Errorf("no valid JSON file found in ZIP")
+	slog.Debug("ZIP downloaded", "size_bytes", size)
+	return z, nil
 }
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/model"
+)
+
+// MineruParser adapts MineruService to DocumentParser
+type MineruParser struct {
+	mineruService *MineruService
+}
+
+func NewMineruParser(mineruSvc *MineruService) *MineruParser {
+	return &MineruParser{mineruService: mineruSvc}
+}
+
+func (p *MineruParser) Name() string {
+	return ParserMineru
+}
+
+// ResolveOptions completes requested options with the tenant's and the
+// global defaults
+func (p *MineruParser) ResolveOptions(tenant string, requested *model.ExtractOptions) *model.ExtractOptions {
+	return p.mineruService.ResolveOptions(tenant, requested)
+}
+
+// Submit creates a MinerU task that downloads the document from job.FileURL
+func (p *MineruParser) Submit(ctx context.Context, job *ParseJob) (*ParseTask, error) {
+	if job.FileURL == "" {
+		return nil, fmt.Errorf("MinerU requires a file URL")
+	}
+	resp, err := p.mineruService.CreateTask(ctx, job.FileURL, job.ContractID, job.Options)
+	if err != nil {
+		return nil, err
+	}
+	return &ParseTask{TaskID: resp.Data.TaskID}, nil
+}
+
+// Poll queries the task state
+func (p *MineruParser) Poll(ctx context.Context, taskID string) (*ParseStatus, error) {
+	resp, err := p.mineruService.GetTaskStatus(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	return &ParseStatus{
+		State:          mineruParseState(resp.Data.State),
+		Phase:          resp.Data.State,
+		ResultURL:      resp.Data.FullZipURL,
+		ErrorMsg:       resp.Data.ErrorMsg,
+		ExtractedPages: resp.Data.ExtractProgress.ExtractedPages,
+		TotalPages:     resp.Data.ExtractProgress.TotalPages,
+		StartedAt:      parseMineruTime(resp.Data.ExtractProgress.StartTime),
+	}, nil
+}
+
+// mineruTimeZone is the zone of MinerU timestamps without an offset
+var mineruTimeZone = time.FixedZone("UTC+8", 8*60*60)
+
+// parseMineruTime parses a MinerU timestamp such as "2025-01-20 11:43:20",
+// returning the zero time for empty or unknown formats
+func parseMineruTime(s string) time.Time {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t
+	}
+	if t, err := time.ParseInLocation(time.DateTime, s, mineruTimeZone); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// Fetch downloads the result ZIP and extracts its JSON, keeping every file
+// of the ZIP until the result is closed. It returns nil without an error
+// when the task finished without a result.
+func (p *MineruParser) Fetch(ctx context.Context, status *ParseStatus) (*ParseResult, error) {
+	if status.ResultURL == "" {
+		return nil, nil
+	}
+	z, err := p.mineruService.FetchResultZip(ctx, status.ResultURL)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonData, err := z.ExtractJSON()
+	if err != nil {
+		z.Close()
+		return nil, err
+	}
+	return &ParseResult{Data: jsonData, Files: z.Files(), closer: z}, nil
+}
+
+// MineruCallbackStatus converts a MinerU callback to the status Poll
+// reports for the same task, so both are ingested alike
+func MineruCallbackStatus(state, fullZipURL, errMsg string) *ParseStatus {
+	return &ParseStatus{
+		State:     mineruParseState(state),
+		ResultURL: fullZipURL,
+		ErrorMsg:  errMsg,
+	}
+}
+
+// mineruParseState maps MinerU task states (pending, waiting-file, running,
+// converting, done, failed) to parse states
+func mineruParseState(state string) string {
+	switch state {
+	case "done":
+		return ParseStateDone
+	case "failed":
+		return ParseStateFailed
+	case "running", "converting":
+		return ParseStateRunning
+	default:
+		return ParseStatePending
+	}
+}
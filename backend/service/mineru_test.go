@@ -1,12 +1,22 @@
 package service
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/AnTengye/contractdiff/backend/config"
+	"github.com/AnTengye/contractdiff/backend/model"
 )
 
 func TestNewMineruService(t *testing.T) {
@@ -61,7 +71,7 @@ func TestMineruServiceCreateTask(t *testing.T) {
 	}
 
 	svc := NewMineruService(cfg)
-	resp, err := svc.CreateTask("http://example.com/test.pdf", "data-123")
+	resp, err := svc.CreateTask(context.Background(), "http://example.com/test.pdf", "data-123", nil)
 
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
@@ -98,13 +108,94 @@ func TestMineruServiceCreateTaskWithCallback(t *testing.T) {
 	}
 
 	svc := NewMineruService(cfg)
-	_, err := svc.CreateTask("http://example.com/test.pdf", "data-123")
+	_, err := svc.CreateTask(context.Background(), "http://example.com/test.pdf", "data-123", nil)
 
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
 }
 
+func TestMineruServiceCreateTaskWithOptions(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		response := MineruTaskResponse{Code: 0}
+		response.Data.TaskID = "task-789"
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	svc := NewMineruService(&config.MineruConfig{APIURL: server.URL, ModelVersion: "vlm"})
+	isOCR := true
+	opts := &model.ExtractOptions{ModelVersion: "pipeline", IsOCR: &isOCR, Language: "en", PageRanges: "2,4-6"}
+	if _, err := svc.CreateTask(context.Background(), "http://example.com/test.pdf", "data-123", opts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if body["model_version"] != "pipeline" || body["is_ocr"] != true || body["language"] != "en" || body["page_ranges"] != "2,4-6" {
+		t.Errorf("Expected options in request, got %v", body)
+	}
+	if _, ok := body["enable_table"]; ok {
+		t.Errorf("Expected unset options to be omitted, got %v", body)
+	}
+}
+
+func TestMineruServiceResolveOptions(t *testing.T) {
+	yes, no := true, false
+	svc := NewMineruService(&config.MineruConfig{
+		ModelVersion: "vlm",
+		Options:      config.ExtractOptions{Language: "ch", EnableTable: &yes},
+		Tenants: map[string]config.ExtractOptions{
+			"scans": {ModelVersion: "pipeline", IsOCR: &yes, Language: "en"},
+		},
+	})
+
+	tests := []struct {
+		name      string
+		tenant    string
+		requested *model.ExtractOptions
+		want      model.ExtractOptions
+	}{
+		{"global defaults", "tenant1", nil, model.ExtractOptions{ModelVersion: "vlm", EnableTable: &yes, Language: "ch"}},
+		{"tenant defaults", "scans", nil, model.ExtractOptions{ModelVersion: "pipeline", IsOCR: &yes, EnableTable: &yes, Language: "en"}},
+		{"requested", "scans", &model.ExtractOptions{IsOCR: &no, PageRanges: "1-3"}, model.ExtractOptions{ModelVersion: "pipeline", IsOCR: &no, EnableTable: &yes, Language: "en", PageRanges: "1-3"}},
+	}
+
+	for _, tt := range tests {
+		got := svc.ResolveOptions(tt.tenant, tt.requested)
+		if !reflect.DeepEqual(*got, tt.want) {
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(tt.want)
+			t.Errorf("%s: expected %s, got %s", tt.name, wantJSON, gotJSON)
+		}
+	}
+}
+
+func TestValidateExtractOptions(t *testing.T) {
+	valid := []model.ExtractOptions{
+		{},
+		{ModelVersion: "pipeline", Language: "ch", PageRanges: "2,4-6"},
+		{ModelVersion: "vlm", Language: "chinese_cht", PageRanges: "1"},
+	}
+	for _, opts := range valid {
+		if err := ValidateExtractOptions(&opts); err != nil {
+			t.Errorf("Unexpected error for %+v: %v", opts, err)
+		}
+	}
+
+	invalid := []model.ExtractOptions{
+		{ModelVersion: "gpt"},
+		{Language: "en; rm -rf"},
+		{PageRanges: "1-"},
+		{PageRanges: "a,b"},
+	}
+	for _, opts := range invalid {
+		if err := ValidateExtractOptions(&opts); err == nil {
+			t.Errorf("Expected error for %+v", opts)
+		}
+	}
+}
+
 func TestMineruServiceCreateTaskError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		response := MineruTaskResponse{
@@ -121,7 +212,7 @@ func TestMineruServiceCreateTaskError(t *testing.T) {
 	}
 
 	svc := NewMineruService(cfg)
-	_, err := svc.CreateTask("http://example.com/test.pdf", "data-123")
+	_, err := svc.CreateTask(context.Background(), "http://example.com/test.pdf", "data-123", nil)
 
 	if err == nil {
 		t.Error("Expected error for API error response")
@@ -154,7 +245,7 @@ func TestMineruServiceGetTaskStatus(t *testing.T) {
 	}
 
 	svc := NewMineruService(cfg)
-	status, err := svc.GetTaskStatus("task-123")
+	status, err := svc.GetTaskStatus(context.Background(), "task-123")
 
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
@@ -183,7 +274,7 @@ func TestMineruServiceGetTaskStatusError(t *testing.T) {
 	}
 
 	svc := NewMineruService(cfg)
-	_, err := svc.GetTaskStatus("invalid-task")
+	_, err := svc.GetTaskStatus(context.Background(), "invalid-task")
 
 	if err == nil {
 		t.Error("Expected error for API error response")
@@ -205,44 +296,6 @@ func TestMineruServiceVerifyCallback(t *testing.T) {
 	}
 }
 
-func TestMineruServiceFetchJSONResult(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"key1": "value1",
-			"key2": 123,
-		})
-	}))
-	defer server.Close()
-
-	cfg := &config.MineruConfig{}
-	svc := NewMineruService(cfg)
-
-	result, err := svc.FetchJSONResult(server.URL)
-	if err != nil {
-		t.Fatalf("Unexpected error: %v", err)
-	}
-
-	if result["key1"] != "value1" {
-		t.Errorf("Expected key1='value1', got '%v'", result["key1"])
-	}
-}
-
-func TestMineruServiceFetchJSONResultInvalid(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("invalid json"))
-	}))
-	defer server.Close()
-
-	cfg := &config.MineruConfig{}
-	svc := NewMineruService(cfg)
-
-	_, err := svc.FetchJSONResult(server.URL)
-	if err == nil {
-		t.Error("Expected error for invalid JSON")
-	}
-}
-
 func TestMineruServiceCreateTaskNetworkError(t *testing.T) {
 	cfg := &config.MineruConfig{
 		APIURL:   "http://invalid-host-that-does-not-exist:9999",
@@ -250,7 +303,7 @@ func TestMineruServiceCreateTaskNetworkError(t *testing.T) {
 	}
 
 	svc := NewMineruService(cfg)
-	_, err := svc.CreateTask("http://example.com/test.pdf", "data-123")
+	_, err := svc.CreateTask(context.Background(), "http://example.com/test.pdf", "data-123", nil)
 
 	if err == nil {
 		t.Error("Expected error for network failure")
@@ -264,7 +317,7 @@ func TestMineruServiceGetTaskStatusNetworkError(t *testing.T) {
 	}
 
 	svc := NewMineruService(cfg)
-	_, err := svc.GetTaskStatus("task-123")
+	_, err := svc.GetTaskStatus(context.Background(), "task-123")
 
 	if err == nil {
 		t.Error("Expected error for network failure")
@@ -283,7 +336,7 @@ func TestMineruServiceCreateTaskInvalidResponse(t *testing.T) {
 	}
 
 	svc := NewMineruService(cfg)
-	_, err := svc.CreateTask("http://example.com/test.pdf", "data-123")
+	_, err := svc.CreateTask(context.Background(), "http://example.com/test.pdf", "data-123", nil)
 
 	if err == nil {
 		t.Error("Expected error for invalid JSON response")
@@ -302,23 +355,13 @@ func TestMineruServiceGetTaskStatusInvalidResponse(t *testing.T) {
 	}
 
 	svc := NewMineruService(cfg)
-	_, err := svc.GetTaskStatus("task-123")
+	_, err := svc.GetTaskStatus(context.Background(), "task-123")
 
 	if err == nil {
 		t.Error("Expected error for invalid JSON response")
 	}
 }
 
-func TestMineruServiceFetchJSONResultNetworkError(t *testing.T) {
-	cfg := &config.MineruConfig{}
-	svc := NewMineruService(cfg)
-
-	_, err := svc.FetchJSONResult("http://invalid-host-that-does-not-exist:9999/test.json")
-	if err == nil {
-		t.Error("Expected error for network failure")
-	}
-}
-
 func TestMineruServiceVerifyCallbackValid(t *testing.T) {
 	cfg := &config.MineruConfig{
 		Seed: "test-seed",
@@ -336,17 +379,17 @@ func TestMineruServiceVerifyCallbackValid(t *testing.T) {
 	}
 }
 
-func TestMineruServiceFetchZipAndExtractJSONNetworkError(t *testing.T) {
+func TestMineruServiceFetchResultZipNetworkError(t *testing.T) {
 	cfg := &config.MineruConfig{}
 	svc := NewMineruService(cfg)
 
-	_, err := svc.FetchZipAndExtractJSON("http://invalid-host-that-does-not-exist:9999/test.zip")
+	_, err := svc.FetchResultZip(context.Background(), "http://invalid-host-that-does-not-exist:9999/test.zip")
 	if err == nil {
 		t.Error("Expected error for network failure")
 	}
 }
 
-func TestMineruServiceFetchZipAndExtractJSONInvalidZip(t *testing.T) {
+func TestMineruServiceFetchResultZipInvalidZip(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("not a zip file"))
 	}))
@@ -355,8 +398,225 @@ func TestMineruServiceFetchZipAndExtractJSONInvalidZip(t *testing.T) {
 	cfg := &config.MineruConfig{}
 	svc := NewMineruService(cfg)
 
-	_, err := svc.FetchZipAndExtractJSON(server.URL)
+	_, err := svc.FetchResultZip(context.Background(), server.URL)
 	if err == nil {
 		t.Error("Expected error for invalid ZIP")
 	}
 }
+
+func TestMineruServiceVerifyCallbackPayload(t *testing.T) {
+	svc := NewMineruService(&config.MineruConfig{UID: "test-uid", Seed: "test-seed"})
+
+	// SHA256("test-uid" + "test-seed" + "test-content")
+	hash := sha256.Sum256([]byte("test-uidtest-seedtest-content"))
+	payload := &MineruCallbackPayload{Checksum: hex.EncodeToString(hash[:]), Content: "test-content"}
+	if !svc.VerifyCallbackPayload(payload) {
+		t.Error("Expected valid checksum to verify")
+	}
+
+	payload.Content = "tampered-content"
+	if svc.VerifyCallbackPayload(payload) {
+		t.Error("Expected tampered content to fail verification")
+	}
+
+	// Without a UID the checksum is SHA256(seed + content), which anyone
+	// knowing the seed can compute
+	noUID := NewMineruService(&config.MineruConfig{Seed: "test-seed"})
+	hash = sha256.Sum256([]byte("test-seedtest-content"))
+	if noUID.VerifyCallbackPayload(&MineruCallbackPayload{Checksum: hex.EncodeToString(hash[:]), Content: "test-content"}) {
+		t.Error("Expected verification to fail without a UID")
+	}
+}
+
+// newRetryingMineruService returns a service against apiURL that retries
+// without sleeping
+func newRetryingMineruService(apiURL string, threshold int) *MineruService {
+	svc := NewMineruService(&config.MineruConfig{
+		APIURL:  apiURL,
+		Retry:   config.RetryConfig{MaxAttempts: 3, BaseDelayMs: 10},
+		Breaker: config.BreakerConfig{FailureThreshold: threshold, CooldownSeconds: 60},
+	})
+	svc.sleep = func(context.Context, time.Duration) error { return nil }
+	return svc
+}
+
+func TestMineruServiceRetriesTransientErrors(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch requests {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Write([]byte(`{"code":-60009,"msg":"queue full"}`))
+		default:
+			w.Write([]byte(`{"code":0,"data":{"task_id":"task-123"}}`))
+		}
+	}))
+	defer server.Close()
+
+	svc := newRetryingMineruService(server.URL, 5)
+	resp, err := svc.GetTaskStatus(context.Background(), "task-123")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.Data.TaskID != "task-123" || requests != 3 {
+		t.Errorf("Expected task-123 after 3 requests, got '%s' after %d", resp.Data.TaskID, requests)
+	}
+	if stats := svc.BreakerStats(); stats.State != BreakerClosed || stats.ConsecutiveFailures != 0 {
+		t.Errorf("Expected closed breaker after success, got %+v", stats)
+	}
+}
+
+// roundTripFunc is an http.RoundTripper calling itself
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestMineruServiceCreateTaskRetriesUnsentRequests(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"code":0,"data":{"task_id":"task-123"}}`))
+	}))
+	defer server.Close()
+
+	// A request that reached MinerU may have created a task, so it is not
+	// sent again
+	svc := newRetryingMineruService(server.URL, 5)
+	if _, err := svc.CreateTask(context.Background(), "http://example.com/test.pdf", "data-123", nil); !IsTransient(err) {
+		t.Fatalf("Expected a transient error, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected no retries, got %d requests", requests)
+	}
+
+	// Failed connections are retried
+	dials := 0
+	svc.httpClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		dials++
+		if dials == 1 {
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	resp, err := svc.CreateTask(context.Background(), "http://example.com/test.pdf", "data-123", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.Data.TaskID != "task-123" || dials != 2 || requests != 2 {
+		t.Errorf("Expected task-123 after 2 dials and 2 requests, got '%s' after %d and %d", resp.Data.TaskID, dials, requests)
+	}
+}
+
+func TestMineruServicePermanentErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		code   string
+	}{
+		{"file too large", http.StatusOK, `{"code":-60005,"msg":"file too large"}`, "-60005"},
+		{"token error", http.StatusUnauthorized, `{"code":"A0202","msg":"token error"}`, "A0202"},
+		{"bad request", http.StatusBadRequest, `not json`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := newRetryingMineruService(server.URL, 1).GetTaskStatus(context.Background(), "task-1")
+			var apiErr *MineruError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Expected MineruError, got %v", err)
+			}
+			if apiErr.Code != tt.code || IsTransient(err) {
+				t.Errorf("Expected permanent error with code '%s', got %+v", tt.code, apiErr)
+			}
+			if requests != 1 {
+				t.Errorf("Expected no retries, got %d requests", requests)
+			}
+		})
+	}
+}
+
+func TestMineruServiceBreakerOpens(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	svc := newRetryingMineruService(server.URL, 2)
+	_, err := svc.GetTaskStatus(context.Background(), "task-1")
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen after the breaker opened, got %v", err)
+	}
+	if !IsTransient(err) {
+		t.Error("Expected open breaker to be transient")
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests before the breaker opened, got %d", requests)
+	}
+
+	// Other calls fail fast without reaching MinerU
+	if _, err := svc.CreateTask(context.Background(), "http://example.com/test.pdf", "data-123", nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected no requests while open, got %d", requests)
+	}
+	if stats := svc.BreakerStats(); stats.State != BreakerOpen || stats.Rejected != 2 {
+		t.Errorf("Unexpected breaker stats: %+v", stats)
+	}
+}
+
+func TestMineruServiceCancelledCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	svc := newRetryingMineruService(server.URL, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := svc.GetTaskStatus(ctx, "task-1")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the call to be aborted, got %v", err)
+	}
+	// Abandoned calls neither retry nor count against MinerU
+	if stats := svc.BreakerStats(); stats.State != BreakerClosed || stats.ConsecutiveFailures != 0 {
+		t.Errorf("Unexpected breaker stats: %+v", stats)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&MineruError{StatusCode: 503, Transient: true}, true},
+		{fmt.Errorf("wrapped: %w", &MineruError{Code: "-60012"}), false},
+		{ErrCircuitOpen, true},
+		{&url.Error{Op: "Get", URL: "http://mineru.test", Err: errors.New("connection refused")}, true},
+		{errors.New("invalid DOCX"), false},
+	}
+
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%v): expected %v, got %v", tt.err, tt.want, got)
+		}
+	}
+}
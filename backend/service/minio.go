@@ -62,6 +62,20 @@ func (s *MinioService) UploadFile(ctx context.Context, objectName string, reader
 	return nil
 }
 
+// GetObject opens an object for reading
+func (s *MinioService) GetObject(ctx context.Context, objectName string) (io.ReadCloser, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	// GetObject is lazy; Stat surfaces missing objects before streaming
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	return obj, nil
+}
+
 // GetPresignedURL generates a presigned URL for the object with expiration
 func (s *MinioService) GetPresignedURL(ctx context.Context, objectName string) (string, error) {
 	expiry := time.Duration(s.config.ExpireDays) * 24 * time.Hour
@@ -83,6 +97,18 @@ func (s *MinioService) DeleteFile(ctx context.Context, objectName string) error
 	return nil
 }
 
+// ListObjects returns every object whose name starts with prefix
+func (s *MinioService) ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", obj.Err)
+		}
+		objects = append(objects, ObjectInfo{Name: obj.Key, Size: obj.Size, LastModified: obj.LastModified})
+	}
+	return objects, nil
+}
+
 // GetPublicURL returns a public URL for the object (if bucket policy allows)
 func (s *MinioService) GetPublicURL(objectName string) string {
 	protocol := "http"
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/config"
+	"github.com/AnTengye/contractdiff/backend/model"
+)
+
+// Parser names
+const (
+	ParserMineru = "mineru"
+	ParserDocx   = "docx"
+)
+
+// Parse states reported by DocumentParser.Poll
+const (
+	ParseStatePending = "pending"
+	ParseStateRunning = "running"
+	ParseStateDone    = "done"
+	ParseStateFailed  = "failed"
+)
+
+// ErrNoPolling is returned by Poll and Fetch of parsers that always finish
+// during Submit
+var ErrNoPolling = errors.New("parser completes synchronously")
+
+// IsTransient reports whether a parser error may go away on retry: network
+// failures, retryable MinerU errors and an open circuit breaker. Any other
+// error is permanent.
+func IsTransient(err error) bool {
+	var apiErr *MineruError
+	if errors.As(err, &apiErr) {
+		return apiErr.Transient
+	}
+	var netErr net.Error
+	return errors.Is(err, ErrCircuitOpen) || errors.As(err, &netErr)
+}
+
+// ParseJob describes a document to parse
+type ParseJob struct {
+	ContractID string
+	Tenant     string
+	Filename   string
+	FileURL    string                // URL the parser downloads the document from
+	Options    *model.ExtractOptions // Resolved extraction options, nil for parsers without options
+}
+
+// ParseTask is the result of submitting a job. Result is set when the
+// parser finished during Submit; otherwise TaskID is polled.
+type ParseTask struct {
+	TaskID string
+	Result map[string]interface{}
+}
+
+// ParseStatus is the state of a submitted task
+type ParseStatus struct {
+	State          string // pending, running, done, failed
+	Phase          string // Parser's own state, e.g. MinerU's converting
+	ResultURL      string // Where Fetch downloads the result from
+	ErrorMsg       string
+	ExtractedPages int
+	TotalPages     int
+	StartedAt      time.Time // When extraction started, zero if unknown
+}
+
+// ParseResult is a fetched parse result: the JSON document and the other
+// files the parser produced, such as markdown and extracted images. The
+// files can be opened until the result is closed.
+type ParseResult struct {
+	Data   map[string]interface{}
+	Files  []ResultFile
+	closer io.Closer // Releases what the files are read from, if set
+}
+
+// Close releases the result's files
+func (r *ParseResult) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// DocumentParser turns an uploaded document into a normalized result in
+// MinerU's middle.json shape (pdf_info → para_blocks → lines → spans)
+type DocumentParser interface {
+	Name() string
+	Submit(ctx context.Context, job *ParseJob) (*ParseTask, error)
+	Poll(ctx context.Context, taskID string) (*ParseStatus, error)
+	Fetch(ctx context.Context, status *ParseStatus) (*ParseResult, error)
+}
+
+// OptionsParser is a DocumentParser that takes extraction options
+type OptionsParser interface {
+	DocumentParser
+	// ResolveOptions completes the options requested for a tenant's upload
+	// with the configured defaults
+	ResolveOptions(tenant string, requested *model.ExtractOptions) *model.ExtractOptions
+}
+
+// ParserRouter selects the parser for an upload from the tenant and file
+// extension
+type ParserRouter struct {
+	parsers map[string]DocumentParser
+	cfg     config.ParserConfig
+}
+
+// NewParserRouter creates a router over parsers. Every parser named in cfg
+// must be registered.
+func NewParserRouter(cfg *config.ParserConfig, parsers ...DocumentParser) (*ParserRouter, error) {
+	r := &ParserRouter{
+		parsers: make(map[string]DocumentParser, len(parsers)),
+		cfg:     *cfg,
+	}
+	for _, p := range parsers {
+		r.parsers[p.Name()] = p
+	}
+	if r.cfg.Default == "" {
+		r.cfg.Default = ParserMineru
+	}
+
+	routes := map[string]config.ParserRoute{"": r.cfg.ParserRoute}
+	for tenant, route := range r.cfg.Tenants {
+		routes[tenant] = route
+	}
+	for tenant, route := range routes {
+		names := []string{route.Default}
+		for _, name := range route.ByExtension {
+			names = append(names, name)
+		}
+		for _, name := range names {
+			if name == "" {
+				continue
+			}
+			if _, ok := r.parsers[name]; !ok {
+				if tenant != "" {
+					return nil, fmt.Errorf("unknown parser %q for tenant %s", name, tenant)
+				}
+				return nil, fmt.Errorf("unknown parser %q", name)
+			}
+		}
+	}
+	return r, nil
+}
+
+// Route returns the parser for a tenant's file. Tenant extension routes win
+// over the tenant default, which wins over the global routes.
+func (r *ParserRouter) Route(tenant, filename string) DocumentParser {
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	if route, ok := r.cfg.Tenants[tenant]; ok {
+		if name := route.ByExtension[ext]; name != "" {
+			return r.parsers[name]
+		}
+		if route.Default != "" {
+			return r.parsers[route.Default]
+		}
+	}
+	if name := r.cfg.ByExtension[ext]; name != "" {
+		return r.parsers[name]
+	}
+	return r.parsers[r.cfg.Default]
+}
+
+// Get returns the parser registered under name
+func (r *ParserRouter) Get(name string) (DocumentParser, bool) {
+	p, ok := r.parsers[name]
+	return p, ok
+}
+
+// Names returns the registered parser names
+func (r *ParserRouter) Names() []string {
+	names := make([]string, 0, len(r.parsers))
+	for name := range r.parsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/config"
+)
+
+func testParserRouter(t *testing.T, cfg *config.ParserConfig) *ParserRouter {
+	t.Helper()
+	router, err := NewParserRouter(cfg, NewMineruParser(nil), NewDocxParser(config.ResultLimits{}))
+	if err != nil {
+		t.Fatalf("Failed to create parser router: %v", err)
+	}
+	return router
+}
+
+func TestParserRouterRoute(t *testing.T) {
+	router := testParserRouter(t, &config.ParserConfig{
+		ParserRoute: config.ParserRoute{
+			Default:     ParserMineru,
+			ByExtension: map[string]string{".docx": ParserDocx},
+		},
+		Tenants: map[string]config.ParserRoute{
+			"ocr-only": {Default: ParserMineru},
+			"mixed":    {ByExtension: map[string]string{".pdf": ParserDocx}},
+		},
+	})
+
+	tests := []struct {
+		tenant   string
+		filename string
+		want     string
+	}{
+		{"default", "contract.pdf", ParserMineru},
+		{"default", "contract.DOCX", ParserDocx},
+		{"ocr-only", "contract.docx", ParserMineru},
+		{"mixed", "contract.pdf", ParserDocx},
+		{"mixed", "contract.docx", ParserDocx},
+	}
+	for _, tt := range tests {
+		if got := router.Route(tt.tenant, tt.filename).Name(); got != tt.want {
+			t.Errorf("Route(%q, %q): expected %s, got %s", tt.tenant, tt.filename, tt.want, got)
+		}
+	}
+}
+
+func TestParserRouterDefaultsToMineru(t *testing.T) {
+	router := testParserRouter(t, &config.ParserConfig{})
+	if got := router.Route("tenant1", "contract.pdf").Name(); got != ParserMineru {
+		t.Errorf("Expected %s, got %s", ParserMineru, got)
+	}
+	if names := router.Names(); len(names) != 2 || names[0] != ParserDocx || names[1] != ParserMineru {
+		t.Errorf("Unexpected parser names: %v", names)
+	}
+}
+
+func TestNewParserRouterUnknownParser(t *testing.T) {
+	cfgs := []*config.ParserConfig{
+		{ParserRoute: config.ParserRoute{Default: "textract"}},
+		{ParserRoute: config.ParserRoute{ByExtension: map[string]string{".pdf": "textract"}}},
+		{Tenants: map[string]config.ParserRoute{"tenant1": {Default: "textract"}}},
+	}
+	for i, cfg := range cfgs {
+		if _, err := NewParserRouter(cfg, NewMineruParser(nil), NewDocxParser(config.ResultLimits{})); err == nil {
+			t.Errorf("Config %d: expected error for unknown parser", i)
+		}
+	}
+}
+
+func TestMineruParser(t *testing.T) {
+	zipData := buildZip(t, map[string]string{
+		"a/middle.json": `{"pdf_info": []}`,
+		"a/full.md":     "# 合同",
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/result.zip":
+			w.Write(zipData)
+		case "/extract/task":
+			var req MineruTaskRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.DataID != "contract-1" {
+				t.Errorf("Expected data_id contract-1, got %s", req.DataID)
+			}
+			response := MineruTaskResponse{}
+			response.Data.TaskID = "task-123"
+			json.NewEncoder(w).Encode(response)
+		case "/extract/task/task-123":
+			response := MineruTaskStatusResponse{}
+			response.Data.State = "converting"
+			response.Data.ExtractProgress.ExtractedPages = 3
+			response.Data.ExtractProgress.TotalPages = 5
+			response.Data.ExtractProgress.StartTime = "2025-01-20 11:43:20"
+			json.NewEncoder(w).Encode(response)
+		default:
+			t.Errorf("Unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	parser := NewMineruParser(NewMineruService(&config.MineruConfig{APIURL: server.URL}))
+	ctx := context.Background()
+
+	if _, err := parser.Submit(ctx, &ParseJob{ContractID: "contract-1"}); err == nil {
+		t.Error("Expected error without a file URL")
+	}
+
+	task, err := parser.Submit(ctx, &ParseJob{ContractID: "contract-1", FileURL: "http://example.com/a.pdf"})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if task.TaskID != "task-123" || task.Result != nil {
+		t.Errorf("Unexpected task: %+v", task)
+	}
+
+	status, err := parser.Poll(ctx, task.TaskID)
+	if err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if status.State != ParseStateRunning || status.Phase != "converting" || status.ExtractedPages != 3 || status.TotalPages != 5 {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+	if want := time.Date(2025, 1, 20, 3, 43, 20, 0, time.UTC); !status.StartedAt.Equal(want) {
+		t.Errorf("Expected start time %v, got %v", want, status.StartedAt)
+	}
+
+	result, err := parser.Fetch(ctx, &ParseStatus{State: ParseStateDone})
+	if err != nil || result != nil {
+		t.Errorf("Expected no result without a result URL, got %v, %v", result, err)
+	}
+
+	// The result's files are streamed from the downloaded ZIP until closed
+	result, err = parser.Fetch(ctx, &ParseStatus{State: ParseStateDone, ResultURL: server.URL + "/result.zip"})
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if result.Data == nil || len(result.Files) != 2 {
+		t.Fatalf("Expected JSON and 2 files, got %+v", result)
+	}
+	read := func(f ResultFile) (string, error) {
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		return string(data), err
+	}
+	if data, err := read(result.Files[0]); err != nil || data != "# 合同" {
+		t.Errorf("Expected full.md content, got %q, %v", data, err)
+	}
+	result.Close()
+	if _, err := read(result.Files[0]); err == nil {
+		t.Error("Expected files to be unreadable after closing the result")
+	}
+}
+
+func TestParseMineruTime(t *testing.T) {
+	if got := parseMineruTime("2025-01-20T11:43:20Z"); !got.Equal(time.Date(2025, 1, 20, 11, 43, 20, 0, time.UTC)) {
+		t.Errorf("Unexpected RFC 3339 time: %v", got)
+	}
+	for _, s := range []string{"", "yesterday"} {
+		if got := parseMineruTime(s); !got.IsZero() {
+			t.Errorf("Expected zero time for %q, got %v", s, got)
+		}
+	}
+}
+
+func TestMineruParseState(t *testing.T) {
+	tests := map[string]string{
+		"pending":      ParseStatePending,
+		"waiting-file": ParseStatePending,
+		"running":      ParseStateRunning,
+		"converting":   ParseStateRunning,
+		"done":         ParseStateDone,
+		"failed":       ParseStateFailed,
+	}
+	for state, want := range tests {
+		if got := mineruParseState(state); got != want {
+			t.Errorf("mineruParseState(%q): expected %s, got %s", state, want, got)
+		}
+	}
+}
+
+func TestDocxParserSubmit(t *testing.T) {
+	docx := buildTestDocx(t, map[string]string{
+		"word/document.xml": testWordHeader + `<w:body><w:p><w:r><w:t>合同正文</w:t></w:r></w:p></w:body></w:document>`,
+	})
+	parser := NewDocxParser(config.ResultLimits{})
+	ctx := context.Background()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		docx.Seek(0, io.SeekStart)
+		io.Copy(w, docx)
+	}))
+	defer server.Close()
+
+	task, err := parser.Submit(ctx, &ParseJob{FileURL: server.URL + "/a.docx"})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if task.Result == nil || task.Result["pdf_info"] == nil {
+		t.Errorf("Expected a result from Submit, got %+v", task)
+	}
+	if _, err := parser.Submit(ctx, &ParseJob{}); err == nil {
+		t.Error("Expected an error without a URL")
+	}
+
+	// Downloads are bounded by the result limits
+	small := NewDocxParser(config.ResultLimits{MaxDownloadBytes: 64})
+	_, err = small.Submit(ctx, &ParseJob{FileURL: server.URL + "/a.docx"})
+	expectLimitError(t, err, LimitDownloadSize)
+
+	if _, err := parser.Poll(ctx, ""); !errors.Is(err, ErrNoPolling) {
+		t.Errorf("Expected ErrNoPolling, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	_ "github.com/lib/pq"
+)
+
+var postgresMigrations = []migration{
+	{
+		version: 1,
+		name:    "create_contracts",
+		sql: `CREATE TABLE contracts (
+			id TEXT PRIMARY KEY,
+			filename TEXT NOT NULL DEFAULT '',
+			tenant TEXT NOT NULL,
+			pdf_url TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL,
+			mineru_task_id TEXT NOT NULL DEFAULT '',
+			json_data JSONB,
+			error_msg TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL
+		);
+		CREATE INDEX idx_contracts_tenant_created ON contracts (tenant, created_at DESC);`,
+	},
+	{
+		version: 2,
+		name:    "index_contracts_tenant_status",
+		sql:     `CREATE INDEX idx_contracts_tenant_status_created ON contracts (tenant, status, created_at DESC);`,
+	},
+	{
+		version: 3,
+		name:    "add_contracts_parser",
+		sql:     `ALTER TABLE contracts ADD COLUMN parser TEXT NOT NULL DEFAULT '';`,
+	},
+	{
+		version: 4,
+		name:    "add_contracts_job_schedule",
+		sql: `ALTER TABLE contracts ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE contracts ADD COLUMN next_run_at TIMESTAMPTZ;
+		CREATE INDEX idx_contracts_status_next_run ON contracts (status, next_run_at);`,
+	},
+	{
+		version: 5,
+		name:    "add_contracts_artifacts",
+		sql:     `ALTER TABLE contracts ADD COLUMN artifacts JSONB;`,
+	},
+	{
+		version: 6,
+		name:    "add_contracts_options",
+		sql:     `ALTER TABLE contracts ADD COLUMN options JSONB;`,
+	},
+	{
+		version: 7,
+		name:    "add_contracts_progress",
+		sql:     `ALTER TABLE contracts ADD COLUMN progress JSONB;`,
+	},
+	{
+		version: 8,
+		name:    "add_contracts_content_hash",
+		sql: `ALTER TABLE contracts ADD COLUMN content_hash TEXT NOT NULL DEFAULT '';
+		ALTER TABLE contracts ADD COLUMN duplicate_of TEXT NOT NULL DEFAULT '';
+		CREATE INDEX idx_contracts_tenant_content_hash ON contracts (tenant, content_hash);`,
+	},
+	{
+		version: 9,
+		name:    "add_contracts_revisions",
+		sql: `ALTER TABLE contracts ADD COLUMN revision INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE contracts ADD COLUMN revisions JSONB;
+		ALTER TABLE contracts ADD COLUMN result_prefix TEXT NOT NULL DEFAULT '';`,
+	},
+	{
+		version: 10,
+		name:    "add_contracts_source_object",
+		sql:     `ALTER TABLE contracts ADD COLUMN source_object TEXT NOT NULL DEFAULT '';`,
+	},
+}
+
+// postgresMigrationLockID is the advisory lock held while migrating, shared
+// by every instance using the database
+const postgresMigrationLockID = 0x636f6e74726163 // "contrac"
+
+// NewPostgresStore connects to PostgreSQL using dsn and applies schema
+// migrations. Parsed JSON data is stored as JSONB.
+func NewPostgresStore(dsn string) (ContractStore, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("postgres store requires a dsn")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+
+	store := &sqlStore{
+		db:            db,
+		rebind:        rebindDollar,
+		noLimit:       "ALL",
+		migrationLock: fmt.Sprintf("SELECT pg_advisory_xact_lock(%d)", postgresMigrationLockID),
+	}
+	if err := store.migrate(postgresMigrations); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	slog.Info("contract store initialized", "driver", StoreDriverPostgres)
+	return store, nil
+}
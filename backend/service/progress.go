@@ -0,0 +1,25 @@
+package service
+
+import (
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/model"
+)
+
+// EstimateRemaining estimates the time left to extract the remaining
+// pages from the page throughput so far. It reports false while the
+// throughput is unknown: before the first page, without a start time, or
+// once every page is extracted and only conversion remains.
+func EstimateRemaining(p *model.Progress, now time.Time) (time.Duration, bool) {
+	if p == nil || p.StartedAt == nil || p.ExtractedPages <= 0 || p.TotalPages <= p.ExtractedPages {
+		return 0, false
+	}
+	elapsed := p.UpdatedAt.Sub(*p.StartedAt)
+	if elapsed <= 0 {
+		return 0, false
+	}
+
+	perPage := elapsed / time.Duration(p.ExtractedPages)
+	remaining := perPage*time.Duration(p.TotalPages-p.ExtractedPages) - now.Sub(p.UpdatedAt)
+	return max(remaining, 0), true
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/model"
+)
+
+func TestEstimateRemaining(t *testing.T) {
+	start := time.Date(2025, 1, 20, 10, 0, 0, 0, time.UTC)
+	reported := start.Add(30 * time.Second)
+
+	tests := []struct {
+		name     string
+		progress *model.Progress
+		now      time.Time
+		want     time.Duration
+		ok       bool
+	}{
+		{"no progress", nil, reported, 0, false},
+		{"no start time", &model.Progress{ExtractedPages: 3, TotalPages: 10, UpdatedAt: reported}, reported, 0, false},
+		{"no pages yet", &model.Progress{TotalPages: 10, StartedAt: &start, UpdatedAt: reported}, reported, 0, false},
+		{"all pages extracted", &model.Progress{ExtractedPages: 10, TotalPages: 10, StartedAt: &start, UpdatedAt: reported}, reported, 0, false},
+		{"at report", &model.Progress{ExtractedPages: 3, TotalPages: 10, StartedAt: &start, UpdatedAt: reported}, reported, 70 * time.Second, true},
+		{"since report", &model.Progress{ExtractedPages: 3, TotalPages: 10, StartedAt: &start, UpdatedAt: reported}, reported.Add(20 * time.Second), 50 * time.Second, true},
+		{"overdue", &model.Progress{ExtractedPages: 3, TotalPages: 10, StartedAt: &start, UpdatedAt: reported}, reported.Add(time.Hour), 0, true},
+	}
+
+	for _, tt := range tests {
+		got, ok := EstimateRemaining(tt.progress, tt.now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: expected %v %v, got %v %v", tt.name, tt.want, tt.ok, got, ok)
+		}
+	}
+}
@@ -0,0 +1,709 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/config"
+	"github.com/AnTengye/contractdiff/backend/model"
+)
+
+// ParseQueue runs parse jobs on a bounded pool of workers. A job is a
+// pending or processing contract with a next run time; the schedule and
+// attempt count are stored on the contract, so jobs survive restarts.
+// Each run is one step: submitting the document when the contract has no
+// task yet, otherwise polling its task once. Jobs are claimed through the
+// store, so replicas sharing a database never run the same job at once.
+type ParseQueue struct {
+	store        ContractStore
+	parsers      *ParserRouter
+	artifacts    *ArtifactStore // Keeps result files; nil discards them
+	workers      int
+	pollInterval time.Duration
+	maxAttempts  int
+	backoff      Backoff // Delay after a failed attempt
+	scanInterval time.Duration
+	lease        time.Duration // How long a claimed job is hidden from other queues
+
+	jobs chan string
+	wake chan struct{} // Dispatches due jobs before the next scan
+
+	mu        sync.Mutex
+	inFlight  map[string]bool               // Contracts being run, reprocessed or cancelled
+	running   map[string]context.CancelFunc // Cancels the step running for a contract
+	delivered map[string]*ParseStatus       // Statuses pushed by the parser, ingested by the next run
+	idle      *sync.Cond                    // Signalled when a contract leaves inFlight
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// QueueStats describes the worker pool
+type QueueStats struct {
+	Workers  int `json:"workers"`
+	InFlight int `json:"in_flight"`
+}
+
+func NewParseQueue(store ContractStore, parsers *ParserRouter, artifacts *ArtifactStore, cfg *config.QueueConfig) *ParseQueue {
+	q := &ParseQueue{
+		store:        store,
+		parsers:      parsers,
+		artifacts:    artifacts,
+		workers:      max(cfg.Workers, 1),
+		pollInterval: time.Duration(max(cfg.PollIntervalSeconds, 1)) * time.Second,
+		maxAttempts:  max(cfg.MaxAttempts, 1),
+		backoff: Backoff{
+			Base: time.Duration(max(cfg.PollIntervalSeconds, 1)) * time.Second,
+			Max:  time.Duration(cfg.MaxBackoffSeconds) * time.Second,
+		},
+		scanInterval: time.Second,
+		lease:        time.Duration(max(cfg.LeaseSeconds, 1)) * time.Second,
+		jobs:         make(chan string),
+		wake:         make(chan struct{}, 1),
+		inFlight:     make(map[string]bool),
+		running:      make(map[string]context.CancelFunc),
+		delivered:    make(map[string]*ParseStatus),
+	}
+	q.idle = sync.NewCond(&q.mu)
+	return q
+}
+
+// Start schedules every unfinished contract that has no next run time,
+// e.g. tasks orphaned by a restart, and starts the workers
+func (q *ParseQueue) Start(ctx context.Context) error {
+	if err := q.resume(); err != nil {
+		return err
+	}
+
+	ctx, q.cancel = context.WithCancel(ctx)
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.worker(ctx)
+	}
+	q.wg.Add(1)
+	go q.schedule(ctx)
+
+	slog.Info("parse queue started",
+		"workers", q.workers,
+		"poll_interval", q.pollInterval,
+		"max_attempts", q.maxAttempts,
+	)
+	return nil
+}
+
+// Stop stops scheduling and waits for running jobs to finish
+func (q *ParseQueue) Stop() {
+	if q.cancel == nil {
+		return
+	}
+	q.cancel()
+	q.wg.Wait()
+	slog.Info("parse queue stopped")
+}
+
+// Stats returns the current worker pool usage
+func (q *ParseQueue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return QueueStats{Workers: q.workers, InFlight: len(q.inFlight)}
+}
+
+func (q *ParseQueue) resume() error {
+	contracts, err := q.store.ListUnfinished()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	resumed := 0
+	for _, c := range contracts {
+		if c.NextRunAt != nil {
+			continue
+		}
+		if _, err := q.store.UpdateJob(c.ID, c.Attempts, now); err != nil {
+			return err
+		}
+		resumed++
+		slog.Info("resuming parse job",
+			"contract_id", c.ID,
+			"status", c.Status,
+			"task_id", c.MineruTaskID,
+		)
+	}
+	if resumed > 0 {
+		slog.Info("parse jobs resumed", "count", resumed)
+	}
+	return nil
+}
+
+func (q *ParseQueue) schedule(ctx context.Context) {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(q.scanInterval)
+	defer ticker.Stop()
+
+	for {
+		q.dispatch(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// dispatch claims due jobs and hands them to idle workers. A claim lasts
+// until the job reschedules or finishes itself; a job whose step never
+// ends, e.g. after a crash or shutdown, runs again when the lease expires.
+func (q *ParseQueue) dispatch(ctx context.Context) {
+	q.mu.Lock()
+	idle := q.workers - len(q.inFlight)
+	q.mu.Unlock()
+	if idle <= 0 {
+		return
+	}
+
+	now := time.Now()
+	due, err := q.store.ListJobs(now, idle)
+	if err != nil {
+		slog.Error("failed to list due parse jobs", "error", err)
+		return
+	}
+
+	for _, c := range due {
+		q.mu.Lock()
+		if q.inFlight[c.ID] {
+			q.mu.Unlock()
+			continue
+		}
+		if len(q.inFlight) >= q.workers {
+			q.mu.Unlock()
+			return
+		}
+		q.inFlight[c.ID] = true
+		q.mu.Unlock()
+
+		claimed, err := q.store.ClaimJob(c.ID, now, now.Add(q.lease))
+		if err != nil {
+			slog.Error("failed to claim parse job",
+				"contract_id", c.ID,
+				"error", err,
+			)
+		}
+		if !claimed {
+			q.release(c.ID)
+			continue
+		}
+
+		select {
+		case q.jobs <- c.ID:
+		case <-ctx.Done():
+			q.release(c.ID)
+			return
+		}
+	}
+}
+
+func (q *ParseQueue) worker(ctx context.Context) {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-q.jobs:
+			jobCtx, cancel := context.WithCancel(ctx)
+			q.mu.Lock()
+			q.running[id] = cancel
+			q.mu.Unlock()
+
+			q.run(jobCtx, id)
+			cancel()
+			q.release(id)
+		}
+	}
+}
+
+// release ends a contract's run or delivery
+func (q *ParseQueue) release(id string) {
+	q.mu.Lock()
+	delete(q.inFlight, id)
+	delete(q.running, id)
+	q.mu.Unlock()
+	q.idle.Broadcast()
+}
+
+// run performs one step of a contract's parse job
+func (q *ParseQueue) run(ctx context.Context, id string) {
+	q.mu.Lock()
+	delivered := q.delivered[id]
+	delete(q.delivered, id)
+	q.mu.Unlock()
+
+	contract, err := q.store.Get(id)
+	if err != nil {
+		slog.Error("failed to load contract for parse job",
+			"contract_id", id,
+			"error", err,
+		)
+		return
+	}
+	if contract == nil || !isUnfinished(contract.Status) {
+		return
+	}
+
+	parser, ok := q.parserFor(contract)
+	if !ok {
+		return
+	}
+
+	if contract.MineruTaskID == "" {
+		q.submit(ctx, contract, parser, &ParseJob{
+			ContractID: contract.ID,
+			Tenant:     contract.Tenant,
+			Filename:   contract.Filename,
+			FileURL:    contract.PDFURL,
+			Options:    contract.Options,
+		})
+		return
+	}
+	if delivered != nil {
+		slog.Info("ingesting delivered parse status",
+			"contract_id", contract.ID,
+			"parser", parser.Name(),
+			"state", delivered.State,
+		)
+		if q.ingest(ctx, contract, parser, delivered, contract.Attempts+1) {
+			return
+		}
+	}
+	q.poll(ctx, contract, parser)
+}
+
+// parserFor returns the parser that handles the contract, failing the
+// contract if its parser is no longer registered
+func (q *ParseQueue) parserFor(contract *model.Contract) (DocumentParser, bool) {
+	parser, ok := q.parsers.Get(contract.Parser)
+	if !ok {
+		if contract.Parser != "" {
+			q.updateStatus(contract.ID, model.StatusFailed, "Unknown parser: "+contract.Parser)
+			return nil, false
+		}
+		// Contracts created before parsers were recorded
+		parser = q.parsers.Route(contract.Tenant, contract.Filename)
+	}
+	return parser, true
+}
+
+// Cancel stops a contract's parse job: a running step is aborted through
+// its context and the contract is marked cancelled, so that it is never
+// scheduled again. It reports false when the contract had already finished
+// or does not exist. The parser's remote task, if any, is left to finish
+// on its own; its result is ignored.
+func (q *ParseQueue) Cancel(id string) (bool, error) {
+	q.mu.Lock()
+	if cancel, ok := q.running[id]; ok {
+		cancel()
+	}
+	for q.inFlight[id] {
+		q.idle.Wait()
+	}
+	q.inFlight[id] = true
+	delete(q.delivered, id)
+	q.mu.Unlock()
+	defer q.release(id)
+
+	contract, err := q.store.Get(id)
+	if err != nil {
+		return false, err
+	}
+	if contract == nil || !isUnfinished(contract.Status) {
+		return false, nil
+	}
+	// A replica sharing the store may finish the contract meanwhile
+	cancelled, err := q.store.UpdateStatus(id, model.StatusCancelled, "")
+	if err != nil || !cancelled {
+		return false, err
+	}
+	slog.Info("parse job cancelled",
+		"contract_id", id,
+		"task_id", contract.MineruTaskID,
+	)
+	return true, nil
+}
+
+// Reprocess schedules a finished contract to be parsed again from fileURL,
+// keeping its current result as a revision. The queue's workers submit it.
+// It fails with ErrContractBusy while the contract is processed.
+func (q *ParseQueue) Reprocess(id string, parser DocumentParser, fileURL string, opts *model.ExtractOptions) error {
+	q.mu.Lock()
+	for q.inFlight[id] {
+		q.idle.Wait()
+	}
+	q.inFlight[id] = true
+	q.mu.Unlock()
+	defer q.release(id)
+
+	contract, err := q.store.Get(id)
+	if err != nil {
+		return err
+	}
+	if contract == nil {
+		return fmt.Errorf("contract %s not found", id)
+	}
+	if isUnfinished(contract.Status) {
+		return ErrContractBusy
+	}
+
+	now := time.Now()
+	archiveRevision(contract)
+	contract.Revision = CurrentRevision(contract) + 1
+	contract.Status = model.StatusPending
+	contract.PDFURL = fileURL
+	contract.Parser = parser.Name()
+	contract.Options = opts
+	contract.MineruTaskID = ""
+	contract.JSONData = nil
+	contract.Artifacts = nil
+	contract.ResultPrefix = ""
+	contract.Progress = nil
+	contract.ErrorMsg = ""
+	contract.Attempts = 0
+	contract.NextRunAt = &now
+	if err := q.store.Save(contract); err != nil {
+		return err
+	}
+
+	slog.Info("reprocessing contract",
+		"contract_id", id,
+		"revision", contract.Revision,
+		"parser", parser.Name(),
+		"options", opts,
+	)
+	return nil
+}
+
+// Deliver records a task status pushed by the parser, e.g. a MinerU
+// callback, and makes the job due at once. A worker then ingests it exactly
+// as if a poll had returned it, so the caller does not wait for the result
+// to be fetched. It reports false when the contract had already finished,
+// does not exist or has a delivery waiting. Deliveries are kept in memory;
+// after a restart the job's next poll finds the result instead.
+func (q *ParseQueue) Deliver(id string, status *ParseStatus) (bool, error) {
+	contract, err := q.store.Get(id)
+	if err != nil {
+		return false, err
+	}
+	if contract == nil || !isUnfinished(contract.Status) {
+		return false, nil
+	}
+
+	q.mu.Lock()
+	if _, ok := q.delivered[id]; ok {
+		q.mu.Unlock()
+		return false, nil
+	}
+	q.delivered[id] = status
+	q.mu.Unlock()
+
+	scheduled, err := q.store.UpdateJob(id, contract.Attempts, time.Now())
+	if err != nil || !scheduled {
+		q.mu.Lock()
+		delete(q.delivered, id)
+		q.mu.Unlock()
+		return false, err
+	}
+	slog.Info("parse status delivered",
+		"contract_id", id,
+		"state", status.State,
+	)
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return true, nil
+}
+
+// submit hands a document to its parser. Parsers that finish during Submit
+// complete the contract immediately; otherwise polling the task is
+// scheduled. Transient failures leave the contract pending and schedule
+// another submission.
+func (q *ParseQueue) submit(ctx context.Context, contract *model.Contract, parser DocumentParser, job *ParseJob) {
+	slog.Info("submitting parse job",
+		"contract_id", contract.ID,
+		"parser", parser.Name(),
+	)
+
+	task, err := parser.Submit(ctx, job)
+	if err != nil {
+		if ctx.Err() != nil {
+			return // Cancelled; the job runs again when next due
+		}
+		attempt := contract.Attempts + 1
+		if IsTransient(err) && attempt < q.maxAttempts {
+			slog.Warn("parse job submission failed, retrying",
+				"contract_id", contract.ID,
+				"parser", parser.Name(),
+				"attempt", attempt,
+				"error", err,
+			)
+			q.reschedule(contract, attempt, q.backoff.Delay(attempt))
+			return
+		}
+		slog.Error("failed to submit parse job",
+			"contract_id", contract.ID,
+			"parser", parser.Name(),
+			"error", err,
+		)
+		q.updateStatus(contract.ID, model.StatusFailed, err.Error())
+		return
+	}
+
+	if task.Result != nil {
+		slog.Info("document parsed",
+			"contract_id", contract.ID,
+			"parser", parser.Name(),
+		)
+		if err := q.store.UpdateJSONData(contract.ID, task.Result); err != nil {
+			slog.Error("failed to save JSON data",
+				"contract_id", contract.ID,
+				"error", err,
+			)
+		}
+		return
+	}
+
+	slog.Info("parse task created",
+		"contract_id", contract.ID,
+		"parser", parser.Name(),
+		"task_id", task.TaskID,
+	)
+
+	// Only the task is written, so that a contract cancelled or deleted
+	// while the parser was busy stays that way
+	updated, err := q.store.UpdateTask(contract.ID, parser.Name(), task.TaskID, time.Now().Add(q.pollInterval))
+	if err != nil {
+		slog.Error("failed to save parse task ID",
+			"contract_id", contract.ID,
+			"task_id", task.TaskID,
+			"error", err,
+		)
+		return
+	}
+	if !updated {
+		slog.Info("contract finished during submission, task ignored",
+			"contract_id", contract.ID,
+			"task_id", task.TaskID,
+		)
+	}
+}
+
+// poll checks a task once and finishes the contract or schedules the next poll
+func (q *ParseQueue) poll(ctx context.Context, contract *model.Contract, parser DocumentParser) {
+	attempt := contract.Attempts + 1
+
+	status, err := parser.Poll(ctx, contract.MineruTaskID)
+	if err != nil {
+		if ctx.Err() != nil {
+			return // Cancelled; the job runs again when next due
+		}
+		if !IsTransient(err) {
+			slog.Error("poll failed",
+				"contract_id", contract.ID,
+				"attempt", attempt,
+				"error", err,
+			)
+			q.updateStatus(contract.ID, model.StatusFailed, err.Error())
+			return
+		}
+		slog.Warn("poll attempt failed",
+			"contract_id", contract.ID,
+			"attempt", attempt,
+			"error", err,
+		)
+		q.reschedule(contract, attempt, q.backoff.Delay(attempt))
+		return
+	}
+
+	slog.Debug("poll status",
+		"contract_id", contract.ID,
+		"attempt", attempt,
+		"state", status.State,
+		"result_url", status.ResultURL,
+	)
+
+	if q.ingest(ctx, contract, parser, status, attempt) {
+		return
+	}
+	q.recordProgress(contract, status)
+
+	q.reschedule(contract, attempt, q.pollInterval)
+}
+
+// ingest finishes the contract of a done or failed task: a done task's
+// result is fetched and stored with its artifacts. It reports false for
+// unfinished tasks, which the caller schedules.
+func (q *ParseQueue) ingest(ctx context.Context, contract *model.Contract, parser DocumentParser, status *ParseStatus, attempt int) bool {
+	switch status.State {
+	case ParseStateDone:
+		slog.Info("fetching parse result",
+			"contract_id", contract.ID,
+			"result_url", status.ResultURL,
+		)
+		result, err := parser.Fetch(ctx, status)
+		if err != nil {
+			if ctx.Err() != nil {
+				return true // Cancelled; the job runs again when next due
+			}
+			slog.Error("failed to fetch/extract JSON",
+				"contract_id", contract.ID,
+				"error", err,
+			)
+			q.updateStatus(contract.ID, model.StatusFailed, "Failed to fetch JSON: "+err.Error())
+			return true
+		}
+		if result == nil {
+			slog.Info("task completed without result",
+				"contract_id", contract.ID,
+			)
+			q.updateStatus(contract.ID, model.StatusCompleted, "")
+			return true
+		}
+		defer result.Close()
+		slog.Info("JSON extracted successfully",
+			"contract_id", contract.ID,
+			"keys", mapKeys(result.Data),
+			"files", len(result.Files),
+		)
+		if !q.saveArtifacts(ctx, contract, result.Files) {
+			q.reschedule(contract, attempt, q.backoff.Delay(attempt))
+			return true
+		}
+		if err := q.store.UpdateJSONData(contract.ID, result.Data); err != nil {
+			slog.Error("failed to save JSON data",
+				"contract_id", contract.ID,
+				"error", err,
+			)
+			q.reschedule(contract, attempt, q.backoff.Delay(attempt))
+		}
+		return true
+	case ParseStateFailed:
+		slog.Error("parse task failed",
+			"contract_id", contract.ID,
+			"parser", parser.Name(),
+			"error_msg", status.ErrorMsg,
+		)
+		q.updateStatus(contract.ID, model.StatusFailed, status.ErrorMsg)
+		return true
+	}
+	return false
+}
+
+// recordProgress stores the progress of an unfinished task. Without a start
+// time from the parser, extraction counts as started when the task was
+// first seen running.
+func (q *ParseQueue) recordProgress(contract *model.Contract, status *ParseStatus) {
+	now := time.Now()
+	progress := &model.Progress{
+		State:          cmp.Or(status.Phase, status.State),
+		ExtractedPages: status.ExtractedPages,
+		TotalPages:     status.TotalPages,
+		UpdatedAt:      now,
+	}
+	switch {
+	case !status.StartedAt.IsZero():
+		startedAt := status.StartedAt
+		progress.StartedAt = &startedAt
+	case contract.Progress != nil && contract.Progress.StartedAt != nil:
+		progress.StartedAt = contract.Progress.StartedAt
+	case status.State == ParseStateRunning:
+		progress.StartedAt = &now
+	}
+
+	if status.TotalPages > 0 {
+		slog.Debug("extraction progress",
+			"contract_id", contract.ID,
+			"state", progress.State,
+			"extracted_pages", status.ExtractedPages,
+			"total_pages", status.TotalPages,
+		)
+	}
+	if err := q.store.UpdateProgress(contract.ID, progress); err != nil {
+		slog.Error("failed to save parse progress",
+			"contract_id", contract.ID,
+			"error", err,
+		)
+	}
+}
+
+// saveArtifacts stores result files and records their manifest on the
+// contract. It reports false when the files could not be stored, so that
+// the result is fetched again later.
+func (q *ParseQueue) saveArtifacts(ctx context.Context, contract *model.Contract, files []ResultFile) bool {
+	if q.artifacts == nil || len(files) == 0 {
+		return true
+	}
+	artifacts, err := q.artifacts.Save(ctx, contract, files)
+	if err != nil {
+		slog.Warn("failed to store result artifacts",
+			"contract_id", contract.ID,
+			"error", err,
+		)
+		return false
+	}
+	if err := q.store.UpdateArtifacts(contract.ID, artifacts); err != nil {
+		slog.Error("failed to save artifact manifest",
+			"contract_id", contract.ID,
+			"error", err,
+		)
+		return false
+	}
+	slog.Info("result artifacts stored",
+		"contract_id", contract.ID,
+		"count", len(artifacts),
+	)
+	return true
+}
+
+// reschedule records an unfinished attempt and runs the job again after
+// delay, failing the contract once the attempts are used up
+func (q *ParseQueue) reschedule(contract *model.Contract, attempt int, delay time.Duration) {
+	if attempt >= q.maxAttempts {
+		slog.Error("task polling timeout",
+			"contract_id", contract.ID,
+			"attempts", attempt,
+		)
+		q.updateStatus(contract.ID, model.StatusFailed, "Task polling timeout")
+		return
+	}
+	if _, err := q.store.UpdateJob(contract.ID, attempt, time.Now().Add(delay)); err != nil {
+		slog.Error("failed to reschedule parse job",
+			"contract_id", contract.ID,
+			"error", err,
+		)
+	}
+}
+
+// updateStatus updates the status of an unfinished contract, logging
+// failures. Contracts finished meanwhile, e.g. cancelled, stay unchanged.
+func (q *ParseQueue) updateStatus(id, status, errMsg string) {
+	if _, err := q.store.UpdateStatus(id, status, errMsg); err != nil {
+		slog.Error("failed to update contract status",
+			"contract_id", id,
+			"status", status,
+			"error", err,
+		)
+	}
+}
+
+func mapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
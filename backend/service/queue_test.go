@@ -0,0 +1,648 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/config"
+	"github.com/AnTengye/contractdiff/backend/model"
+)
+
+// fakeParser is a DocumentParser returning canned responses
+type fakeParser struct {
+	mu        sync.Mutex
+	task      *ParseTask
+	submitErr error
+	statuses  []*ParseStatus // Returned by successive polls
+	pollErr   error
+	result    *ParseResult
+	submits   int
+	polls     int
+	blocking  chan struct{} // When set, Poll signals it and blocks until cancelled
+	gate      chan struct{} // When set, Submit receives from it once when it starts and again before returning
+}
+
+func (p *fakeParser) Name() string { return "fake" }
+
+func (p *fakeParser) Submit(ctx context.Context, job *ParseJob) (*ParseTask, error) {
+	if p.gate != nil {
+		p.gate <- struct{}{}
+		p.gate <- struct{}{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.submits++
+	return p.task, p.submitErr
+}
+
+func (p *fakeParser) Poll(ctx context.Context, taskID string) (*ParseStatus, error) {
+	if p.blocking != nil {
+		select {
+		case p.blocking <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pollErr != nil {
+		return nil, p.pollErr
+	}
+	status := p.statuses[min(p.polls, len(p.statuses)-1)]
+	p.polls++
+	return status, nil
+}
+
+func (p *fakeParser) Fetch(ctx context.Context, status *ParseStatus) (*ParseResult, error) {
+	return p.result, nil
+}
+
+func newTestParseQueue(t *testing.T, store ContractStore, parser *fakeParser, maxAttempts int) *ParseQueue {
+	t.Helper()
+	router, err := NewParserRouter(&config.ParserConfig{
+		ParserRoute: config.ParserRoute{Default: parser.Name()},
+	}, parser)
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+	q := NewParseQueue(store, router, nil, &config.QueueConfig{Workers: 2, MaxAttempts: maxAttempts})
+	q.pollInterval = time.Millisecond
+	q.backoff = Backoff{Base: time.Millisecond, Max: time.Millisecond}
+	q.scanInterval = time.Millisecond
+	return q
+}
+
+// waitForStatus waits until the contract reaches status
+func waitForStatus(t *testing.T, store ContractStore, id, status string) *model.Contract {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		contract, _ := store.Get(id)
+		if contract != nil && contract.Status == status {
+			return contract
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for status %s, got %+v", status, contract)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestParseQueueSubmit(t *testing.T) {
+	store := newTestStore(0)
+	result := map[string]interface{}{"pdf_info": []interface{}{}}
+
+	tests := []struct {
+		name   string
+		parser *fakeParser
+		want   string
+	}{
+		{"sync", &fakeParser{task: &ParseTask{Result: result}}, model.StatusCompleted},
+		{"error", &fakeParser{submitErr: errors.New("boom")}, model.StatusFailed},
+		{"async", &fakeParser{task: &ParseTask{TaskID: "task-1"}}, model.StatusProcessing},
+	}
+
+	for _, tt := range tests {
+		id := "submit-" + tt.name
+		contract := &model.Contract{ID: id, Tenant: "tenant1", Status: model.StatusPending, CreatedAt: time.Now()}
+		store.Save(contract)
+
+		q := newTestParseQueue(t, store, tt.parser, 3)
+		q.submit(context.Background(), contract, tt.parser, &ParseJob{ContractID: id})
+		if c := mustGet(t, store, id); c.Status != tt.want {
+			t.Errorf("%s: expected status %s, got %s", tt.name, tt.want, c.Status)
+		}
+	}
+
+	if c := mustGet(t, store, "submit-error"); c.ErrorMsg != "boom" {
+		t.Errorf("Expected error message 'boom', got '%s'", c.ErrorMsg)
+	}
+	async := mustGet(t, store, "submit-async")
+	if async.MineruTaskID != "task-1" || async.Parser != "fake" || async.NextRunAt == nil {
+		t.Errorf("Expected scheduled task, got %+v", async)
+	}
+}
+
+func TestParseQueueSubmitRacesCancel(t *testing.T) {
+	result := map[string]interface{}{"pdf_info": []interface{}{}}
+	tests := []struct {
+		name   string
+		task   *ParseTask
+		delete bool
+	}{
+		{"async-cancelled", &ParseTask{TaskID: "task-1"}, false},
+		{"async-deleted", &ParseTask{TaskID: "task-1"}, true},
+		{"sync-cancelled", &ParseTask{Result: result}, false},
+		{"sync-deleted", &ParseTask{Result: result}, true},
+	}
+
+	for _, tt := range tests {
+		store := newTestStore(0)
+		contract := &model.Contract{ID: tt.name, Tenant: "tenant1", Status: model.StatusPending, CreatedAt: time.Now()}
+		store.Save(contract)
+
+		parser := &fakeParser{task: tt.task, gate: make(chan struct{})}
+		q := newTestParseQueue(t, store, parser, 3)
+		done := make(chan struct{})
+		go func() {
+			q.submit(context.Background(), contract, parser, &ParseJob{ContractID: tt.name})
+			close(done)
+		}()
+
+		<-parser.gate
+		if tt.delete {
+			store.Delete(tt.name)
+		} else if cancelled, err := q.Cancel(tt.name); !cancelled || err != nil {
+			t.Fatalf("%s: expected the pending contract to be cancelled, got %v, %v", tt.name, cancelled, err)
+		}
+		<-parser.gate
+		<-done
+
+		c := mustGet(t, store, tt.name)
+		switch {
+		case tt.delete && c != nil:
+			t.Errorf("%s: expected the deleted contract to stay deleted, got %+v", tt.name, c)
+		case !tt.delete && (c.Status != model.StatusCancelled || c.MineruTaskID != "" || c.JSONData != nil):
+			t.Errorf("%s: expected the contract to stay cancelled without a task or result, got %+v", tt.name, c)
+		}
+	}
+}
+
+func TestParseQueuesSharingStoreClaimJobs(t *testing.T) {
+	store := newTestStore(0)
+	now := time.Now()
+	store.Save(&model.Contract{ID: "shared", Tenant: "tenant1", Status: model.StatusPending, CreatedAt: now, NextRunAt: &now})
+
+	parser := &fakeParser{
+		task:     &ParseTask{TaskID: "task-1"},
+		statuses: []*ParseStatus{{State: ParseStateRunning}},
+		gate:     make(chan struct{}),
+	}
+	stopped := make(chan struct{})
+	defer close(stopped)
+	for i := 0; i < 2; i++ {
+		q := newTestParseQueue(t, store, parser, 100)
+		if err := q.Start(context.Background()); err != nil {
+			t.Fatalf("Failed to start queue: %v", err)
+		}
+		defer q.Stop()
+	}
+	defer func() {
+		// Release submissions still at the gate, so that the queues stop
+		go func() {
+			for {
+				select {
+				case <-parser.gate:
+				case <-stopped:
+					return
+				}
+			}
+		}()
+	}()
+
+	// Give the other queue time to start a second submission, which would
+	// block on the gate like the first
+	<-parser.gate
+	time.Sleep(50 * time.Millisecond)
+	<-parser.gate
+	select {
+	case <-parser.gate:
+		t.Fatal("Expected the job to be submitted by one queue only")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	waitForStatus(t, store, "shared", model.StatusProcessing)
+	parser.mu.Lock()
+	defer parser.mu.Unlock()
+	if parser.submits != 1 {
+		t.Errorf("Expected 1 submission, got %d", parser.submits)
+	}
+}
+
+func TestParseQueuePoll(t *testing.T) {
+	store := newTestStore(0)
+	result := map[string]interface{}{"pdf_info": []interface{}{}}
+
+	tests := []struct {
+		name     string
+		statuses []*ParseStatus
+		want     string
+		errMsg   string
+	}{
+		{"done", []*ParseStatus{{State: ParseStateDone, ResultURL: "http://example.com/result.zip"}}, model.StatusCompleted, ""},
+		{"failed", []*ParseStatus{{State: ParseStateFailed, ErrorMsg: "unsupported file"}}, model.StatusFailed, "unsupported file"},
+		{"running", []*ParseStatus{{State: ParseStateRunning, Phase: "converting", ExtractedPages: 2, TotalPages: 8}}, model.StatusProcessing, ""},
+	}
+
+	for _, tt := range tests {
+		id := "poll-" + tt.name
+		store.Save(&model.Contract{ID: id, Tenant: "tenant1", Status: model.StatusProcessing, Parser: "fake", MineruTaskID: "task-1", CreatedAt: time.Now()})
+
+		q := newTestParseQueue(t, store, &fakeParser{statuses: tt.statuses, result: &ParseResult{Data: result}}, 3)
+		q.run(context.Background(), id)
+
+		c := mustGet(t, store, id)
+		if c.Status != tt.want || c.ErrorMsg != tt.errMsg {
+			t.Errorf("%s: expected %s '%s', got %s '%s'", tt.name, tt.want, tt.errMsg, c.Status, c.ErrorMsg)
+		}
+	}
+
+	if c := mustGet(t, store, "poll-done"); c.JSONData == nil {
+		t.Error("Expected JSON data after task completed")
+	}
+	running := mustGet(t, store, "poll-running")
+	if running.Attempts != 1 || running.NextRunAt == nil {
+		t.Errorf("Expected rescheduled job after 1 attempt, got %d %v", running.Attempts, running.NextRunAt)
+	}
+	if p := running.Progress; p == nil || p.State != "converting" || p.ExtractedPages != 2 || p.TotalPages != 8 || p.StartedAt == nil {
+		t.Errorf("Expected recorded progress, got %+v", p)
+	}
+}
+
+func TestParseQueueRecordsProgress(t *testing.T) {
+	store := newTestStore(0)
+	store.Save(&model.Contract{ID: "progress", Tenant: "tenant1", Status: model.StatusProcessing, Parser: "fake", MineruTaskID: "task-1", CreatedAt: time.Now()})
+
+	parser := &fakeParser{statuses: []*ParseStatus{
+		{State: ParseStatePending, Phase: "waiting-file"},
+		{State: ParseStateRunning, Phase: "running", ExtractedPages: 1, TotalPages: 4},
+		{State: ParseStateRunning, Phase: "running", ExtractedPages: 3, TotalPages: 4},
+	}}
+	q := newTestParseQueue(t, store, parser, 10)
+
+	q.run(context.Background(), "progress")
+	if p := mustGet(t, store, "progress").Progress; p == nil || p.State != "waiting-file" || p.StartedAt != nil {
+		t.Fatalf("Expected waiting progress without start time, got %+v", p)
+	}
+
+	// The start time is the first running poll's unless the parser knows it
+	q.run(context.Background(), "progress")
+	first := mustGet(t, store, "progress").Progress
+	if first.StartedAt == nil || first.ExtractedPages != 1 {
+		t.Fatalf("Expected running progress with start time, got %+v", first)
+	}
+	q.run(context.Background(), "progress")
+	second := mustGet(t, store, "progress").Progress
+	if second.ExtractedPages != 3 || !second.StartedAt.Equal(*first.StartedAt) {
+		t.Errorf("Expected start time to be kept, got %+v", second)
+	}
+}
+
+func TestParseQueueStoresArtifacts(t *testing.T) {
+	store := newTestStore(0)
+	store.Save(&model.Contract{ID: "artifacts", Tenant: "tenant1", Status: model.StatusProcessing, Parser: "fake", MineruTaskID: "task-1", CreatedAt: time.Now()})
+
+	parser := &fakeParser{
+		statuses: []*ParseStatus{{State: ParseStateDone, ResultURL: "http://example.com/result.zip"}},
+		result: &ParseResult{
+			Data:  map[string]interface{}{"pdf_info": []interface{}{}},
+			Files: []ResultFile{memoryFile("full.md", "# 合同")},
+		},
+	}
+	objects := newMemoryObjects()
+	objects.err = errors.New("storage unavailable")
+
+	q := newTestParseQueue(t, store, parser, 3)
+	q.artifacts = NewArtifactStore(objects)
+
+	// A storage failure leaves the result to be fetched again
+	q.run(context.Background(), "artifacts")
+	if c := mustGet(t, store, "artifacts"); c.Status != model.StatusProcessing || c.Attempts != 1 || c.JSONData != nil {
+		t.Errorf("Expected rescheduled job without result, got %+v", c)
+	}
+
+	objects.err = nil
+	q.run(context.Background(), "artifacts")
+	c := mustGet(t, store, "artifacts")
+	if c.Status != model.StatusCompleted || c.JSONData == nil {
+		t.Fatalf("Expected completed contract, got %+v", c)
+	}
+	if len(c.Artifacts) != 1 || c.Artifacts[0].Path != "full.md" || c.Artifacts[0].Size != int64(len("# 合同")) {
+		t.Errorf("Unexpected artifact manifest: %+v", c.Artifacts)
+	}
+	if _, ok := objects.objects["tenant1/artifacts/result/full.md"]; !ok {
+		t.Errorf("Expected artifact under the contract prefix, got %v", objects.names())
+	}
+}
+
+// failingJSONStore is a ContractStore whose UpdateJSONData fails with err
+// when set
+type failingJSONStore struct {
+	ContractStore
+	err error
+}
+
+func (s *failingJSONStore) UpdateJSONData(id string, jsonData any) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.ContractStore.UpdateJSONData(id, jsonData)
+}
+
+func TestParseQueueRetriesFailedSave(t *testing.T) {
+	store := &failingJSONStore{ContractStore: newTestStore(0), err: errors.New("database is locked")}
+	store.Save(&model.Contract{ID: "save-json", Tenant: "tenant1", Status: model.StatusProcessing, Parser: "fake", MineruTaskID: "task-1", CreatedAt: time.Now()})
+
+	parser := &fakeParser{
+		statuses: []*ParseStatus{{State: ParseStateDone, ResultURL: "http://example.com/result.zip"}},
+		result:   &ParseResult{Data: map[string]interface{}{"pdf_info": []interface{}{}}},
+	}
+	q := newTestParseQueue(t, store, parser, 2)
+
+	// A failed save counts as an attempt and the result is fetched again
+	q.run(context.Background(), "save-json")
+	if c := mustGet(t, store, "save-json"); c.Status != model.StatusProcessing || c.Attempts != 1 || c.NextRunAt == nil {
+		t.Errorf("Expected rescheduled job after 1 attempt, got %+v", c)
+	}
+
+	q.run(context.Background(), "save-json")
+	if c := mustGet(t, store, "save-json"); c.Status != model.StatusFailed {
+		t.Errorf("Expected failed contract after max attempts, got %+v", c)
+	}
+}
+
+func TestParseQueueDeliver(t *testing.T) {
+	store := newTestStore(0)
+	later := time.Now().Add(time.Hour)
+	store.Save(&model.Contract{ID: "deliver", Tenant: "tenant1", Status: model.StatusProcessing, Parser: "fake", MineruTaskID: "task-1", NextRunAt: &later, CreatedAt: time.Now()})
+
+	parser := &fakeParser{
+		statuses: []*ParseStatus{{State: ParseStateRunning}},
+		result:   &ParseResult{Data: map[string]interface{}{"pdf_info": []interface{}{}}},
+	}
+	q := newTestParseQueue(t, store, parser, 3)
+
+	// Delivering only records the status and makes the job due
+	delivered, err := q.Deliver("deliver", &ParseStatus{State: ParseStateDone, ResultURL: "http://example.com/result.zip"})
+	if !delivered || err != nil {
+		t.Fatalf("Expected delivery, got %v %v", delivered, err)
+	}
+	c := mustGet(t, store, "deliver")
+	if c.Status != model.StatusProcessing || c.NextRunAt == nil || c.NextRunAt.After(time.Now()) {
+		t.Errorf("Expected a processing contract due now, got %+v", c)
+	}
+	if delivered, _ := q.Deliver("deliver", &ParseStatus{State: ParseStateDone}); delivered {
+		t.Error("Expected a replay to be skipped while the first delivery waits")
+	}
+
+	// A worker ingests the delivered status instead of polling
+	if err := q.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start queue: %v", err)
+	}
+	defer q.Stop()
+	if c := waitForStatus(t, store, "deliver", model.StatusCompleted); c.JSONData == nil {
+		t.Errorf("Expected completed contract with result, got %+v", c)
+	}
+	parser.mu.Lock()
+	polls := parser.polls
+	parser.mu.Unlock()
+	if polls != 0 {
+		t.Errorf("Expected no polls, got %d", polls)
+	}
+
+	// Later deliveries, e.g. a replayed callback, are ignored
+	delivered, err = q.Deliver("deliver", &ParseStatus{State: ParseStateFailed, ErrorMsg: "late"})
+	if delivered || err != nil {
+		t.Errorf("Expected finished contract to be skipped, got %v %v", delivered, err)
+	}
+	if c := mustGet(t, store, "deliver"); c.Status != model.StatusCompleted {
+		t.Errorf("Expected status %s, got %s", model.StatusCompleted, c.Status)
+	}
+	if delivered, _ := q.Deliver("missing", &ParseStatus{State: ParseStateDone}); delivered {
+		t.Error("Expected missing contract to be skipped")
+	}
+}
+
+func TestParseQueueCancel(t *testing.T) {
+	store := newTestStore(0)
+	now := time.Now()
+	store.Save(&model.Contract{ID: "cancel-running", Tenant: "tenant1", Status: model.StatusProcessing, MineruTaskID: "task-1", NextRunAt: &now, CreatedAt: now})
+	store.Save(&model.Contract{ID: "cancel-done", Tenant: "tenant1", Status: model.StatusCompleted, CreatedAt: now})
+
+	parser := &fakeParser{blocking: make(chan struct{}, 1)}
+	q := newTestParseQueue(t, store, parser, 3)
+	if err := q.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start queue: %v", err)
+	}
+	defer q.Stop()
+
+	select {
+	case <-parser.blocking:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the poll to start")
+	}
+
+	cancelled, err := q.Cancel("cancel-running")
+	if err != nil || !cancelled {
+		t.Fatalf("Expected the running job to be cancelled, got %v, %v", cancelled, err)
+	}
+	contract := mustGet(t, store, "cancel-running")
+	if contract.Status != model.StatusCancelled || contract.ErrorMsg != "" {
+		t.Errorf("Expected cancelled contract without error, got %s '%s'", contract.Status, contract.ErrorMsg)
+	}
+	if stats := q.Stats(); stats.InFlight != 0 {
+		t.Errorf("Expected no job in flight after cancelling, got %d", stats.InFlight)
+	}
+
+	// Finished contracts cannot be cancelled and late results are ignored
+	for _, id := range []string{"cancel-running", "cancel-done", "non-existent"} {
+		if cancelled, err := q.Cancel(id); cancelled || err != nil {
+			t.Errorf("%s: expected nothing to cancel, got %v, %v", id, cancelled, err)
+		}
+	}
+	if delivered, _ := q.Deliver("cancel-running", &ParseStatus{State: ParseStateDone}); delivered {
+		t.Error("Expected a result for a cancelled contract to be ignored")
+	}
+	// As are the writes of a worker that missed the cancellation, e.g. on
+	// another replica
+	q.updateStatus("cancel-running", model.StatusFailed, "late failure")
+	q.reschedule(contract, 1, time.Hour)
+	if c := mustGet(t, store, "cancel-running"); c.Status != model.StatusCancelled || c.ErrorMsg != "" || c.Attempts != 0 {
+		t.Errorf("Expected contract to stay cancelled, got %+v", c)
+	}
+}
+
+func TestParseQueueReprocess(t *testing.T) {
+	store := newTestStore(0)
+	completedAt := time.Now().Add(-time.Hour)
+	result := map[string]interface{}{"pdf_info": []interface{}{}}
+	store.Save(&model.Contract{
+		ID:        "reprocess-test",
+		Tenant:    "tenant1",
+		Filename:  "a.pdf",
+		Status:    model.StatusCompleted,
+		Parser:    "fake",
+		Options:   &model.ExtractOptions{ModelVersion: "pipeline"},
+		JSONData:  result,
+		Artifacts: []model.Artifact{{Path: "full.md"}},
+		CreatedAt: completedAt,
+	})
+
+	parser := &fakeParser{task: &ParseTask{TaskID: "task-2"}}
+	q := newTestParseQueue(t, store, parser, 3)
+	vlm := &model.ExtractOptions{ModelVersion: "vlm"}
+
+	if err := q.Reprocess("reprocess-test", parser, "http://example.com/a.pdf", vlm); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The parser is not called; the queue's workers submit the contract
+	contract := mustGet(t, store, "reprocess-test")
+	if contract.Status != model.StatusPending || contract.NextRunAt == nil || parser.submits != 0 {
+		t.Errorf("Expected a pending contract due for submission, got %+v after %d submits", contract, parser.submits)
+	}
+	if contract.Revision != 2 || contract.MineruTaskID != "" || contract.JSONData != nil || contract.Artifacts != nil {
+		t.Errorf("Expected a fresh second run, got %+v", contract)
+	}
+	if contract.Options.ModelVersion != "vlm" || contract.PDFURL != "http://example.com/a.pdf" {
+		t.Errorf("Expected the new options and file URL, got %+v %s", contract.Options, contract.PDFURL)
+	}
+	if ResultPrefix(contract) != "tenant1/reprocess-test/revisions/2/result/" {
+		t.Errorf("Expected the second run's own result prefix, got %s", ResultPrefix(contract))
+	}
+
+	revision := FindRevision(contract, 1)
+	if revision == nil || revision.Options.ModelVersion != "pipeline" || revision.JSONData == nil || len(revision.Artifacts) != 1 {
+		t.Fatalf("Expected the first run to be kept, got %+v", contract.Revisions)
+	}
+	if revision.ResultPrefix != "tenant1/reprocess-test/result/" {
+		t.Errorf("Expected the first run's result prefix, got %s", revision.ResultPrefix)
+	}
+
+	if err := q.Reprocess("reprocess-test", parser, "http://example.com/a.pdf", vlm); !errors.Is(err, ErrContractBusy) {
+		t.Errorf("Expected ErrContractBusy while processing, got %v", err)
+	}
+
+	// A failed run is not kept as a revision
+	store.UpdateStatus("reprocess-test", model.StatusFailed, "boom")
+	if err := q.Reprocess("reprocess-test", parser, "http://example.com/a.pdf", vlm); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	contract = mustGet(t, store, "reprocess-test")
+	if contract.Revision != 3 || len(contract.Revisions) != 1 || contract.ErrorMsg != "" {
+		t.Errorf("Expected third run keeping only the first, got revision %d with %d kept", contract.Revision, len(contract.Revisions))
+	}
+}
+
+func TestParseQueuePollTimeout(t *testing.T) {
+	store := newTestStore(0)
+	store.Save(&model.Contract{ID: "poll-timeout", Tenant: "tenant1", Status: model.StatusProcessing, Parser: "fake", MineruTaskID: "task-1", Attempts: 2, CreatedAt: time.Now()})
+
+	q := newTestParseQueue(t, store, &fakeParser{statuses: []*ParseStatus{{State: ParseStatePending}}}, 3)
+	q.run(context.Background(), "poll-timeout")
+
+	if c := mustGet(t, store, "poll-timeout"); c.Status != model.StatusFailed || c.ErrorMsg != "Task polling timeout" {
+		t.Errorf("Unexpected contract after timeout: %+v", c)
+	}
+}
+
+func TestParseQueueTransientErrors(t *testing.T) {
+	store := newTestStore(0)
+	unavailable := &MineruError{StatusCode: 503, Message: "Service Unavailable", Transient: true}
+
+	// A failed submission stays pending and is submitted again
+	store.Save(&model.Contract{ID: "transient-submit", Tenant: "tenant1", Status: model.StatusPending, CreatedAt: time.Now()})
+	parser := &fakeParser{submitErr: unavailable}
+	q := newTestParseQueue(t, store, parser, 3)
+	q.submit(context.Background(), mustGet(t, store, "transient-submit"), parser, &ParseJob{})
+	if c := mustGet(t, store, "transient-submit"); c.Status != model.StatusPending || c.Attempts != 1 || c.NextRunAt == nil {
+		t.Errorf("Expected pending job after 1 attempt, got %+v", c)
+	}
+
+	// A failed poll is retried, and permanent errors fail the contract
+	store.Save(&model.Contract{ID: "transient-poll", Tenant: "tenant1", Status: model.StatusProcessing, Parser: "fake", MineruTaskID: "task-1", CreatedAt: time.Now()})
+	store.Save(&model.Contract{ID: "permanent-poll", Tenant: "tenant1", Status: model.StatusProcessing, Parser: "fake", MineruTaskID: "task-2", CreatedAt: time.Now()})
+
+	newTestParseQueue(t, store, &fakeParser{pollErr: unavailable}, 3).run(context.Background(), "transient-poll")
+	if c := mustGet(t, store, "transient-poll"); c.Status != model.StatusProcessing || c.Attempts != 1 {
+		t.Errorf("Expected processing job after 1 attempt, got %+v", c)
+	}
+
+	notFound := &MineruError{Code: "-60012", Message: "task not found"}
+	newTestParseQueue(t, store, &fakeParser{pollErr: notFound}, 3).run(context.Background(), "permanent-poll")
+	if c := mustGet(t, store, "permanent-poll"); c.Status != model.StatusFailed || c.ErrorMsg != notFound.Error() {
+		t.Errorf("Expected failed contract, got %+v", c)
+	}
+}
+
+func TestParseQueueUnknownParser(t *testing.T) {
+	store := newTestStore(0)
+	store.Save(&model.Contract{ID: "unknown-parser", Tenant: "tenant1", Status: model.StatusProcessing, Parser: "gone", MineruTaskID: "task-1", CreatedAt: time.Now()})
+
+	q := newTestParseQueue(t, store, &fakeParser{}, 3)
+	q.run(context.Background(), "unknown-parser")
+
+	if c := mustGet(t, store, "unknown-parser"); c.Status != model.StatusFailed {
+		t.Errorf("Expected status %s, got %s", model.StatusFailed, c.Status)
+	}
+}
+
+func TestParseQueueResumesUnfinishedJobs(t *testing.T) {
+	store := newTestStore(0)
+	result := map[string]interface{}{"pdf_info": []interface{}{}}
+
+	// A task submitted before a restart and a contract whose upload never
+	// reached its parser
+	store.Save(&model.Contract{ID: "resume-task", Tenant: "tenant1", Status: model.StatusProcessing, Parser: "fake", MineruTaskID: "task-1", CreatedAt: time.Now()})
+	store.Save(&model.Contract{ID: "resume-pending", Tenant: "tenant1", Filename: "a.pdf", Status: model.StatusPending, CreatedAt: time.Now()})
+	store.Save(&model.Contract{ID: "resume-done", Tenant: "tenant1", Status: model.StatusCompleted, CreatedAt: time.Now()})
+
+	parser := &fakeParser{
+		task: &ParseTask{Result: result},
+		statuses: []*ParseStatus{
+			{State: ParseStateRunning},
+			{State: ParseStateDone},
+		},
+		result: &ParseResult{Data: result},
+	}
+	q := newTestParseQueue(t, store, parser, 10)
+	if err := q.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start queue: %v", err)
+	}
+	defer q.Stop()
+
+	if c := waitForStatus(t, store, "resume-task", model.StatusCompleted); c.JSONData == nil {
+		t.Error("Expected JSON data after resumed task completed")
+	}
+	waitForStatus(t, store, "resume-pending", model.StatusCompleted)
+
+	parser.mu.Lock()
+	defer parser.mu.Unlock()
+	if parser.submits != 1 {
+		t.Errorf("Expected 1 submit for the pending contract, got %d", parser.submits)
+	}
+	if stats := q.Stats(); stats.Workers != 2 {
+		t.Errorf("Expected 2 workers, got %d", stats.Workers)
+	}
+}
+
+func TestMapKeys(t *testing.T) {
+	m := map[string]interface{}{
+		"key1": "value1",
+		"key2": "value2",
+		"key3": "value3",
+	}
+
+	keys := mapKeys(m)
+	if len(keys) != 3 {
+		t.Errorf("Expected 3 keys, got %d", len(keys))
+	}
+
+	keySet := make(map[string]bool)
+	for _, k := range keys {
+		keySet[k] = true
+	}
+	for k := range m {
+		if !keySet[k] {
+			t.Errorf("Expected key '%s' in result", k)
+		}
+	}
+
+	if keys := mapKeys(map[string]interface{}{}); len(keys) != 0 {
+		t.Errorf("Expected 0 keys, got %d", len(keys))
+	}
+}
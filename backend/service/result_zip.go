@@ -0,0 +1,230 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/AnTengye/contractdiff/backend/config"
+)
+
+// Result limits reported by ResultLimitError
+const (
+	LimitDownloadSize     = "download size"
+	LimitUncompressedSize = "uncompressed size"
+	LimitEntries          = "entry count"
+)
+
+// ResultLimitError is returned when a downloaded result exceeds one of the
+// configured limits
+type ResultLimitError struct {
+	Limit string // One of the Limit constants
+	Max   int64
+}
+
+func (e *ResultLimitError) Error() string {
+	return fmt.Sprintf("result %s exceeds limit of %d", e.Limit, e.Max)
+}
+
+// resultLimits fills limits left unset with the defaults
+func resultLimits(limits config.ResultLimits) config.ResultLimits {
+	defaults := config.DefaultResultLimits
+	if limits.MaxDownloadBytes <= 0 {
+		limits.MaxDownloadBytes = defaults.MaxDownloadBytes
+	}
+	if limits.MaxUncompressedBytes <= 0 {
+		limits.MaxUncompressedBytes = defaults.MaxUncompressedBytes
+	}
+	if limits.MaxEntries <= 0 {
+		limits.MaxEntries = defaults.MaxEntries
+	}
+	return limits
+}
+
+// limitedReader fails with err once more than *remaining bytes were read.
+// The remaining byte count may be shared by several readers.
+type limitedReader struct {
+	r         io.Reader
+	remaining *int64
+	err       error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if *l.remaining < 0 {
+		return 0, l.err
+	}
+	// Read at most one byte past the limit to detect exceeding it
+	if int64(len(p)) > *l.remaining+1 {
+		p = p[:*l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	*l.remaining -= int64(n)
+	if *l.remaining < 0 {
+		return n, l.err
+	}
+	return n, err
+}
+
+// downloadToTemp streams r into a temporary file of at most maxBytes. The
+// caller removes the file.
+func downloadToTemp(r io.Reader, maxBytes int64) (*os.File, int64, error) {
+	f, err := os.CreateTemp("", "contractdiff-result-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	remaining := maxBytes
+	n, err := io.Copy(f, &limitedReader{r: r, remaining: &remaining, err: &ResultLimitError{Limit: LimitDownloadSize, Max: maxBytes}})
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, 0, err
+	}
+	return f, n, nil
+}
+
+// ResultZip is an opened result ZIP. Reading its files is bounded by the
+// uncompressed size limit.
+type ResultZip struct {
+	reader *zip.Reader
+	limits config.ResultLimits
+	file   *os.File // Temporary file backing the ZIP, if any
+}
+
+// OpenResultZip opens a result ZIP of size bytes, rejecting archives with
+// too many entries or a declared uncompressed size over the limit
+func OpenResultZip(r io.ReaderAt, size int64, limits config.ResultLimits) (*ResultZip, error) {
+	limits = resultLimits(limits)
+	if size > limits.MaxDownloadBytes {
+		return nil, &ResultLimitError{Limit: LimitDownloadSize, Max: limits.MaxDownloadBytes}
+	}
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ZIP: %w", err)
+	}
+	if len(zr.File) > limits.MaxEntries {
+		return nil, &ResultLimitError{Limit: LimitEntries, Max: int64(limits.MaxEntries)}
+	}
+	var total uint64
+	for _, f := range zr.File {
+		total += f.UncompressedSize64
+		if total > uint64(limits.MaxUncompressedBytes) {
+			return nil, &ResultLimitError{Limit: LimitUncompressedSize, Max: limits.MaxUncompressedBytes}
+		}
+	}
+	return &ResultZip{reader: zr, limits: limits}, nil
+}
+
+// Close removes the temporary file of a downloaded ZIP
+func (z *ResultZip) Close() error {
+	if z.file == nil {
+		return nil
+	}
+	z.file.Close()
+	return os.Remove(z.file.Name())
+}
+
+// open opens a ZIP entry whose reads count against remaining
+func (z *ResultZip) open(f *zip.File, remaining *int64) (io.ReadCloser, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{
+		&limitedReader{r: rc, remaining: remaining, err: &ResultLimitError{Limit: LimitUncompressedSize, Max: z.limits.MaxUncompressedBytes}},
+		rc,
+	}, nil
+}
+
+// ExtractJSON decodes the first parseable JSON document of the ZIP,
+// preferring content_list.json, middle.json and model.json
+func (z *ResultZip) ExtractJSON() (map[string]interface{}, error) {
+	remaining := z.limits.MaxUncompressedBytes
+	decode := func(f *zip.File) (map[string]interface{}, error) {
+		rc, err := z.open(f, &remaining)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		var jsonData map[string]interface{}
+		if err := json.NewDecoder(rc).Decode(&jsonData); err != nil {
+			return nil, err
+		}
+		return jsonData, nil
+	}
+
+	// Look for the known JSON files first, then try any .json file
+	jsonFiles := []string{"content_list.json", "middle.json", "model.json"}
+	isTarget := func(name string) bool {
+		for _, target := range jsonFiles {
+			if strings.HasSuffix(name, target) {
+				return true
+			}
+		}
+		return false
+	}
+	passes := []func(name string) bool{
+		isTarget,
+		func(name string) bool { return strings.HasSuffix(name, ".json") },
+	}
+
+	for _, match := range passes {
+		for _, file := range z.reader.File {
+			if !match(file.Name) {
+				continue
+			}
+
+			jsonData, err := decode(file)
+			var limitErr *ResultLimitError
+			if errors.As(err, &limitErr) {
+				return nil, err
+			}
+			if err != nil {
+				slog.Debug("failed to parse JSON file", "file", file.Name, "error", err)
+				continue
+			}
+
+			slog.Info("successfully parsed JSON", "file", file.Name)
+			return jsonData, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no valid JSON file found in ZIP")
+}
+
+// Files returns the files of the ZIP, which can be opened until the ZIP is
+// closed. Reads of all files together are bounded by the uncompressed size
+// limit. Directories and entries with unsafe paths are skipped.
+func (z *ResultZip) Files() []ResultFile {
+	remaining := z.limits.MaxUncompressedBytes
+	var files []ResultFile
+	for _, f := range z.reader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name, ok := cleanArtifactPath(f.Name)
+		if !ok {
+			continue
+		}
+		files = append(files, ResultFile{
+			Path: name,
+			Size: int64(f.UncompressedSize64),
+			Open: func() (io.ReadCloser, error) {
+				rc, err := z.open(f, &remaining)
+				if err != nil {
+					return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+				}
+				return rc, nil
+			},
+		})
+	}
+	return files
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/AnTengye/contractdiff/backend/config"
+)
+
+func expectLimitError(t *testing.T, err error, limit string) {
+	t.Helper()
+	var limitErr *ResultLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("Expected ResultLimitError for %s, got %v", limit, err)
+	}
+	if limitErr.Limit != limit {
+		t.Errorf("Expected %s limit, got %s", limit, limitErr.Limit)
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+	remaining := int64(5)
+	limitErr := &ResultLimitError{Limit: LimitDownloadSize, Max: 5}
+
+	data, err := io.ReadAll(&limitedReader{r: strings.NewReader("12345"), remaining: &remaining, err: limitErr})
+	if err != nil || string(data) != "12345" {
+		t.Errorf("Expected data at the limit to pass, got %q, %v", data, err)
+	}
+
+	// The budget is shared, so the next reader is over the limit
+	remaining = 5
+	io.ReadAll(&limitedReader{r: strings.NewReader("123"), remaining: &remaining, err: limitErr})
+	_, err = io.ReadAll(&limitedReader{r: strings.NewReader("456"), remaining: &remaining, err: limitErr})
+	expectLimitError(t, err, LimitDownloadSize)
+}
+
+func TestOpenResultZipLimits(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"a/middle.json": `{"pdf_info": []}`,
+		"a/full.md":     strings.Repeat("合同", 100),
+	})
+	open := func(limits config.ResultLimits) error {
+		_, err := OpenResultZip(bytes.NewReader(data), int64(len(data)), limits)
+		return err
+	}
+
+	if err := open(config.ResultLimits{}); err != nil {
+		t.Fatalf("Expected ZIP within default limits to open, got %v", err)
+	}
+	expectLimitError(t, open(config.ResultLimits{MaxEntries: 1}), LimitEntries)
+	expectLimitError(t, open(config.ResultLimits{MaxUncompressedBytes: 100}), LimitUncompressedSize)
+	expectLimitError(t, open(config.ResultLimits{MaxDownloadBytes: 100}), LimitDownloadSize)
+}
+
+func TestResultZipFilesShareLimit(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"a/full.md":   strings.Repeat("x", 60),
+		"a/layout.md": strings.Repeat("y", 60),
+	})
+	z, err := OpenResultZip(bytes.NewReader(data), int64(len(data)), config.ResultLimits{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Entries are read when opened, with one budget for all of them
+	z.limits.MaxUncompressedBytes = 100
+	files := z.Files()
+	if len(files) != 2 || files[0].Size != 60 {
+		t.Fatalf("Expected 2 files of 60 bytes, got %+v", files)
+	}
+	read := func(f ResultFile) error {
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		_, err = io.ReadAll(rc)
+		return err
+	}
+	if err := read(files[0]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectLimitError(t, read(files[1]), LimitUncompressedSize)
+}
+
+func TestResultZipExtractJSONFallback(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"a/middle.json": `not json`,
+		"a/layout.json": `{"pdf_info": [], "_backend": "pipeline"}`,
+	})
+	z, err := OpenResultZip(bytes.NewReader(data), int64(len(data)), config.ResultLimits{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, err := z.ExtractJSON()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result["_backend"] != "pipeline" {
+		t.Errorf("Expected the fallback JSON file, got %v", result)
+	}
+}
+
+func TestMineruServiceFetchResultZip(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"a/middle.json": `{"pdf_info": []}`,
+		"a/full.md":     "# 合同",
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	svc := NewMineruService(&config.MineruConfig{})
+	z, err := svc.FetchResultZip(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if files := z.Files(); len(files) != 2 {
+		t.Errorf("Expected 2 files, got %d", len(files))
+	}
+
+	// Closing removes the temporary file
+	tempFile := z.file.Name()
+	z.Close()
+	if _, err := os.Stat(tempFile); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed, got %v", tempFile, err)
+	}
+
+	small := NewMineruService(&config.MineruConfig{ResultLimits: config.ResultLimits{MaxDownloadBytes: 64}})
+	_, err = small.FetchResultZip(context.Background(), server.URL)
+	expectLimitError(t, err, LimitDownloadSize)
+
+	// Without a Content-Length the limit applies while streaming
+	chunked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		w.Write(data)
+	}))
+	defer chunked.Close()
+	_, err = small.FetchResultZip(context.Background(), chunked.URL)
+	expectLimitError(t, err, LimitDownloadSize)
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	if _, err := svc.FetchResultZip(context.Background(), missing.URL); err == nil {
+		t.Error("Expected error for HTTP 404")
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/AnTengye/contractdiff/backend/model"
+)
+
+// ErrContractBusy is returned for operations that need a contract whose
+// processing has finished
+var ErrContractBusy = errors.New("contract is being processed")
+
+// CurrentRevision returns the run number of a contract's current parse
+func CurrentRevision(contract *model.Contract) int {
+	return max(contract.Revision, 1)
+}
+
+// FindRevision returns the kept result of run number n, or nil
+func FindRevision(contract *model.Contract, n int) *model.Revision {
+	for i := range contract.Revisions {
+		if contract.Revisions[i].Number == n {
+			return &contract.Revisions[i]
+		}
+	}
+	return nil
+}
+
+// archiveRevision keeps the current result of a completed contract as a
+// revision. Runs without a result are not kept.
+func archiveRevision(contract *model.Contract) {
+	if contract.Status != model.StatusCompleted || contract.JSONData == nil {
+		return
+	}
+	revisions := make([]model.Revision, len(contract.Revisions), len(contract.Revisions)+1)
+	copy(revisions, contract.Revisions)
+	contract.Revisions = append(revisions, model.Revision{
+		Number:       CurrentRevision(contract),
+		Parser:       contract.Parser,
+		Options:      contract.Options,
+		JSONData:     contract.JSONData,
+		Artifacts:    contract.Artifacts,
+		ResultPrefix: ResultPrefix(contract),
+		CompletedAt:  contract.UpdatedAt,
+	})
+}
@@ -0,0 +1,518 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/model"
+)
+
+// migration is a versioned schema change applied once at startup
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// sqlStore implements ContractStore on top of database/sql. Queries are
+// written with "?" placeholders and rewritten by rebind for the driver.
+type sqlStore struct {
+	db      *sql.DB
+	rebind  func(query string) string
+	noLimit string // LIMIT value meaning "no limit"
+	// migrationLock, if set, is run first in the migration transaction to
+	// keep instances starting together from applying migrations twice
+	migrationLock string
+}
+
+const contractColumns = `id, filename, tenant, pdf_url, status, parser, mineru_task_id, content_hash, duplicate_of, source_object, json_data, artifacts, options, progress, revision, revisions, result_prefix, error_msg, attempts, next_run_at, created_at, updated_at`
+
+// migrate applies pending migrations in version order, in one transaction
+// that reads the applied versions under migrationLock
+func (s *sqlStore) migrate(migrations []migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migrations: %w", err)
+	}
+	defer tx.Rollback()
+
+	if s.migrationLock != "" {
+		if _, err := tx.Exec(s.migrationLock); err != nil {
+			return fmt.Errorf("failed to lock migrations: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied := make(map[int]bool)
+	rows, err := tx.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		applied[v] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	var pending []migration
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if _, err := tx.Exec(m.sql); err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %w", m.version, m.name, err)
+		}
+		if _, err := tx.Exec(s.rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+			m.version, m.name, time.Now().UTC()); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+		}
+		pending = append(pending, m)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migrations: %w", err)
+	}
+	for _, m := range pending {
+		slog.Info("store migration applied", "version", m.version, "name", m.name)
+	}
+	return nil
+}
+
+func (s *sqlStore) Save(contract *model.Contract) error {
+	contract.UpdatedAt = time.Now()
+
+	jsonData, err := encodeJSONColumn(contract.JSONData)
+	if err != nil {
+		return err
+	}
+	artifacts, err := encodeArtifacts(contract.Artifacts)
+	if err != nil {
+		return err
+	}
+	var options, progress, revisions any
+	if contract.Options != nil {
+		if options, err = encodeJSONColumn(contract.Options); err != nil {
+			return err
+		}
+	}
+	if contract.Progress != nil {
+		if progress, err = encodeJSONColumn(contract.Progress); err != nil {
+			return err
+		}
+	}
+	if len(contract.Revisions) > 0 {
+		if revisions, err = encodeJSONColumn(contract.Revisions); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.db.Exec(s.rebind(`INSERT INTO contracts (`+contractColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			filename = excluded.filename,
+			tenant = excluded.tenant,
+			pdf_url = excluded.pdf_url,
+			status = excluded.status,
+			parser = excluded.parser,
+			mineru_task_id = excluded.mineru_task_id,
+			content_hash = excluded.content_hash,
+			duplicate_of = excluded.duplicate_of,
+			source_object = excluded.source_object,
+			json_data = excluded.json_data,
+			artifacts = excluded.artifacts,
+			options = excluded.options,
+			progress = excluded.progress,
+			revision = excluded.revision,
+			revisions = excluded.revisions,
+			result_prefix = excluded.result_prefix,
+			error_msg = excluded.error_msg,
+			attempts = excluded.attempts,
+			next_run_at = excluded.next_run_at,
+			updated_at = excluded.updated_at`),
+		contract.ID,
+		contract.Filename,
+		contract.Tenant,
+		contract.PDFURL,
+		contract.Status,
+		contract.Parser,
+		contract.MineruTaskID,
+		contract.ContentHash,
+		contract.DuplicateOf,
+		contract.SourceObject,
+		jsonData,
+		artifacts,
+		options,
+		progress,
+		contract.Revision,
+		revisions,
+		contract.ResultPrefix,
+		contract.ErrorMsg,
+		contract.Attempts,
+		nullTime(contract.NextRunAt),
+		contract.CreatedAt.UTC(),
+		contract.UpdatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save contract: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) Get(id string) (*model.Contract, error) {
+	row := s.db.QueryRow(s.rebind(`SELECT `+contractColumns+` FROM contracts WHERE id = ?`), id)
+	contract, err := scanContract(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contract: %w", err)
+	}
+	return contract, nil
+}
+
+func (s *sqlStore) GetByTenant(tenant string) ([]*model.Contract, error) {
+	return s.queryContracts(`SELECT `+contractColumns+` FROM contracts WHERE tenant = ? ORDER BY created_at DESC`, tenant)
+}
+
+func (s *sqlStore) ListByTenant(tenant string, opts ListOptions) ([]*model.Contract, int, error) {
+	where := `WHERE tenant = ?`
+	args := []any{tenant}
+	if opts.Status != "" {
+		where += ` AND status = ?`
+		args = append(args, opts.Status)
+	}
+
+	var total int
+	if err := s.db.QueryRow(s.rebind(`SELECT COUNT(*) FROM contracts `+where), args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count contracts: %w", err)
+	}
+
+	query := `SELECT ` + contractColumns + ` FROM contracts ` + where + ` ORDER BY created_at DESC`
+	if opts.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, opts.Limit)
+	}
+	if opts.Offset > 0 {
+		if opts.Limit <= 0 {
+			// OFFSET requires a LIMIT in SQLite; -1 and NULL mean no limit
+			query += ` LIMIT ` + s.noLimit
+		}
+		query += ` OFFSET ?`
+		args = append(args, opts.Offset)
+	}
+
+	rows, err := s.db.Query(s.rebind(query), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list contracts: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*model.Contract
+	for rows.Next() {
+		contract, err := scanContract(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to list contracts: %w", err)
+		}
+		result = append(result, contract)
+	}
+	return result, total, rows.Err()
+}
+
+func (s *sqlStore) Delete(id string) error {
+	if _, err := s.db.Exec(s.rebind(`DELETE FROM contracts WHERE id = ?`), id); err != nil {
+		return fmt.Errorf("failed to delete contract: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) UpdateStatus(id, status string, errMsg string) (bool, error) {
+	res, err := s.db.Exec(s.rebind(`UPDATE contracts SET status = ?, error_msg = ?, updated_at = ? WHERE id = ? AND status IN (?, ?)`),
+		status, errMsg, time.Now().UTC(), id, model.StatusPending, model.StatusProcessing)
+	if err != nil {
+		return false, fmt.Errorf("failed to update contract status: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to update contract status: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (s *sqlStore) UpdateJSONData(id string, jsonData any) error {
+	data, err := encodeJSONColumn(jsonData)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(s.rebind(`UPDATE contracts SET json_data = ?, status = ?, updated_at = ? WHERE id = ? AND status IN (?, ?)`),
+		data, model.StatusCompleted, time.Now().UTC(), id, model.StatusPending, model.StatusProcessing)
+	if err != nil {
+		return fmt.Errorf("failed to update contract JSON data: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) UpdateArtifacts(id string, artifacts []model.Artifact) error {
+	data, err := encodeArtifacts(artifacts)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.rebind(`UPDATE contracts SET artifacts = ?, updated_at = ? WHERE id = ?`),
+		data, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update contract artifacts: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) UpdateProgress(id string, progress *model.Progress) error {
+	data, err := encodeJSONColumn(progress)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.rebind(`UPDATE contracts SET progress = ?, updated_at = ? WHERE id = ?`),
+		data, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update contract progress: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) FindByHash(tenant, hash string) ([]*model.Contract, error) {
+	return s.queryContracts(`SELECT `+contractColumns+` FROM contracts WHERE tenant = ? AND content_hash = ? AND status = ? ORDER BY created_at DESC`,
+		tenant, hash, model.StatusCompleted)
+}
+
+func (s *sqlStore) ListJobs(due time.Time, limit int) ([]*model.Contract, error) {
+	query := `SELECT ` + contractColumns + ` FROM contracts
+		WHERE status IN (?, ?) AND next_run_at IS NOT NULL AND next_run_at <= ?
+		ORDER BY next_run_at`
+	args := []any{model.StatusPending, model.StatusProcessing, due.UTC()}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	return s.queryContracts(query, args...)
+}
+
+func (s *sqlStore) ClaimJob(id string, due, lease time.Time) (bool, error) {
+	res, err := s.db.Exec(s.rebind(`UPDATE contracts SET next_run_at = ?, updated_at = ?
+		WHERE id = ? AND status IN (?, ?) AND next_run_at IS NOT NULL AND next_run_at <= ?`),
+		lease.UTC(), time.Now().UTC(),
+		id, model.StatusPending, model.StatusProcessing, due.UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to claim contract job: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim contract job: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (s *sqlStore) ListUnfinished() ([]*model.Contract, error) {
+	return s.queryContracts(`SELECT `+contractColumns+` FROM contracts WHERE status IN (?, ?) ORDER BY created_at`,
+		model.StatusPending, model.StatusProcessing)
+}
+
+func (s *sqlStore) UpdateJob(id string, attempts int, nextRunAt time.Time) (bool, error) {
+	res, err := s.db.Exec(s.rebind(`UPDATE contracts SET attempts = ?, next_run_at = ?, updated_at = ? WHERE id = ? AND status IN (?, ?)`),
+		attempts, nextRunAt.UTC(), time.Now().UTC(), id, model.StatusPending, model.StatusProcessing)
+	if err != nil {
+		return false, fmt.Errorf("failed to update contract job: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to update contract job: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (s *sqlStore) UpdateTask(id, parser, taskID string, nextRunAt time.Time) (bool, error) {
+	res, err := s.db.Exec(s.rebind(`UPDATE contracts
+		SET status = ?, parser = ?, mineru_task_id = ?, attempts = 0, next_run_at = ?, updated_at = ?
+		WHERE id = ? AND status IN (?, ?)`),
+		model.StatusProcessing, parser, taskID, nextRunAt.UTC(), time.Now().UTC(),
+		id, model.StatusPending, model.StatusProcessing)
+	if err != nil {
+		return false, fmt.Errorf("failed to update contract task: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to update contract task: %w", err)
+	}
+	return n > 0, nil
+}
+
+// queryContracts runs a query returning contract rows
+func (s *sqlStore) queryContracts(query string, args ...any) ([]*model.Contract, error) {
+	rows, err := s.db.Query(s.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list contracts: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*model.Contract
+	for rows.Next() {
+		contract, err := scanContract(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list contracts: %w", err)
+		}
+		result = append(result, contract)
+	}
+	return result, rows.Err()
+}
+
+func (s *sqlStore) Count() (int, error) {
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM contracts`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count contracts: %w", err)
+	}
+	return n, nil
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanContract(row rowScanner) (*model.Contract, error) {
+	var (
+		c         model.Contract
+		jsonData  sql.NullString
+		artifacts sql.NullString
+		options   sql.NullString
+		progress  sql.NullString
+		revisions sql.NullString
+		nextRunAt sql.NullTime
+	)
+	err := row.Scan(
+		&c.ID,
+		&c.Filename,
+		&c.Tenant,
+		&c.PDFURL,
+		&c.Status,
+		&c.Parser,
+		&c.MineruTaskID,
+		&c.ContentHash,
+		&c.DuplicateOf,
+		&c.SourceObject,
+		&jsonData,
+		&artifacts,
+		&options,
+		&progress,
+		&c.Revision,
+		&revisions,
+		&c.ResultPrefix,
+		&c.ErrorMsg,
+		&c.Attempts,
+		&nextRunAt,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if nextRunAt.Valid {
+		c.NextRunAt = &nextRunAt.Time
+	}
+	if jsonData.Valid && jsonData.String != "" {
+		if err := json.Unmarshal([]byte(jsonData.String), &c.JSONData); err != nil {
+			return nil, fmt.Errorf("failed to decode json_data of %s: %w", c.ID, err)
+		}
+	}
+	if artifacts.Valid && artifacts.String != "" {
+		if err := json.Unmarshal([]byte(artifacts.String), &c.Artifacts); err != nil {
+			return nil, fmt.Errorf("failed to decode artifacts of %s: %w", c.ID, err)
+		}
+	}
+	if options.Valid && options.String != "" {
+		if err := json.Unmarshal([]byte(options.String), &c.Options); err != nil {
+			return nil, fmt.Errorf("failed to decode options of %s: %w", c.ID, err)
+		}
+	}
+	if progress.Valid && progress.String != "" {
+		if err := json.Unmarshal([]byte(progress.String), &c.Progress); err != nil {
+			return nil, fmt.Errorf("failed to decode progress of %s: %w", c.ID, err)
+		}
+	}
+	if revisions.Valid && revisions.String != "" {
+		if err := json.Unmarshal([]byte(revisions.String), &c.Revisions); err != nil {
+			return nil, fmt.Errorf("failed to decode revisions of %s: %w", c.ID, err)
+		}
+	}
+	return &c, nil
+}
+
+// nullTime maps a nil time to NULL
+func nullTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+// encodeJSONColumn marshals v for a JSON column, mapping nil to NULL
+func encodeJSONColumn(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON column: %w", err)
+	}
+	return string(data), nil
+}
+
+// encodeArtifacts encodes an artifact manifest, mapping an empty one to NULL
+func encodeArtifacts(artifacts []model.Artifact) (any, error) {
+	if len(artifacts) == 0 {
+		return nil, nil
+	}
+	return encodeJSONColumn(artifacts)
+}
+
+// rebindDollar rewrites "?" placeholders to "$1", "$2", ...
+func rebindDollar(query string) string {
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&sb, "$%d", n)
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// rebindNone leaves "?" placeholders as they are
+func rebindNone(query string) string {
+	return query
+}
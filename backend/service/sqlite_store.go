@@ -0,0 +1,112 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var sqliteMigrations = []migration{
+	{
+		version: 1,
+		name:    "create_contracts",
+		sql: `CREATE TABLE contracts (
+			id TEXT PRIMARY KEY,
+			filename TEXT NOT NULL DEFAULT '',
+			tenant TEXT NOT NULL,
+			pdf_url TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL,
+			mineru_task_id TEXT NOT NULL DEFAULT '',
+			json_data TEXT,
+			error_msg TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		);
+		CREATE INDEX idx_contracts_tenant_created ON contracts (tenant, created_at);`,
+	},
+	{
+		version: 2,
+		name:    "index_contracts_tenant_status",
+		sql:     `CREATE INDEX idx_contracts_tenant_status_created ON contracts (tenant, status, created_at);`,
+	},
+	{
+		version: 3,
+		name:    "add_contracts_parser",
+		sql:     `ALTER TABLE contracts ADD COLUMN parser TEXT NOT NULL DEFAULT '';`,
+	},
+	{
+		version: 4,
+		name:    "add_contracts_job_schedule",
+		sql: `ALTER TABLE contracts ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE contracts ADD COLUMN next_run_at TIMESTAMP;
+		CREATE INDEX idx_contracts_status_next_run ON contracts (status, next_run_at);`,
+	},
+	{
+		version: 5,
+		name:    "add_contracts_artifacts",
+		sql:     `ALTER TABLE contracts ADD COLUMN artifacts TEXT;`,
+	},
+	{
+		version: 6,
+		name:    "add_contracts_options",
+		sql:     `ALTER TABLE contracts ADD COLUMN options TEXT;`,
+	},
+	{
+		version: 7,
+		name:    "add_contracts_progress",
+		sql:     `ALTER TABLE contracts ADD COLUMN progress TEXT;`,
+	},
+	{
+		version: 8,
+		name:    "add_contracts_content_hash",
+		sql: `ALTER TABLE contracts ADD COLUMN content_hash TEXT NOT NULL DEFAULT '';
+		ALTER TABLE contracts ADD COLUMN duplicate_of TEXT NOT NULL DEFAULT '';
+		CREATE INDEX idx_contracts_tenant_content_hash ON contracts (tenant, content_hash);`,
+	},
+	{
+		version: 9,
+		name:    "add_contracts_revisions",
+		sql: `ALTER TABLE contracts ADD COLUMN revision INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE contracts ADD COLUMN revisions TEXT;
+		ALTER TABLE contracts ADD COLUMN result_prefix TEXT NOT NULL DEFAULT '';`,
+	},
+	{
+		version: 10,
+		name:    "add_contracts_source_object",
+		sql:     `ALTER TABLE contracts ADD COLUMN source_object TEXT NOT NULL DEFAULT '';`,
+	},
+}
+
+// NewSQLiteStore opens (creating if needed) the SQLite database at path and
+// applies schema migrations
+func NewSQLiteStore(path string) (ContractStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sqlite store requires a path")
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	// SQLite allows a single writer; serialize access through one connection
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	store := &sqlStore{db: db, rebind: rebindNone, noLimit: "-1"}
+	if err := store.migrate(sqliteMigrations); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	slog.Info("contract store initialized",
+		"driver", StoreDriverSQLite,
+		"path", path,
+	)
+	return store, nil
+}
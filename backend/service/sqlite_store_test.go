@@ -0,0 +1,236 @@
+package service
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/model"
+)
+
+func newTestSQLiteStore(t *testing.T, path string) ContractStore {
+	t.Helper()
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open sqlite store: %v", err)
+	}
+	return store
+}
+
+func TestSQLiteStoreSaveAndGet(t *testing.T) {
+	store := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "test.db"))
+	defer store.Close()
+
+	createdAt := time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)
+	isOCR := true
+	if err := store.Save(&model.Contract{
+		ID:           "sqlite-1",
+		Filename:     "test.pdf",
+		Tenant:       "tenant1",
+		PDFURL:       "http://example.com/test.pdf",
+		Status:       model.StatusProcessing,
+		Parser:       ParserMineru,
+		MineruTaskID: "task-1",
+		Options:      &model.ExtractOptions{ModelVersion: "pipeline", IsOCR: &isOCR, PageRanges: "1-3"},
+		CreatedAt:    createdAt,
+	}); err != nil {
+		t.Fatalf("Failed to save contract: %v", err)
+	}
+
+	contract := mustGet(t, store, "sqlite-1")
+	if contract == nil {
+		t.Fatal("Expected to retrieve contract")
+	}
+	if contract.Filename != "test.pdf" || contract.Parser != ParserMineru || contract.MineruTaskID != "task-1" {
+		t.Errorf("Unexpected contract: %+v", contract)
+	}
+	if !contract.CreatedAt.Equal(createdAt) {
+		t.Errorf("Expected created_at %v, got %v", createdAt, contract.CreatedAt)
+	}
+	if contract.JSONData != nil {
+		t.Errorf("Expected nil JSON data, got %v", contract.JSONData)
+	}
+	if o := contract.Options; o == nil || o.ModelVersion != "pipeline" || o.IsOCR == nil || !*o.IsOCR || o.PageRanges != "1-3" {
+		t.Errorf("Expected options to round-trip, got %+v", o)
+	}
+
+	if mustGet(t, store, "non-existent") != nil {
+		t.Error("Expected nil for non-existent contract")
+	}
+}
+
+func TestSQLiteStoreUpdates(t *testing.T) {
+	store := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "test.db"))
+	defer store.Close()
+
+	store.Save(&model.Contract{ID: "a", Tenant: "tenant1", Status: model.StatusPending, CreatedAt: time.Now()})
+	store.Save(&model.Contract{ID: "b", Tenant: "tenant1", Status: model.StatusPending, CreatedAt: time.Now()})
+	store.Save(&model.Contract{ID: "c", Tenant: "tenant2", Status: model.StatusPending, CreatedAt: time.Now()})
+
+	if updated, err := store.UpdateStatus("a", model.StatusFailed, "boom"); !updated || err != nil {
+		t.Fatalf("Failed to update status: %v, %v", updated, err)
+	}
+	a := mustGet(t, store, "a")
+	if a.Status != model.StatusFailed || a.ErrorMsg != "boom" {
+		t.Errorf("Unexpected status update: %+v", a)
+	}
+
+	jsonData := map[string]interface{}{"pdf_info": []interface{}{map[string]interface{}{"page_idx": float64(0)}}}
+	if err := store.UpdateJSONData("b", jsonData); err != nil {
+		t.Fatalf("Failed to update JSON data: %v", err)
+	}
+	b := mustGet(t, store, "b")
+	if b.Status != model.StatusCompleted {
+		t.Errorf("Expected status %s, got %s", model.StatusCompleted, b.Status)
+	}
+	data, ok := b.JSONData.(map[string]interface{})
+	if !ok || data["pdf_info"] == nil {
+		t.Errorf("Expected JSON data to round-trip, got %#v", b.JSONData)
+	}
+
+	startedAt := time.Date(2025, 1, 20, 3, 43, 20, 0, time.UTC)
+	if err := store.UpdateProgress("a", &model.Progress{State: "running", ExtractedPages: 2, TotalPages: 9, StartedAt: &startedAt}); err != nil {
+		t.Fatalf("Failed to update progress: %v", err)
+	}
+	if p := mustGet(t, store, "a").Progress; p == nil || p.ExtractedPages != 2 || p.TotalPages != 9 || !p.StartedAt.Equal(startedAt) {
+		t.Errorf("Expected progress to round-trip, got %+v", p)
+	}
+
+	artifacts := []model.Artifact{{Path: "images/0.jpg", Size: 3, ContentType: "image/jpeg"}}
+	if err := store.UpdateArtifacts("b", artifacts); err != nil {
+		t.Fatalf("Failed to update artifacts: %v", err)
+	}
+	if b := mustGet(t, store, "b"); len(b.Artifacts) != 1 || b.Artifacts[0] != artifacts[0] {
+		t.Errorf("Expected artifacts to round-trip, got %+v", b.Artifacts)
+	}
+
+	if n := len(mustGetByTenant(t, store, "tenant1")); n != 2 {
+		t.Errorf("Expected 2 contracts for tenant1, got %d", n)
+	}
+
+	if err := store.Delete("c"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if mustCount(t, store) != 2 {
+		t.Errorf("Expected 2 contracts after delete, got %d", mustCount(t, store))
+	}
+}
+
+func TestSQLiteStoreSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	store := newTestSQLiteStore(t, path)
+	store.Save(&model.Contract{ID: "persist", Tenant: "tenant1", Status: model.StatusProcessing, CreatedAt: time.Now()})
+	store.UpdateJSONData("persist", map[string]interface{}{"key": "value"})
+	store.Close()
+
+	// Reopening re-runs migrations, which must be idempotent
+	store = newTestSQLiteStore(t, path)
+	defer store.Close()
+
+	contract := mustGet(t, store, "persist")
+	if contract == nil {
+		t.Fatal("Expected contract to survive reopen")
+	}
+	if contract.Status != model.StatusCompleted {
+		t.Errorf("Expected status %s, got %s", model.StatusCompleted, contract.Status)
+	}
+}
+
+func TestSQLStoreMigrateIsAtomic(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open sqlite database: %v", err)
+	}
+	defer db.Close()
+	store := &sqlStore{db: db, rebind: rebindNone, noLimit: "-1", migrationLock: "SELECT 1"}
+
+	migrations := []migration{
+		{version: 1, name: "create_a", sql: `CREATE TABLE a (id TEXT)`},
+		{version: 2, name: "broken", sql: `CREATE TABL b (id TEXT)`},
+	}
+	if err := store.migrate(migrations); err == nil {
+		t.Fatal("Expected error for a broken migration")
+	}
+
+	// Nothing of the failed run is kept
+	var tables int
+	db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name IN ('a', 'schema_migrations')`).Scan(&tables)
+	if tables != 0 {
+		t.Errorf("Expected the failed run to be rolled back, got %d tables", tables)
+	}
+
+	migrations[1].sql = `CREATE TABLE b (id TEXT)`
+	if err := store.migrate(migrations); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var versions int
+	db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&versions)
+	if versions != 2 {
+		t.Errorf("Expected 2 applied migrations, got %d", versions)
+	}
+}
+
+func TestNewSQLiteStoreRequiresPath(t *testing.T) {
+	if _, err := NewSQLiteStore(""); err == nil {
+		t.Error("Expected error for empty path")
+	}
+}
+
+func TestSQLiteStoreListByTenant(t *testing.T) {
+	store := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "test.db"))
+	defer store.Close()
+
+	testStoreListByTenant(t, store, "list-tenant")
+}
+
+func TestSQLiteStoreJobs(t *testing.T) {
+	store := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "test.db"))
+	defer store.Close()
+
+	testStoreJobs(t, store, "jobs-tenant")
+}
+
+func TestSQLiteStoreFindByHash(t *testing.T) {
+	store := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "test.db"))
+	defer store.Close()
+
+	testStoreFindByHash(t, store, "hash-tenant")
+}
+
+func TestSQLiteStoreRevisions(t *testing.T) {
+	store := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "test.db"))
+	defer store.Close()
+
+	completedAt := time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)
+	if err := store.Save(&model.Contract{
+		ID:           "sqlite-revisions",
+		Tenant:       "tenant1",
+		Status:       model.StatusProcessing,
+		Revision:     2,
+		ResultPrefix: "tenant1/other/result/",
+		Revisions: []model.Revision{{
+			Number:       1,
+			Parser:       ParserMineru,
+			JSONData:     map[string]interface{}{"pdf_info": []interface{}{}},
+			ResultPrefix: "tenant1/sqlite-revisions/result/",
+			CompletedAt:  completedAt,
+		}},
+		CreatedAt: completedAt,
+	}); err != nil {
+		t.Fatalf("Failed to save contract: %v", err)
+	}
+
+	contract := mustGet(t, store, "sqlite-revisions")
+	if contract.Revision != 2 || contract.ResultPrefix != "tenant1/other/result/" {
+		t.Errorf("Expected revision and result prefix to round-trip, got %d %q", contract.Revision, contract.ResultPrefix)
+	}
+	if len(contract.Revisions) != 1 {
+		t.Fatalf("Expected 1 revision, got %d", len(contract.Revisions))
+	}
+	r := contract.Revisions[0]
+	if r.Number != 1 || r.JSONData == nil || r.ResultPrefix != "tenant1/sqlite-revisions/result/" || !r.CompletedAt.Equal(completedAt) {
+		t.Errorf("Unexpected revision: %+v", r)
+	}
+}
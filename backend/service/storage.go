@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/AnTengye/contractdiff/backend/config"
+)
+
+// Object storage drivers
+const (
+	StorageDriverMinio = "minio"
+	StorageDriverLocal = "local"
+)
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Name         string
+	Size         int64
+	LastModified time.Time
+}
+
+// ObjectStorage stores uploaded files and parse artifacts by object name
+type ObjectStorage interface {
+	UploadFile(ctx context.Context, objectName string, reader io.Reader, size int64, contentType string) error
+	GetObject(ctx context.Context, objectName string) (io.ReadCloser, error)
+	// DeleteFile deletes an object. Deleting a missing object succeeds.
+	DeleteFile(ctx context.Context, objectName string) error
+	// ListObjects returns every object whose name starts with prefix
+	ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// GetPresignedURL returns an expiring URL from which parsers can fetch
+	// the object without other credentials
+	GetPresignedURL(ctx context.Context, objectName string) (string, error)
+}
+
+// NewObjectStorage creates the object storage selected by cfg.Storage.Driver.
+// The MinIO bucket is created if it doesn't exist.
+func NewObjectStorage(ctx context.Context, cfg *config.Config) (ObjectStorage, error) {
+	switch cfg.Storage.Driver {
+	case "", StorageDriverMinio:
+		minioSvc, err := NewMinioService(&cfg.Minio)
+		if err != nil {
+			return nil, err
+		}
+		if err := minioSvc.EnsureBucket(ctx); err != nil {
+			return nil, err
+		}
+		return minioSvc, nil
+	case StorageDriverLocal:
+		return NewLocalStorage(&cfg.Storage)
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", cfg.Storage.Driver)
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"fmt"
 	"log/slog"
 	"sort"
 	"sync"
@@ -10,105 +11,346 @@ import (
 	"github.com/AnTengye/contractdiff/backend/model"
 )
 
-// ContractStore is an in-memory store for contracts
-// In production, this should be replaced with a database
-type ContractStore struct {
-	contracts    map[string]*model.Contract
-	mu           sync.RWMutex
-	maxContracts int // Maximum contracts to keep, 0 = unlimited
+// Store drivers
+const (
+	StoreDriverMemory   = "memory"
+	StoreDriverSQLite   = "sqlite"
+	StoreDriverPostgres = "postgres"
+)
+
+// ListOptions filters and paginates a tenant's contracts
+type ListOptions struct {
+	Status string // Only contracts with this status, empty = all
+	Limit  int    // Maximum contracts to return, 0 = unlimited
+	Offset int
+}
+
+// ContractStore persists contracts and their parse results.
+// Get returns nil without an error when the contract does not exist.
+type ContractStore interface {
+	Save(contract *model.Contract) error
+	Get(id string) (*model.Contract, error)
+	GetByTenant(tenant string) ([]*model.Contract, error)
+	// ListByTenant returns a page of a tenant's contracts, newest first,
+	// together with the total number of matching contracts
+	ListByTenant(tenant string, opts ListOptions) ([]*model.Contract, int, error)
+	Delete(id string) error
+	// UpdateStatus sets the status of a pending or processing contract. It
+	// reports false, changing nothing, when the contract was deleted or
+	// finished meanwhile, e.g. cancelled.
+	UpdateStatus(id, status string, errMsg string) (bool, error)
+	// UpdateJSONData completes a pending or processing contract with its
+	// parse result. Contracts finished meanwhile, e.g. cancelled, are left
+	// unchanged.
+	UpdateJSONData(id string, jsonData any) error
+	// UpdateArtifacts replaces the artifact manifest of a contract
+	UpdateArtifacts(id string, artifacts []model.Artifact) error
+	// UpdateProgress records the parse progress of a contract
+	UpdateProgress(id string, progress *model.Progress) error
+	// FindByHash returns a tenant's completed contracts whose uploaded file
+	// has the given content hash, newest first
+	FindByHash(tenant, hash string) ([]*model.Contract, error)
+	// ListJobs returns up to limit pending or processing contracts whose
+	// next run time is at or before due, earliest first
+	ListJobs(due time.Time, limit int) ([]*model.Contract, error)
+	// ClaimJob takes the job of a pending or processing contract if it is
+	// due at due, moving its next run time to lease so that queues sharing
+	// the store skip it while it runs. It reports false when the job is no
+	// longer due, e.g. because another queue claimed it first.
+	ClaimJob(id string, due, lease time.Time) (bool, error)
+	// ListUnfinished returns every pending or processing contract
+	ListUnfinished() ([]*model.Contract, error)
+	// UpdateJob records the attempt count and next run time of a pending or
+	// processing contract's parse job. Like UpdateStatus, it reports false
+	// when the contract was deleted or finished meanwhile.
+	UpdateJob(id string, attempts int, nextRunAt time.Time) (bool, error)
+	// UpdateTask records the task a parser created for a pending or
+	// processing contract and schedules its first poll. It reports false,
+	// changing nothing, when the contract was deleted or finished meanwhile.
+	UpdateTask(id, parser, taskID string, nextRunAt time.Time) (bool, error)
+	Count() (int, error)
+	Close() error
 }
 
 var (
-	globalStore *ContractStore
+	globalStore ContractStore
 	storeOnce   sync.Once
 )
 
 // InitContractStore initializes the global contract store with configuration
-func InitContractStore(cfg *config.StoreConfig) {
+func InitContractStore(cfg *config.StoreConfig) error {
+	var err error
 	storeOnce.Do(func() {
-		maxContracts := cfg.MaxContracts
-		if maxContracts < 0 {
-			maxContracts = 0
-		}
-		globalStore = &ContractStore{
-			contracts:    make(map[string]*model.Contract),
-			maxContracts: maxContracts,
-		}
-		slog.Info("contract store initialized", "max_contracts", maxContracts)
+		globalStore, err = NewContractStore(cfg)
 	})
+	return err
+}
+
+// NewContractStore creates the contract store selected by cfg.Driver
+func NewContractStore(cfg *config.StoreConfig) (ContractStore, error) {
+	switch cfg.Driver {
+	case "", StoreDriverMemory:
+		return NewMemoryStore(cfg.MaxContracts), nil
+	case StoreDriverSQLite:
+		return NewSQLiteStore(cfg.Path)
+	case StoreDriverPostgres:
+		return NewPostgresStore(cfg.DSN)
+	default:
+		return nil, fmt.Errorf("unknown store driver: %s", cfg.Driver)
+	}
 }
 
 // GetContractStore returns the global contract store
-func GetContractStore() *ContractStore {
+func GetContractStore() ContractStore {
 	if globalStore == nil {
 		// Fallback initialization with default settings
-		globalStore = &ContractStore{
-			contracts:    make(map[string]*model.Contract),
-			maxContracts: 100, // Default: keep 100 contracts
-		}
+		globalStore = NewMemoryStore(100) // Default: keep 100 contracts
 	}
 	return globalStore
 }
 
-func (s *ContractStore) Save(contract *model.Contract) {
+// MemoryStore is an in-memory contract store. Contracts are lost on restart.
+// Like the SQL stores it keeps its own copies, so callers may modify the
+// contracts they pass in or get back without holding any lock.
+type MemoryStore struct {
+	contracts    map[string]*model.Contract
+	mu           sync.RWMutex
+	maxContracts int // Maximum contracts to keep, 0 = unlimited
+}
+
+// NewMemoryStore creates an in-memory store keeping at most maxContracts
+func NewMemoryStore(maxContracts int) *MemoryStore {
+	if maxContracts < 0 {
+		maxContracts = 0
+	}
+	slog.Info("contract store initialized",
+		"driver", StoreDriverMemory,
+		"max_contracts", maxContracts,
+	)
+	return &MemoryStore{
+		contracts:    make(map[string]*model.Contract),
+		maxContracts: maxContracts,
+	}
+}
+
+func (s *MemoryStore) Save(contract *model.Contract) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	contract.UpdatedAt = time.Now()
-	s.contracts[contract.ID] = contract
+	stored := *contract
+	s.contracts[contract.ID] = &stored
 
 	// Cleanup if exceeds max
 	s.cleanupIfNeeded()
+	return nil
 }
 
-func (s *ContractStore) Get(id string) *model.Contract {
+func (s *MemoryStore) Get(id string) (*model.Contract, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.contracts[id]
+	c, ok := s.contracts[id]
+	if !ok {
+		return nil, nil
+	}
+	contract := *c
+	return &contract, nil
 }
 
-func (s *ContractStore) GetByTenant(tenant string) []*model.Contract {
+func (s *MemoryStore) GetByTenant(tenant string) ([]*model.Contract, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	var result []*model.Contract
 	for _, c := range s.contracts {
 		if c.Tenant == tenant {
-			result = append(result, c)
+			contract := *c
+			result = append(result, &contract)
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) ListByTenant(tenant string, opts ListOptions) ([]*model.Contract, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matched []*model.Contract
+	for _, c := range s.contracts {
+		if c.Tenant == tenant && (opts.Status == "" || c.Status == opts.Status) {
+			contract := *c
+			matched = append(matched, &contract)
 		}
 	}
-	return result
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].CreatedAt.After(matched[j].CreatedAt)
+	})
+
+	total := len(matched)
+	if opts.Offset >= total {
+		return nil, total, nil
+	}
+	matched = matched[max(opts.Offset, 0):]
+	if opts.Limit > 0 && opts.Limit < len(matched) {
+		matched = matched[:opts.Limit]
+	}
+	return matched, total, nil
 }
 
-func (s *ContractStore) Delete(id string) {
+func (s *MemoryStore) Delete(id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	delete(s.contracts, id)
+	return nil
 }
 
-func (s *ContractStore) UpdateStatus(id, status string, errMsg string) {
+func (s *MemoryStore) UpdateStatus(id, status string, errMsg string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.contracts[id]
+	if !ok || !isUnfinished(c.Status) {
+		return false, nil
+	}
+	c.Status = status
+	c.ErrorMsg = errMsg
+	c.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (s *MemoryStore) UpdateJSONData(id string, jsonData any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.contracts[id]; ok && isUnfinished(c.Status) {
+		c.JSONData = jsonData
+		c.Status = model.StatusCompleted
+		c.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdateArtifacts(id string, artifacts []model.Artifact) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if c, ok := s.contracts[id]; ok {
-		c.Status = status
-		c.ErrorMsg = errMsg
+		c.Artifacts = append([]model.Artifact(nil), artifacts...)
 		c.UpdatedAt = time.Now()
 	}
+	return nil
 }
 
-func (s *ContractStore) UpdateJSONData(id string, jsonData any) {
+func (s *MemoryStore) UpdateProgress(id string, progress *model.Progress) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if c, ok := s.contracts[id]; ok {
-		c.JSONData = jsonData
-		c.Status = model.StatusCompleted
+		stored := *progress
+		c.Progress = &stored
 		c.UpdatedAt = time.Now()
 	}
+	return nil
+}
+
+func (s *MemoryStore) FindByHash(tenant, hash string) ([]*model.Contract, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*model.Contract
+	for _, c := range s.contracts {
+		if c.Tenant == tenant && c.ContentHash == hash && c.Status == model.StatusCompleted {
+			contract := *c
+			result = append(result, &contract)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (s *MemoryStore) ListJobs(due time.Time, limit int) ([]*model.Contract, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*model.Contract
+	for _, c := range s.contracts {
+		if isUnfinished(c.Status) && c.NextRunAt != nil && !c.NextRunAt.After(due) {
+			contract := *c
+			result = append(result, &contract)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].NextRunAt.Before(*result[j].NextRunAt)
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) ClaimJob(id string, due, lease time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.contracts[id]
+	if !ok || !isUnfinished(c.Status) || c.NextRunAt == nil || c.NextRunAt.After(due) {
+		return false, nil
+	}
+	c.NextRunAt = &lease
+	c.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (s *MemoryStore) ListUnfinished() ([]*model.Contract, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*model.Contract
+	for _, c := range s.contracts {
+		if isUnfinished(c.Status) {
+			contract := *c
+			result = append(result, &contract)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (s *MemoryStore) UpdateJob(id string, attempts int, nextRunAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.contracts[id]
+	if !ok || !isUnfinished(c.Status) {
+		return false, nil
+	}
+	c.Attempts = attempts
+	c.NextRunAt = &nextRunAt
+	c.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (s *MemoryStore) UpdateTask(id, parser, taskID string, nextRunAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.contracts[id]
+	if !ok || !isUnfinished(c.Status) {
+		return false, nil
+	}
+	c.Status = model.StatusProcessing
+	c.Parser = parser
+	c.MineruTaskID = taskID
+	c.Attempts = 0
+	c.NextRunAt = &nextRunAt
+	c.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func isUnfinished(status string) bool {
+	return status == model.StatusPending || status == model.StatusProcessing
 }
 
 // cleanupIfNeeded removes oldest contracts if store exceeds maxContracts
 // Must be called with lock held
-func (s *ContractStore) cleanupIfNeeded() {
+func (s *MemoryStore) cleanupIfNeeded() {
 	if s.maxContracts <= 0 {
 		return // Unlimited
 	}
@@ -129,7 +371,7 @@ func (s *ContractStore) cleanupIfNeeded() {
 	// Remove oldest contracts
 	removeCount := len(contracts) - s.maxContracts
 	for i := 0; i < removeCount; i++ {
-		slog.Info("auto-cleaning old contract",
+		slog.Warn("auto-cleaning old contract",
 			"contract_id", contracts[i].ID,
 			"created_at", contracts[i].CreatedAt,
 		)
@@ -138,8 +380,13 @@ func (s *ContractStore) cleanupIfNeeded() {
 }
 
 // Count returns the number of contracts in the store
-func (s *ContractStore) Count() int {
+func (s *MemoryStore) Count() (int, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return len(s.contracts)
+	return len(s.contracts), nil
+}
+
+// Close is a no-op for the in-memory store
+func (s *MemoryStore) Close() error {
+	return nil
 }
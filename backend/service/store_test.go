@@ -1,6 +1,7 @@
 package service
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -8,13 +9,40 @@ import (
 	"github.com/AnTengye/contractdiff/backend/model"
 )
 
-func newTestStore(maxContracts int) *ContractStore {
-	return &ContractStore{
+func newTestStore(maxContracts int) *MemoryStore {
+	return &MemoryStore{
 		contracts:    make(map[string]*model.Contract),
 		maxContracts: maxContracts,
 	}
 }
 
+func mustGet(t *testing.T, store ContractStore, id string) *model.Contract {
+	t.Helper()
+	contract, err := store.Get(id)
+	if err != nil {
+		t.Fatalf("Unexpected error getting %s: %v", id, err)
+	}
+	return contract
+}
+
+func mustGetByTenant(t *testing.T, store ContractStore, tenant string) []*model.Contract {
+	t.Helper()
+	contracts, err := store.GetByTenant(tenant)
+	if err != nil {
+		t.Fatalf("Unexpected error listing %s: %v", tenant, err)
+	}
+	return contracts
+}
+
+func mustCount(t *testing.T, store ContractStore) int {
+	t.Helper()
+	n, err := store.Count()
+	if err != nil {
+		t.Fatalf("Unexpected error counting contracts: %v", err)
+	}
+	return n
+}
+
 func TestContractStoreSaveAndGet(t *testing.T) {
 	store := newTestStore(100)
 
@@ -29,7 +57,7 @@ func TestContractStoreSaveAndGet(t *testing.T) {
 	store.Save(contract)
 
 	// Test Get
-	retrieved := store.Get("test-id-1")
+	retrieved := mustGet(t, store, "test-id-1")
 	if retrieved == nil {
 		t.Fatal("Expected to retrieve contract")
 	}
@@ -38,7 +66,7 @@ func TestContractStoreSaveAndGet(t *testing.T) {
 	}
 
 	// Test Get non-existent
-	notFound := store.Get("non-existent")
+	notFound := mustGet(t, store, "non-existent")
 	if notFound != nil {
 		t.Error("Expected nil for non-existent contract")
 	}
@@ -53,17 +81,17 @@ func TestContractStoreGetByTenant(t *testing.T) {
 	store.Save(&model.Contract{ID: "3", Tenant: "tenant2", CreatedAt: time.Now()})
 
 	// Test GetByTenant
-	tenant1Contracts := store.GetByTenant("tenant1")
+	tenant1Contracts := mustGetByTenant(t, store, "tenant1")
 	if len(tenant1Contracts) != 2 {
 		t.Errorf("Expected 2 contracts for tenant1, got %d", len(tenant1Contracts))
 	}
 
-	tenant2Contracts := store.GetByTenant("tenant2")
+	tenant2Contracts := mustGetByTenant(t, store, "tenant2")
 	if len(tenant2Contracts) != 1 {
 		t.Errorf("Expected 1 contract for tenant2, got %d", len(tenant2Contracts))
 	}
 
-	tenant3Contracts := store.GetByTenant("tenant3")
+	tenant3Contracts := mustGetByTenant(t, store, "tenant3")
 	if len(tenant3Contracts) != 0 {
 		t.Errorf("Expected 0 contracts for tenant3, got %d", len(tenant3Contracts))
 	}
@@ -74,13 +102,13 @@ func TestContractStoreDelete(t *testing.T) {
 
 	store.Save(&model.Contract{ID: "delete-me", CreatedAt: time.Now()})
 
-	if store.Get("delete-me") == nil {
+	if mustGet(t, store, "delete-me") == nil {
 		t.Fatal("Expected contract to exist before delete")
 	}
 
 	store.Delete("delete-me")
 
-	if store.Get("delete-me") != nil {
+	if mustGet(t, store, "delete-me") != nil {
 		t.Error("Expected contract to be deleted")
 	}
 }
@@ -94,23 +122,32 @@ func TestContractStoreUpdateStatus(t *testing.T) {
 		CreatedAt: time.Now(),
 	})
 
-	store.UpdateStatus("status-test", model.StatusCompleted, "")
+	store.UpdateStatus("status-test", model.StatusProcessing, "")
 
-	contract := store.Get("status-test")
-	if contract.Status != model.StatusCompleted {
-		t.Errorf("Expected status %s, got %s", model.StatusCompleted, contract.Status)
+	contract := mustGet(t, store, "status-test")
+	if contract.Status != model.StatusProcessing {
+		t.Errorf("Expected status %s, got %s", model.StatusProcessing, contract.Status)
 	}
 
 	// Test update with error message
 	store.UpdateStatus("status-test", model.StatusFailed, "test error")
-	contract = store.Get("status-test")
+	contract = mustGet(t, store, "status-test")
 	if contract.ErrorMsg != "test error" {
 		t.Errorf("Expected error msg 'test error', got '%s'", contract.ErrorMsg)
 	}
 
+	// Finished contracts stay unchanged
+	if updated, _ := store.UpdateStatus("status-test", model.StatusCompleted, ""); updated {
+		t.Error("Expected no update of a failed contract")
+	}
+	if c := mustGet(t, store, "status-test"); c.Status != model.StatusFailed {
+		t.Errorf("Expected status %s, got %s", model.StatusFailed, c.Status)
+	}
+
 	// Test update non-existent
-	store.UpdateStatus("non-existent", model.StatusCompleted, "")
-	// Should not panic
+	if updated, _ := store.UpdateStatus("non-existent", model.StatusCompleted, ""); updated {
+		t.Error("Expected no update of a missing contract")
+	}
 }
 
 func TestContractStoreUpdateJSONData(t *testing.T) {
@@ -125,7 +162,7 @@ func TestContractStoreUpdateJSONData(t *testing.T) {
 	jsonData := map[string]interface{}{"key": "value"}
 	store.UpdateJSONData("json-test", jsonData)
 
-	contract := store.Get("json-test")
+	contract := mustGet(t, store, "json-test")
 	if contract.Status != model.StatusCompleted {
 		t.Errorf("Expected status %s, got %s", model.StatusCompleted, contract.Status)
 	}
@@ -151,15 +188,15 @@ func TestContractStoreAutoCleanup(t *testing.T) {
 	}
 
 	// Should only have 3 contracts (newest)
-	if store.Count() != 3 {
-		t.Errorf("Expected 3 contracts after cleanup, got %d", store.Count())
+	if mustCount(t, store) != 3 {
+		t.Errorf("Expected 3 contracts after cleanup, got %d", mustCount(t, store))
 	}
 
 	// Oldest contracts should be removed
-	if store.Get("a") != nil {
+	if mustGet(t, store, "a") != nil {
 		t.Error("Expected oldest contract 'a' to be removed")
 	}
-	if store.Get("b") != nil {
+	if mustGet(t, store, "b") != nil {
 		t.Error("Expected second oldest contract 'b' to be removed")
 	}
 }
@@ -176,23 +213,23 @@ func TestContractStoreUnlimitedContracts(t *testing.T) {
 	}
 
 	// All should be present
-	if store.Count() != 10 {
-		t.Errorf("Expected 10 contracts, got %d", store.Count())
+	if mustCount(t, store) != 10 {
+		t.Errorf("Expected 10 contracts, got %d", mustCount(t, store))
 	}
 }
 
 func TestContractStoreCount(t *testing.T) {
 	store := newTestStore(100)
 
-	if store.Count() != 0 {
+	if mustCount(t, store) != 0 {
 		t.Error("Expected 0 contracts initially")
 	}
 
 	store.Save(&model.Contract{ID: "1", CreatedAt: time.Now()})
 	store.Save(&model.Contract{ID: "2", CreatedAt: time.Now()})
 
-	if store.Count() != 2 {
-		t.Errorf("Expected 2 contracts, got %d", store.Count())
+	if mustCount(t, store) != 2 {
+		t.Errorf("Expected 2 contracts, got %d", mustCount(t, store))
 	}
 }
 
@@ -207,6 +244,225 @@ func TestGetContractStore(t *testing.T) {
 func TestInitContractStoreConfig(t *testing.T) {
 	// Test InitContractStore with config
 	cfg := &config.StoreConfig{MaxContracts: 50}
-	InitContractStore(cfg)
-	// Should not panic
+	if err := InitContractStore(cfg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestNewContractStoreDrivers(t *testing.T) {
+	store, err := NewContractStore(&config.StoreConfig{Driver: StoreDriverMemory})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := store.(*MemoryStore); !ok {
+		t.Errorf("Expected *MemoryStore, got %T", store)
+	}
+
+	if _, err := NewContractStore(&config.StoreConfig{Driver: "unknown"}); err == nil {
+		t.Error("Expected error for unknown driver")
+	}
+}
+
+// testStoreListByTenant checks status filtering and pagination on any store.
+// Contracts are created under tenant, which should be unique to the test.
+func testStoreListByTenant(t *testing.T, store ContractStore, tenant string) {
+	t.Helper()
+
+	base := time.Now().Add(-time.Hour)
+	statuses := []string{model.StatusCompleted, model.StatusFailed, model.StatusCompleted, model.StatusPending, model.StatusCompleted}
+	for i, status := range statuses {
+		err := store.Save(&model.Contract{
+			ID:        fmt.Sprintf("%s-%d", tenant, i),
+			Tenant:    tenant,
+			Status:    status,
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("Failed to save contract: %v", err)
+		}
+	}
+	store.Save(&model.Contract{ID: tenant + "-other", Tenant: tenant + "-other", Status: model.StatusCompleted, CreatedAt: base})
+
+	all, total, err := store.ListByTenant(tenant, ListOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if total != 5 || len(all) != 5 {
+		t.Fatalf("Expected 5 contracts, got %d (total %d)", len(all), total)
+	}
+	if all[0].ID != tenant+"-4" || all[4].ID != tenant+"-0" {
+		t.Errorf("Expected newest first, got %s ... %s", all[0].ID, all[4].ID)
+	}
+
+	completed, total, err := store.ListByTenant(tenant, ListOptions{Status: model.StatusCompleted})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if total != 3 || len(completed) != 3 {
+		t.Errorf("Expected 3 completed contracts, got %d (total %d)", len(completed), total)
+	}
+
+	page, total, err := store.ListByTenant(tenant, ListOptions{Status: model.StatusCompleted, Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if total != 3 || len(page) != 2 {
+		t.Fatalf("Expected page of 2 with total 3, got %d (total %d)", len(page), total)
+	}
+	if page[0].ID != tenant+"-2" || page[1].ID != tenant+"-0" {
+		t.Errorf("Unexpected page: %s, %s", page[0].ID, page[1].ID)
+	}
+
+	rest, _, err := store.ListByTenant(tenant, ListOptions{Offset: 4})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rest) != 1 {
+		t.Errorf("Expected 1 contract after offset 4, got %d", len(rest))
+	}
+
+	beyond, total, err := store.ListByTenant(tenant, ListOptions{Offset: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(beyond) != 0 || total != 5 {
+		t.Errorf("Expected empty page with total 5, got %d (total %d)", len(beyond), total)
+	}
+}
+
+func TestContractStoreListByTenant(t *testing.T) {
+	testStoreListByTenant(t, newTestStore(0), "list-tenant")
+}
+
+// testStoreJobs checks parse job scheduling on any store. Contracts are
+// created under tenant, and jobs of other tenants are ignored.
+func testStoreJobs(t *testing.T, store ContractStore, tenant string) {
+	t.Helper()
+
+	now := time.Now()
+	past, later := now.Add(-time.Minute), now.Add(time.Hour)
+	contracts := []*model.Contract{
+		{ID: tenant + "-due", Status: model.StatusProcessing, MineruTaskID: "task-1", Attempts: 2, NextRunAt: &past},
+		{ID: tenant + "-later", Status: model.StatusProcessing, MineruTaskID: "task-2", NextRunAt: &later},
+		{ID: tenant + "-new", Status: model.StatusPending},
+		{ID: tenant + "-done", Status: model.StatusCompleted, NextRunAt: &past},
+	}
+	for i, c := range contracts {
+		c.Tenant = tenant
+		c.CreatedAt = now.Add(time.Duration(i-len(contracts)) * time.Minute)
+		if err := store.Save(c); err != nil {
+			t.Fatalf("Failed to save contract: %v", err)
+		}
+	}
+
+	ids := func(contracts []*model.Contract) []string {
+		var result []string
+		for _, c := range contracts {
+			if c.Tenant == tenant {
+				result = append(result, c.ID)
+			}
+		}
+		return result
+	}
+
+	due, err := store.ListJobs(now, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := ids(due); len(got) != 1 || got[0] != tenant+"-due" {
+		t.Fatalf("Expected only the due job, got %v", got)
+	}
+	if job := mustGet(t, store, tenant+"-due"); job.Attempts != 2 || job.NextRunAt == nil || job.MineruTaskID != "task-1" {
+		t.Errorf("Unexpected job: %+v", job)
+	}
+
+	unfinished, err := store.ListUnfinished()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := ids(unfinished); len(got) != 3 || got[0] != tenant+"-due" || got[2] != tenant+"-new" {
+		t.Errorf("Expected 3 unfinished contracts oldest first, got %v", got)
+	}
+
+	if _, err := store.UpdateJob(tenant+"-new", 1, past); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := store.UpdateJob(tenant+"-due", 3, later); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if updated, err := store.UpdateJob(tenant+"-done", 1, past); updated || err != nil {
+		t.Errorf("Expected no job update of a finished contract, got %v, %v", updated, err)
+	}
+	due, err = store.ListJobs(now, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := ids(due); len(got) != 1 || got[0] != tenant+"-new" {
+		t.Errorf("Expected the rescheduled job to be due, got %v", got)
+	}
+	if c := mustGet(t, store, tenant+"-due"); c.Attempts != 3 || c.NextRunAt == nil || !c.NextRunAt.After(now) {
+		t.Errorf("Expected 3 attempts and a later run, got %d %v", c.Attempts, c.NextRunAt)
+	}
+
+	// A claimed job is no longer due, so only one claim succeeds
+	lease := now.Add(time.Minute)
+	for k, want := range []bool{true, false} {
+		claimed, err := store.ClaimJob(tenant+"-new", now, lease)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if claimed != want {
+			t.Errorf("Claim %d: expected %v, got %v", k, want, claimed)
+		}
+	}
+	if c := mustGet(t, store, tenant+"-new"); c.NextRunAt == nil || !c.NextRunAt.After(now) {
+		t.Errorf("Expected the claimed job to run after its lease, got %v", c.NextRunAt)
+	}
+	for _, id := range []string{tenant + "-later", tenant + "-done", tenant + "-missing"} {
+		if claimed, err := store.ClaimJob(id, now, lease); claimed || err != nil {
+			t.Errorf("%s: expected no claim, got %v, %v", id, claimed, err)
+		}
+	}
+	if _, err := store.UpdateJob(tenant+"-new", 1, past); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	limited, err := store.ListJobs(later, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(limited) != 1 {
+		t.Errorf("Expected 1 job with limit 1, got %d", len(limited))
+	}
+
+	// Tasks are only recorded on unfinished contracts
+	for _, tt := range []struct {
+		id      string
+		updated bool
+	}{
+		{tenant + "-new", true},
+		{tenant + "-done", false},
+		{tenant + "-missing", false},
+	} {
+		updated, err := store.UpdateTask(tt.id, "fake", "task-3", later)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if updated != tt.updated {
+			t.Errorf("%s: expected updated %v, got %v", tt.id, tt.updated, updated)
+		}
+	}
+	if c := mustGet(t, store, tenant+"-new"); c.Status != model.StatusProcessing || c.Parser != "fake" || c.MineruTaskID != "task-3" || c.Attempts != 0 || c.NextRunAt == nil {
+		t.Errorf("Expected the task to be recorded, got %+v", c)
+	}
+	if c := mustGet(t, store, tenant+"-done"); c.Status != model.StatusCompleted || c.MineruTaskID != "" {
+		t.Errorf("Expected the completed contract to be unchanged, got %+v", c)
+	}
+	if c := mustGet(t, store, tenant+"-missing"); c != nil {
+		t.Errorf("Expected no contract to be created, got %+v", c)
+	}
+}
+
+func TestContractStoreJobs(t *testing.T) {
+	testStoreJobs(t, newTestStore(0), "jobs-tenant")
 }